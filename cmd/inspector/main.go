@@ -2,37 +2,52 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"ExpeditusClient/internal/browser"
-
-	"github.com/chromedp/chromedp"
+	"ExpeditusClient/internal/inspector"
+	"ExpeditusClient/internal/output"
 )
 
-type PageAnalysis struct {
-	URL             string   `json:"url"`
-	Title           string   `json:"title"`
-	IsSPA           bool     `json:"is_spa"`
-	MetaDescription string   `json:"meta_description"`
-	SemanticAnchors []string `json:"semantic_anchors"`
-	FormsFound      int      `json:"forms_count"`
-	ButtonsFound    int      `json:"buttons_count"`
-	SuggestedDriver string   `json:"suggested_driver"`
-	Error           string   `json:"error,omitempty"`
+func main() {
+	os.Exit(run())
 }
 
-func main() {
+func run() int {
 	urlFlag := flag.String("url", "", "URL to inspect")
 	timeoutFlag := flag.Int("timeout", 30, "Timeout in seconds")
 	waitSelector := flag.String("wait", "", "CSS selector to wait for")
+	formatFlag := flag.String("format", string(output.FormatJSON), "Output format: json, ndjson, csv or table")
 	flag.Parse()
 
+	format, err := output.ParseFormat(*formatFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return output.ExitUsage
+	}
+	report := output.NewReport("inspector")
+	report.Meta.Params = map[string]string{"url": *urlFlag, "wait": *waitSelector}
+	finish := func(code int) int {
+		if report.Error != nil && report.Data == nil {
+			// data.error keeps the field the inspector wrote before the envelope
+			report.Data = &inspector.PageAnalysis{URL: *urlFlag, Error: report.Error.Message}
+		}
+		if err := report.Write(os.Stdout, os.Stderr, format); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+			return output.ExitFailure
+		}
+		return code
+	}
+
 	if *urlFlag == "" {
-		fail("URL is required. Usage: -url <https://example.com>")
+		report.Fail("usage", errors.New("URL is required. Usage: -url <https://example.com>"))
+		return finish(output.ExitUsage)
 	}
 
 	cfg := browser.DefaultConfig()
@@ -41,164 +56,29 @@ func main() {
 	ctx := context.Background()
 	pool, err := browser.NewPool(ctx, cfg)
 	if err != nil {
-		fail(fmt.Sprintf("browser pool error: %v", err))
+		report.Fail("browser", fmt.Errorf("browser pool error: %w", err))
+		return finish(output.ExitFailure)
 	}
 	defer pool.Close()
 
-	analysis, err := inspectPage(ctx, pool, *urlFlag, *waitSelector)
+	analysis, err := inspector.Inspect(ctx, pool, *urlFlag, *waitSelector)
 	if err != nil {
-		fail(err.Error())
-	}
-
-	outputJSON(analysis)
-}
-
-func inspectPage(ctx context.Context, pool *browser.Pool, url, waitSelector string) (*PageAnalysis, error) {
-	browserCtx, cancel := pool.NewContext(ctx)
-	defer cancel()
-
-	var raw map[string]interface{}
-
-	tasks := []chromedp.Action{
-		chromedp.Navigate(url),
-		chromedp.WaitReady("body", chromedp.ByQuery),
-	}
-
-	if waitSelector != "" {
-		tasks = append(tasks, chromedp.WaitVisible(waitSelector, chromedp.ByQuery))
-	}
-
-	tasks = append(tasks, chromedp.Evaluate(buildInspectionScript(), &raw))
-
-	if err := chromedp.Run(browserCtx, tasks...); err != nil {
-		return nil, fmt.Errorf("inspection failed: %w", err)
-	}
-
-	return parseAnalysis(url, raw), nil
-}
-
-func buildInspectionScript() string {
-	return `(() => {
-		const isSPA = !!(
-			document.querySelector('#root') ||
-			document.querySelector('#app') ||
-			document.querySelector('[data-reactroot]') ||
-			(document.scripts.length > 5 && document.body.innerText.length < 500)
-		);
-
-		const metaDesc = document.querySelector('meta[name="description"]')?.content || "";
-
-		const getVisibleText = (node) => {
-			if (node.nodeType === Node.TEXT_NODE) {
-				const text = node.textContent.trim();
-				if (text.length >= 3 && text.length <= 40 && isNaN(Number(text)) && !text.includes('{')) {
-					return text;
-				}
-			}
-			return null;
-		};
-
-		const anchors = new Set();
-		const walker = document.createTreeWalker(
-			document.body,
-			NodeFilter.SHOW_TEXT,
-			{ acceptNode: (node) => {
-				if (['SCRIPT', 'STYLE', 'NOSCRIPT'].includes(node.parentNode.nodeName)) {
-					return NodeFilter.FILTER_REJECT;
-				}
-				if (node.parentNode.offsetParent === null) {
-					return NodeFilter.FILTER_REJECT;
-				}
-				return NodeFilter.FILTER_ACCEPT;
-			}}
-		);
-
-		let count = 0;
-		while(walker.nextNode() && count < 50) {
-			const txt = getVisibleText(walker.currentNode);
-			if (txt) {
-				anchors.add(txt);
-				count++;
-			}
-		}
-
-		return {
-			title: document.title,
-			is_spa: isSPA,
-			meta_description: metaDesc,
-			semantic_anchors: Array.from(anchors),
-			forms_count: document.querySelectorAll('form').length,
-			buttons_count: document.querySelectorAll('button, [role="button"], input[type="submit"]').length
-		};
-	})()`
-}
-
-func parseAnalysis(url string, raw map[string]interface{}) *PageAnalysis {
-	analysis := &PageAnalysis{
-		URL:             url,
-		Title:           getStringSafe(raw, "title"),
-		MetaDescription: getStringSafe(raw, "meta_description"),
-		IsSPA:           getBoolSafe(raw, "is_spa"),
-		FormsFound:      getIntSafe(raw, "forms_count"),
-		ButtonsFound:    getIntSafe(raw, "buttons_count"),
+		report.Fail("failed", err)
+		return finish(output.ExitFailure)
 	}
 
-	if anchors, ok := raw["semantic_anchors"].([]interface{}); ok {
-		analysis.SemanticAnchors = make([]string, 0, len(anchors))
-		for _, a := range anchors {
-			if s, ok := a.(string); ok {
-				analysis.SemanticAnchors = append(analysis.SemanticAnchors, s)
-			}
-		}
-	}
-
-	if analysis.IsSPA || analysis.ButtonsFound > 10 {
-		analysis.SuggestedDriver = "chromedp"
-	} else {
-		analysis.SuggestedDriver = "sonar-static"
-	}
-
-	return analysis
+	fillReport(report, analysis)
+	return finish(output.ExitOK)
 }
 
-func getStringSafe(m map[string]interface{}, key string) string {
-	if v, ok := m[key]; ok {
-		if s, ok := v.(string); ok {
-			return s
-		}
+func fillReport(report *output.Report, a *inspector.PageAnalysis) {
+	report.Data = a
+	report.Records = []output.Record{{Kind: "page", Data: a}}
+	report.Table = output.Table{
+		Columns: []string{"url", "title", "is_spa", "meta_description", "forms_count", "buttons_count", "suggested_driver", "semantic_anchors"},
+		Rows: [][]string{{
+			a.URL, a.Title, strconv.FormatBool(a.IsSPA), a.MetaDescription,
+			strconv.Itoa(a.FormsFound), strconv.Itoa(a.ButtonsFound), a.SuggestedDriver, strings.Join(a.SemanticAnchors, "; "),
+		}},
 	}
-	return ""
-}
-
-func getBoolSafe(m map[string]interface{}, key string) bool {
-	if v, ok := m[key]; ok {
-		if b, ok := v.(bool); ok {
-			return b
-		}
-	}
-	return false
-}
-
-func getIntSafe(m map[string]interface{}, key string) int {
-	if v, ok := m[key]; ok {
-		switch n := v.(type) {
-		case float64:
-			return int(n)
-		case int:
-			return n
-		}
-	}
-	return 0
-}
-
-func fail(msg string) {
-	errObj := PageAnalysis{Error: msg}
-	data, _ := json.Marshal(errObj)
-	fmt.Println(string(data))
-	os.Exit(1)
-}
-
-func outputJSON(a *PageAnalysis) {
-	data, _ := json.MarshalIndent(a, "", "  ")
-	fmt.Println(string(data))
 }
@@ -0,0 +1,506 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"ExpeditusClient/internal/config"
+	"ExpeditusClient/internal/delfos"
+	"ExpeditusClient/internal/inspector"
+	"ExpeditusClient/internal/jobs"
+	"ExpeditusClient/internal/output"
+	"ExpeditusClient/internal/scheduler"
+)
+
+const (
+	apiRequestTimeout = 5 * time.Minute
+	maxRequestBody    = 1 << 20
+)
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+// api serves the HTTP endpoints of "login serve". Every response is the same
+// JSON envelope the commands print with -format json. Browser work runs as
+// queued jobs: the synchronous endpoints submit an interactive job and wait
+// for it, /v1/jobs submits without waiting.
+type api struct {
+	cfg    *config.LoginConfig
+	wk     *workers
+	sched  *scheduler.Scheduler
+	logger *log.Logger
+
+	token   string        // bearer token required on every route but /healthz
+	timeout time.Duration // how long a request waits for its job
+}
+
+func newAPI(cfg *config.LoginConfig, wk *workers, sched *scheduler.Scheduler, token string, logger *log.Logger) *api {
+	return &api{cfg: cfg, wk: wk, sched: sched, logger: logger, token: token, timeout: apiRequestTimeout}
+}
+
+func (a *api) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", a.health)
+	mux.HandleFunc("GET /openapi.json", a.openAPI)
+	mux.HandleFunc("GET /v1/status", a.status)
+	mux.HandleFunc("GET /v1/session", a.sessionStatus)
+	mux.HandleFunc("POST /v1/session/logout", a.logout)
+	mux.HandleFunc("POST /v1/search", a.search)
+	mux.HandleFunc("GET /v1/search/stream", a.searchStream)
+	mux.HandleFunc("POST /v1/search/stream", a.searchStream)
+	mux.HandleFunc("POST /v1/hotels/details", a.hotelDetails)
+	mux.HandleFunc("POST /v1/inspect", a.inspect)
+	mux.HandleFunc("POST /v1/jobs", a.submitJob)
+	mux.HandleFunc("GET /v1/jobs", a.listJobs)
+	mux.HandleFunc("GET /v1/jobs/{id}", a.getJob)
+	mux.HandleFunc("DELETE /v1/jobs/{id}", a.cancelJob)
+	return a.logRequests(a.authorize(mux))
+}
+
+// authorize rejects requests without the API token. The token goes in an
+// "Authorization: Bearer" header; GET /v1/search/stream also takes it as the
+// access_token parameter, since EventSource cannot set headers.
+func (a *api) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok && r.Method == http.MethodGet && r.URL.Path == "/v1/search/stream" {
+			token = r.URL.Query().Get("access_token")
+		}
+		if a.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="expeditus"`)
+			report := output.NewReport("api")
+			report.Fail("unauthorized", errors.New("missing or invalid bearer token"))
+			respond(w, http.StatusUnauthorized, report)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *api) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		a.logger.Printf("%s %s %d %s", r.Method, r.URL.Path, rec.status, time.Since(start).Round(time.Millisecond))
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController flush streamed responses.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// health is the unauthenticated liveness probe; it says nothing about the
+// sessions or the queue, which are behind the token at /v1/status.
+func (a *api) health(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	io.WriteString(w, `{"status":"ok"}`+"\n")
+}
+
+func (a *api) status(w http.ResponseWriter, r *http.Request) {
+	queue := make(map[jobs.Status]int)
+	for _, job := range a.wk.queue.List("") {
+		if !job.Status.Done() {
+			queue[job.Status]++
+		}
+	}
+	report := output.NewReport("api status")
+	report.Data = struct {
+		Sessions   []sessionStatus               `json:"sessions"`
+		Queue      map[jobs.Status]int           `json:"queue"`
+		Challenges []challengeStatus             `json:"challenges,omitempty"` // CAPTCHAs waiting for a human
+		Jobs       map[string]scheduler.JobState `json:"jobs,omitempty"`
+	}{a.wk.statuses(), queue, a.wk.challenges.list(), a.sched.State()}
+	respond(w, http.StatusOK, report)
+}
+
+func (a *api) openAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
+
+func (a *api) sessionStatus(w http.ResponseWriter, r *http.Request) {
+	report := output.NewReport("api session")
+	if r.URL.Query().Get("verify") == "true" {
+		ctx, cancel := context.WithTimeout(r.Context(), a.timeout)
+		defer cancel()
+		if err := a.wk.do(ctx, jobKeepAlive, jobs.PriorityInteractive, struct{}{}, nil); err != nil {
+			fail(w, r, report, err)
+			return
+		}
+	}
+	report.Data = a.wk.statuses()
+	respond(w, http.StatusOK, report)
+}
+
+func (a *api) logout(w http.ResponseWriter, r *http.Request) {
+	report := output.NewReport("api logout")
+	ctx, cancel := context.WithTimeout(r.Context(), a.timeout)
+	defer cancel()
+	var outcome delfos.LoginOutcome
+	if err := a.wk.do(ctx, jobLogout, jobs.PriorityInteractive, struct{}{}, &outcome); err != nil {
+		fail(w, r, report, err)
+		return
+	}
+	report.Data = outcome
+	respond(w, http.StatusOK, report)
+}
+
+func (a *api) search(w http.ResponseWriter, r *http.Request) {
+	report := output.NewReport("api search")
+	var p searchParams
+	err := decodeJSON(r, &p)
+	if err == nil {
+		report.Meta.Params = requestParams(p)
+		p, err = a.checkSearch(p)
+	}
+	if err != nil {
+		report.Fail("usage", err)
+		respond(w, http.StatusBadRequest, report)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), a.timeout)
+	defer cancel()
+	result := &LoginResult{}
+	if err := a.wk.do(ctx, jobSearch, jobs.PriorityInteractive, p, result); err != nil {
+		fail(w, r, report, err)
+		return
+	}
+	fillReport(report, result)
+	respond(w, http.StatusOK, report)
+}
+
+// checkSearch fills the defaults of a search and validates it, so bad input
+// is rejected before it is queued.
+func (a *api) checkSearch(p searchParams) (searchParams, error) {
+	p = p.withDefaults()
+	if _, _, err := buildSearch(a.cfg, nil, p, time.Now(), nil); err != nil {
+		return p, fmt.Errorf("invalid search: %w", err)
+	}
+	return p, nil
+}
+
+// hotelDetails takes a hotel as returned by /v1/search and extracts its rates.
+func (a *api) hotelDetails(w http.ResponseWriter, r *http.Request) {
+	report := output.NewReport("api hotel details")
+	var h delfos.Hotel
+	err := decodeJSON(r, &h)
+	if err == nil {
+		err = a.checkDetailURL(h.DetailURL)
+	}
+	if err != nil {
+		report.Fail("usage", err)
+		respond(w, http.StatusBadRequest, report)
+		return
+	}
+	report.Meta.Params = map[string]string{"name": h.Name, "detail_url": h.DetailURL}
+
+	ctx, cancel := context.WithTimeout(r.Context(), a.timeout)
+	defer cancel()
+	result := &LoginResult{}
+	if err := a.wk.do(ctx, jobDetails, jobs.PriorityInteractive, h, result); err != nil {
+		fail(w, r, report, err)
+		return
+	}
+	fillReport(report, result)
+	respond(w, http.StatusOK, report)
+}
+
+// checkDetailURL only lets the browser open hotel pages of the configured site.
+func (a *api) checkDetailURL(raw string) error {
+	if raw == "" {
+		return errors.New("detail_url is required; pass a hotel from a search response")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("detail_url: %w", err)
+	}
+	site, err := url.Parse(a.cfg.TargetURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != site.Scheme || u.Host != site.Host {
+		return fmt.Errorf("detail_url must be on %s://%s", site.Scheme, site.Host)
+	}
+	return nil
+}
+
+func (a *api) inspect(w http.ResponseWriter, r *http.Request) {
+	report := output.NewReport("api inspect")
+	var p inspectParams
+	err := decodeJSON(r, &p)
+	if err == nil {
+		err = a.checkInspectURL(p.URL)
+	}
+	if err != nil {
+		report.Fail("usage", err)
+		respond(w, http.StatusBadRequest, report)
+		return
+	}
+	report.Meta.Params = map[string]string{"url": p.URL, "wait": p.Wait}
+
+	ctx, cancel := context.WithTimeout(r.Context(), a.timeout)
+	defer cancel()
+	var analysis inspector.PageAnalysis
+	if err := a.wk.do(ctx, jobInspect, jobs.PriorityInteractive, p, &analysis); err != nil {
+		fail(w, r, report, err)
+		return
+	}
+	report.Data = analysis
+	report.Records = []output.Record{{Kind: "page", Data: analysis}}
+	respond(w, http.StatusOK, report)
+}
+
+// checkInspectURL only lets the inspector open pages of the configured site:
+// it runs in the browser that holds the supplier session.
+func (a *api) checkInspectURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http(s) URL, got %q", raw)
+	}
+	site, err := url.Parse(a.cfg.TargetURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != site.Scheme || u.Host != site.Host {
+		return fmt.Errorf("url must be on %s://%s", site.Scheme, site.Host)
+	}
+	return nil
+}
+
+// jobRequest is the body of POST /v1/jobs.
+type jobRequest struct {
+	Kind     string          `json:"kind"`
+	Priority string          `json:"priority,omitempty"` // interactive (default) or background
+	Params   json.RawMessage `json:"params,omitempty"`
+}
+
+// submitJob queues a job and answers 202 with it; its result is read later
+// from /v1/jobs/{id}.
+func (a *api) submitJob(w http.ResponseWriter, r *http.Request) {
+	report := output.NewReport("api submit job")
+	var req jobRequest
+	err := decodeJSON(r, &req)
+	var priority int
+	var params any
+	if err == nil {
+		report.Meta.Params = map[string]string{"kind": req.Kind, "priority": req.Priority}
+		priority, err = jobPriority(req.Priority)
+	}
+	if err == nil {
+		params, err = a.jobParams(req.Kind, req.Params)
+	}
+	if err != nil {
+		report.Fail("usage", err)
+		respond(w, http.StatusBadRequest, report)
+		return
+	}
+
+	job, err := a.wk.submit("", req.Kind, priority, params)
+	if err != nil {
+		report.Fail("failed", err)
+		respond(w, http.StatusInternalServerError, report)
+		return
+	}
+	w.Header().Set("Location", "/v1/jobs/"+job.ID)
+	report.Data = job
+	respond(w, http.StatusAccepted, report)
+}
+
+func jobPriority(name string) (int, error) {
+	switch name {
+	case "", "interactive":
+		return jobs.PriorityInteractive, nil
+	case "background":
+		return jobs.PriorityBackground, nil
+	}
+	return 0, fmt.Errorf("unknown priority %q (want interactive or background)", name)
+}
+
+// jobParams decodes and validates the parameters of a job kind.
+func (a *api) jobParams(kind string, raw json.RawMessage) (any, error) {
+	if len(raw) == 0 {
+		raw = json.RawMessage("{}")
+	}
+	decode := func(v any) error {
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(v); err != nil {
+			return fmt.Errorf("invalid params: %w", err)
+		}
+		return nil
+	}
+
+	switch kind {
+	case jobSearch:
+		var p searchParams
+		if err := decode(&p); err != nil {
+			return nil, err
+		}
+		return a.checkSearch(p)
+	case jobDetails:
+		var h delfos.Hotel
+		if err := decode(&h); err != nil {
+			return nil, err
+		}
+		return h, a.checkDetailURL(h.DetailURL)
+	case jobInspect:
+		var p inspectParams
+		if err := decode(&p); err != nil {
+			return nil, err
+		}
+		return p, a.checkInspectURL(p.URL)
+	case jobWatchlist:
+		var p watchlistParams
+		if err := decode(&p); err != nil {
+			return nil, err
+		}
+		if p.Notify != "" {
+			if _, err := buildNotifiers(p.Notify, config.LoadNotifyConfig(), io.Discard); err != nil {
+				return nil, err
+			}
+		}
+		return p, nil
+	case jobKeepAlive, jobLogout:
+		return struct{}{}, decode(&struct{}{})
+	}
+	return nil, fmt.Errorf("unknown job kind %q (want search, details, inspect, watchlist, keepalive or logout)", kind)
+}
+
+func (a *api) listJobs(w http.ResponseWriter, r *http.Request) {
+	report := output.NewReport("api jobs")
+	status := jobs.Status(r.URL.Query().Get("status"))
+	switch status {
+	case "", jobs.StatusQueued, jobs.StatusRunning, jobs.StatusSucceeded, jobs.StatusFailed, jobs.StatusCanceled:
+	default:
+		report.Fail("usage", fmt.Errorf("unknown status %q", status))
+		respond(w, http.StatusBadRequest, report)
+		return
+	}
+	list := a.wk.queue.List(status)
+	for i := range list {
+		list[i].Result = nil // keep listings small; fetch a job for its result
+	}
+	report.Data = list
+	respond(w, http.StatusOK, report)
+}
+
+func (a *api) getJob(w http.ResponseWriter, r *http.Request) {
+	report := output.NewReport("api job")
+	job, ok := a.wk.queue.Get(r.PathValue("id"))
+	if !ok {
+		report.Fail("not_found", jobs.ErrNotFound)
+		respond(w, http.StatusNotFound, report)
+		return
+	}
+	report.Data = job
+	respond(w, http.StatusOK, report)
+}
+
+// cancelJob cancels a queued or running job. A running job reports
+// "running" until its worker has stopped.
+func (a *api) cancelJob(w http.ResponseWriter, r *http.Request) {
+	report := output.NewReport("api cancel job")
+	job, err := a.wk.queue.Cancel(r.PathValue("id"))
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		report.Fail("not_found", err)
+		respond(w, http.StatusNotFound, report)
+		return
+	case errors.Is(err, jobs.ErrFinished):
+		report.Data = job
+		report.Fail("finished", err)
+		respond(w, http.StatusConflict, report)
+		return
+	case err != nil:
+		report.Fail("failed", err)
+		respond(w, http.StatusInternalServerError, report)
+		return
+	}
+	report.Data = job
+	respond(w, http.StatusOK, report)
+}
+
+// decodeJSON reads a single JSON object, rejecting unknown fields.
+func decodeJSON(r *http.Request, v any) error {
+	dec := json.NewDecoder(io.LimitReader(r.Body, maxRequestBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	if dec.More() {
+		return errors.New("invalid request body: more than one JSON value")
+	}
+	return nil
+}
+
+// requestParams flattens a request body into the run metadata.
+func requestParams(v any) map[string]string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil
+	}
+	params := make(map[string]string, len(fields))
+	for k, v := range fields {
+		params[k] = fmt.Sprint(v)
+	}
+	return params
+}
+
+// fail reports a failed job or browser run, mapping the error to the HTTP
+// status.
+func fail(w http.ResponseWriter, r *http.Request, report *output.Report, err error) {
+	status, code := http.StatusBadGateway, "failed"
+	var loginErr *delfos.LoginError
+	var failure *jobFailure
+	switch {
+	case errors.As(err, &loginErr):
+		_, code = exitCode(err)
+	case errors.As(err, &failure):
+		status, code = failure.httpStatus(), failure.Code
+	case r.Context().Err() != nil:
+		status, code = 499, "canceled" // client closed the request
+	case errors.Is(err, context.DeadlineExceeded):
+		status, code = http.StatusGatewayTimeout, "timeout"
+	}
+	report.Fail(code, err)
+	respond(w, status, report)
+}
+
+func respond(w http.ResponseWriter, status int, report *output.Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	// a write error means the client is gone; there is nobody to report it to
+	report.Write(w, nil, output.FormatJSON)
+}
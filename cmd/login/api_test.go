@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"ExpeditusClient/internal/config"
+	"ExpeditusClient/internal/jobs"
+	"ExpeditusClient/internal/scheduler"
+)
+
+const testToken = "secret"
+
+func newTestAPI(t *testing.T) *api {
+	t.Helper()
+	dir := t.TempDir()
+	queue, err := jobs.Open(filepath.Join(dir, "jobs"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sched, err := scheduler.New(filepath.Join(dir, "scheduler-state.json"), func(string, ...any) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := log.New(io.Discard, "", 0)
+	cfg := &config.LoginConfig{TargetURL: "https://www.delfos.tur.ar/"}
+	a := newAPI(cfg, newWorkers(1, cfg, config.HandoffConfig{}, nil, queue, logger), sched, testToken, logger)
+	a.timeout = 200 * time.Millisecond
+	return a
+}
+
+// envelope is the part of the response envelope the tests look at.
+type envelope struct {
+	Status string          `json:"status"`
+	Data   json.RawMessage `json:"data"`
+	Error  *struct {
+		Code string `json:"code"`
+	} `json:"error"`
+}
+
+func call(t *testing.T, h http.Handler, req *http.Request) (int, envelope) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	var env envelope
+	if err := json.Unmarshal(rec.Body.Bytes(), &env); err != nil {
+		t.Fatalf("%s %s: decode %q: %v", req.Method, req.URL, rec.Body.String(), err)
+	}
+	return rec.Code, env
+}
+
+func authed(method, target, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	return req
+}
+
+func code(env envelope) string {
+	if env.Error == nil {
+		return ""
+	}
+	return env.Error.Code
+}
+
+func future(days int) string {
+	return time.Now().AddDate(0, 0, days).Format("02/01/2006")
+}
+
+func validSearch() string {
+	return `{"dest": "PUJ", "checkin": "` + future(30) + `", "checkout": "` + future(37) + `"}`
+}
+
+func TestAPIAuthorization(t *testing.T) {
+	h := newTestAPI(t).routes()
+	tests := []struct {
+		name string
+		req  *http.Request
+		want int
+	}{
+		{"health is open", httptest.NewRequest("GET", "/healthz", nil), http.StatusOK},
+		{"status needs a token", httptest.NewRequest("GET", "/v1/status", nil), http.StatusUnauthorized},
+		{"status", authed("GET", "/v1/status", ""), http.StatusOK},
+		{"no token", httptest.NewRequest("GET", "/v1/jobs", nil), http.StatusUnauthorized},
+		{"wrong token", func() *http.Request {
+			req := httptest.NewRequest("GET", "/v1/jobs", nil)
+			req.Header.Set("Authorization", "Bearer nope")
+			return req
+		}(), http.StatusUnauthorized},
+		{"openapi needs a token", httptest.NewRequest("GET", "/openapi.json", nil), http.StatusUnauthorized},
+		{"logout needs a token", httptest.NewRequest("POST", "/v1/session/logout", nil), http.StatusUnauthorized},
+		{"token", authed("GET", "/v1/jobs", ""), http.StatusOK},
+		// the query token only counts for EventSource; past auth, bad params are a 400
+		{"stream query token", httptest.NewRequest("GET", "/v1/search/stream?access_token="+testToken, nil), http.StatusBadRequest},
+		{"query token elsewhere", httptest.NewRequest("GET", "/v1/jobs?access_token="+testToken, nil), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, tt.req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d (%s)", tt.name, rec.Code, tt.want, rec.Body.String())
+		}
+	}
+}
+
+func TestAPIHealth(t *testing.T) {
+	a := newTestAPI(t)
+	a.wk.challenges.add(challengeStatus{DevTools: "http://127.0.0.1:9222/devtools/inspector.html"})
+	h := a.routes()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+	if body := strings.TrimSpace(rec.Body.String()); rec.Code != http.StatusOK || body != `{"status":"ok"}` {
+		t.Errorf("healthz: %d %s", rec.Code, body)
+	}
+
+	status, env := call(t, h, authed("GET", "/v1/status", ""))
+	if status != http.StatusOK || !strings.Contains(string(env.Data), "9222") || !strings.Contains(string(env.Data), `"sessions"`) {
+		t.Errorf("status: %d %s", status, env.Data)
+	}
+}
+
+func TestAPIValidation(t *testing.T) {
+	a := newTestAPI(t)
+	h := a.routes()
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		want   int
+		code   string
+	}{
+		{"unknown field", "POST", "/v1/search", `{"dest": "PUJ", "nights": 3}`, 400, "usage"},
+		{"two values", "POST", "/v1/search", validSearch() + `{}`, 400, "usage"},
+		{"past dates", "POST", "/v1/search", `{"dest": "PUJ", "checkin": "01/01/2020", "checkout": "05/01/2020"}`, 400, "usage"},
+		{"free-text past dates", "POST", "/v1/search", `{"dest": "Punta Cana", "checkin": "01/01/2020", "checkout": "05/01/2020"}`, 400, "usage"},
+		{"negative rooms", "POST", "/v1/search", `{"dest": "PUJ", "checkin": "` + future(30) + `", "checkout": "` + future(37) + `", "rooms": -1}`, 400, "usage"},
+		{"negative adults", "POST", "/v1/search", `{"dest": "PUJ", "checkin": "` + future(30) + `", "checkout": "` + future(37) + `", "adults": -2}`, 400, "usage"},
+		{"off-site details", "POST", "/v1/hotels/details", `{"name": "X", "detail_url": "https://evil.example/hotel"}`, 400, "usage"},
+		{"inspect other host", "POST", "/v1/inspect", `{"url": "http://169.254.169.254/latest/meta-data/"}`, 400, "usage"},
+		{"inspect file url", "POST", "/v1/inspect", `{"url": "file:///etc/passwd"}`, 400, "usage"},
+		{"inspect job other host", "POST", "/v1/jobs", `{"kind": "inspect", "params": {"url": "http://localhost:8080/"}}`, 400, "usage"},
+		{"unknown kind", "POST", "/v1/jobs", `{"kind": "shell"}`, 400, "usage"},
+		{"unknown priority", "POST", "/v1/jobs", `{"kind": "keepalive", "priority": "urgent"}`, 400, "usage"},
+		{"unknown status", "GET", "/v1/jobs?status=lost", "", 400, "usage"},
+		{"unknown job", "GET", "/v1/jobs/0000", "", 404, "not_found"},
+		{"cancel unknown job", "DELETE", "/v1/jobs/0000", "", 404, "not_found"},
+	}
+	for _, tt := range tests {
+		status, env := call(t, h, authed(tt.method, tt.target, tt.body))
+		if status != tt.want || code(env) != tt.code {
+			t.Errorf("%s: %d %q, want %d %q", tt.name, status, code(env), tt.want, tt.code)
+		}
+	}
+	if jobs := a.wk.queue.List(""); len(jobs) != 0 {
+		t.Errorf("rejected requests queued %d jobs", len(jobs))
+	}
+}
+
+func TestAPIJobErrors(t *testing.T) {
+	a := newTestAPI(t)
+	h := a.routes()
+
+	// no worker runs: the request times out and its job is canceled
+	status, env := call(t, h, authed("POST", "/v1/search", validSearch()))
+	if status != http.StatusGatewayTimeout || code(env) != "timeout" {
+		t.Errorf("unserved search: %d %q, want 504 timeout", status, code(env))
+	}
+
+	// the client goes away: 499 and the job is canceled too
+	a.timeout = time.Minute
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	status, env = call(t, h, authed("POST", "/v1/search", validSearch()).WithContext(ctx))
+	if status != 499 || code(env) != "canceled" {
+		t.Errorf("disconnected search: %d %q, want 499 canceled", status, code(env))
+	}
+	for _, job := range a.wk.queue.List("") {
+		if job.Status != jobs.StatusCanceled {
+			t.Errorf("job %s of an abandoned request is %s, want canceled", job.ID, job.Status)
+		}
+	}
+
+	runCtx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go a.wk.queue.Run(runCtx, 1, func(ctx context.Context, worker int, job jobs.Job) (any, error) {
+		switch job.Kind {
+		case jobSearch:
+			return &LoginResult{Destination: "PUJ"}, nil
+		case jobKeepAlive:
+			return nil, &codedError{code: "login_invalid_credentials", err: errors.New("login invalid_credentials")}
+		}
+		return nil, fmt.Errorf("unexpected %s job", job.Kind)
+	})
+
+	status, env = call(t, h, authed("POST", "/v1/search", validSearch()))
+	var result LoginResult
+	json.Unmarshal(env.Data, &result)
+	if status != http.StatusOK || result.Destination != "PUJ" {
+		t.Errorf("served search: %d %s", status, env.Data)
+	}
+	status, env = call(t, h, authed("GET", "/v1/session?verify=true", ""))
+	if status != http.StatusBadGateway || code(env) != "login_invalid_credentials" {
+		t.Errorf("rejected login: %d %q, want 502 login_invalid_credentials", status, code(env))
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"ExpeditusClient/internal/browser"
+	"ExpeditusClient/internal/config"
+	"ExpeditusClient/internal/delfos"
+)
+
+// captchaHandoff hands the CAPTCHAs of a run to a human through the DevTools
+// port of config. In "login serve" the open challenges are listed on board
+// for the API. A nil handoff never waits for anyone.
+type captchaHandoff struct {
+	config config.HandoffConfig
+	board  *challengeBoard
+}
+
+// wait is the extra time a tab needs to wait for a human.
+func (h *captchaHandoff) wait() time.Duration {
+	if h == nil || h.config.DevToolsPort == 0 {
+		return 0
+	}
+	return h.config.Wait
+}
+
+// challengeStatus is a challenge waiting for a human.
+type challengeStatus struct {
+	delfos.Challenge
+	DevTools string    `json:"devtools"` // where to solve it
+	Since    time.Time `json:"since"`
+}
+
+// challengeBoard lists the open challenges for the API.
+type challengeBoard struct {
+	mu   sync.Mutex
+	open []challengeStatus
+}
+
+func (b *challengeBoard) add(c challengeStatus) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.open = append(b.open, c)
+}
+
+func (b *challengeBoard) remove(devTools string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.open = slices.DeleteFunc(b.open, func(c challengeStatus) bool { return c.DevTools == devTools })
+}
+
+func (b *challengeBoard) list() []challengeStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return slices.Clone(b.open)
+}
+
+// await hands the challenge on the tab's page to a human through DevTools and
+// waits until the page moves past it.
+func (h *captchaHandoff) await(ctx context.Context, events searchEvents) error {
+	c, err := delfos.DetectChallenge(ctx)
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		return nil // gone already, e.g. solved before we looked
+	}
+	if h == nil || h.config.DevToolsPort == 0 {
+		return fmt.Errorf("%s shows a %s challenge; set EXPEDITUS_DEVTOOLS_PORT to solve it by hand", c.URL, c.Kind)
+	}
+
+	status := challengeStatus{Challenge: *c, DevTools: browser.InspectURL(ctx, h.config.DevToolsPort), Since: time.Now().UTC()}
+	if h.board != nil {
+		h.board.add(status)
+		defer h.board.remove(status.DevTools)
+	}
+	fmt.Fprintf(os.Stderr, "Captcha: %s challenge on %s; solve it within %s at %s\n", c.Kind, c.URL, h.config.Wait, status.DevTools)
+	events.emit("progress", searchProgress{Stage: stageCaptcha, Message: status.DevTools})
+
+	if err := delfos.WaitChallengeSolved(ctx, c, h.config.Wait); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "Captcha: solved, resuming")
+	return nil
+}
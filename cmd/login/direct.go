@@ -0,0 +1,53 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"time"
+
+	"ExpeditusClient/internal/config"
+	"ExpeditusClient/internal/delfos"
+)
+
+// runDirect runs the login with the browserless client: it logs in over
+// plain HTTP, paced like the browser, and when p names a destination
+// searches hotels, keeping the first p.Limit hotels of the first results
+// page. The run is bounded by timeout (0 = defaultTimeout).
+func runDirect(ctx context.Context, cfg *config.LoginConfig, p searchParams, timeout time.Duration) (*LoginResult, error) {
+	browserCfg, err := browserConfig(cfg, config.HandoffConfig{})
+	if err != nil {
+		return nil, err
+	}
+	client, err := delfos.NewHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	client.Pace(browserCfg.Throttle)
+
+	ctx, cancel := context.WithTimeout(ctx, cmp.Or(timeout, defaultTimeout))
+	defer cancel()
+	outcome, err := client.Login(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := &LoginResult{Login: outcome, SessionID: client.SessionID(), URL: outcome.URL}
+	if p.Dest == "" {
+		return result, nil
+	}
+
+	req, err := buildSearchRequest(p.Dest, p.CheckIn, p.CheckOut, p.Trip, p.Occupancy, p.Rooms, p.Adults)
+	if err != nil {
+		return nil, err
+	}
+	if err := resolveDestination(ctx, &req, client.QueryDestinations); err != nil {
+		return nil, err
+	}
+	result.Destination = req.DestinationCode()
+	if result.Hotels, err = client.SearchHotels(ctx, req); err != nil {
+		return nil, err
+	}
+	if p.Limit > 0 && len(result.Hotels) > p.Limit {
+		result.Hotels = result.Hotels[:p.Limit]
+	}
+	return result, nil
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"ExpeditusClient/internal/config"
+	"ExpeditusClient/internal/delfos"
+	"ExpeditusClient/internal/history"
+	"ExpeditusClient/internal/money"
+	"ExpeditusClient/internal/output"
+)
+
+func openHistory() (*history.Store, error) {
+	dir, err := config.DataDir()
+	if err != nil {
+		return nil, err
+	}
+	store, err := history.Open(filepath.Join(dir, "history"))
+	if err != nil {
+		return nil, err
+	}
+	store.Logf = func(format string, args ...any) {
+		fmt.Fprintf(os.Stderr, "Warning: history: "+format+"\n", args...)
+	}
+	return store, nil
+}
+
+// recordHistory stores the search and every price found in r.
+func recordHistory(search history.Search, r *LoginResult) error {
+	store, err := openHistory()
+	if err != nil {
+		return err
+	}
+	_, err = store.Record(search, historyPrices(r))
+	return err
+}
+
+func historyPrices(r *LoginResult) []history.Price {
+	var prices []history.Price
+	add := func(kind, item, detail string, price money.Money) {
+		if !price.IsZero() {
+			prices = append(prices, history.Price{Kind: kind, Item: item, Detail: detail, Price: price})
+		}
+	}
+
+	for _, h := range r.Hotels {
+		add(history.KindHotel, h.Name, joinNonEmpty(" / ", h.RoomType, h.Board), h.TotalPrice)
+	}
+	for _, d := range r.Details {
+		for _, rate := range d.Rates {
+			add(history.KindRate, d.Hotel.Name, joinNonEmpty(" / ", rate.Room, rate.Board), rate.TotalPrice)
+		}
+	}
+	for _, it := range r.Itineraries {
+		add(history.KindItinerary, joinNonEmpty(" ", route(it.Segments), flights(it.Segments)), it.FareFamily, it.TotalPrice)
+	}
+	for _, p := range r.Packages {
+		if p.TotalPrice.IsZero() {
+			add(history.KindPackage, p.Title, "por persona", p.PricePerPerson)
+		} else {
+			add(history.KindPackage, p.Title, "", p.TotalPrice)
+		}
+	}
+	return prices
+}
+
+func joinNonEmpty(sep string, parts ...string) string {
+	var kept []string
+	for _, p := range parts {
+		if p != "" {
+			kept = append(kept, p)
+		}
+	}
+	return strings.Join(kept, sep)
+}
+
+// runHistory implements "login history": it lists recorded prices, their
+// trends with -trends, or the searches themselves with -searches, filtered by
+// item, destination and date range.
+func runHistory(args []string) int {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	item := fs.String("item", "", "Hotel name, route or package title (substring)")
+	dest := fs.String("dest", "", "Destination code or name (substring)")
+	kind := fs.String("kind", "", "Only hotel, rate, itinerary or package prices")
+	account := fs.String("account", "", "Only prices seen by this account")
+	since := fs.String("since", "", "Observed since a duration ago (7d, 12h) or a date (dd/mm/yyyy)")
+	until := fs.String("until", "", "Observed before a date (dd/mm/yyyy)")
+	checkIn := fs.String("checkin", "", "Only stays starting on this date (dd/mm/yyyy)")
+	limit := fs.Int("limit", 0, "Only the most recent prices (0 = all)")
+	trends := fs.Bool("trends", false, "Summarize first/last/min/max price per item instead of listing prices")
+	searches := fs.Bool("searches", false, "List the recorded searches instead of their prices (-item and -kind do not apply)")
+	formatFlag := fs.String("format", string(output.FormatTable), "Output format: json, ndjson, csv or table")
+	if err := fs.Parse(args); err != nil {
+		return output.ExitUsage
+	}
+
+	format, err := output.ParseFormat(*formatFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return output.ExitUsage
+	}
+	report := output.NewReport("login history")
+	report.Meta.Params = make(map[string]string)
+	fs.Visit(func(f *flag.Flag) { report.Meta.Params[f.Name] = f.Value.String() })
+	finish := func(code int) int {
+		if err := report.Write(os.Stdout, os.Stderr, format); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+			return output.ExitFailure
+		}
+		return code
+	}
+
+	q := history.Query{Item: *item, Destination: *dest, Kind: *kind, Account: *account, Limit: *limit}
+	if q.Since, err = parseSince(*since, time.Now()); err == nil && *until != "" {
+		q.Until, err = delfos.ParseDate(*until)
+	}
+	if err == nil && *checkIn != "" {
+		q.CheckIn, err = delfos.ParseDate(*checkIn)
+	}
+	if err == nil && *searches && *trends {
+		err = errors.New("-searches and -trends are mutually exclusive")
+	}
+	if err != nil {
+		report.Fail("usage", err)
+		return finish(output.ExitUsage)
+	}
+
+	store, err := openHistory()
+	if err != nil {
+		report.Fail("history", err)
+		return finish(output.ExitFailure)
+	}
+	if *searches {
+		found, err := store.Searches(q)
+		if err != nil {
+			report.Fail("history", err)
+			return finish(output.ExitFailure)
+		}
+		fillSearchesReport(report, found)
+		return finish(output.ExitOK)
+	}
+	prices, err := store.Prices(q)
+	if err != nil {
+		report.Fail("history", err)
+		return finish(output.ExitFailure)
+	}
+
+	if *trends {
+		fillTrendsReport(report, history.Trends(prices))
+	} else {
+		fillPricesReport(report, prices)
+	}
+	return finish(output.ExitOK)
+}
+
+// parseSince accepts "7d"-style day counts, Go durations ("12h") or a
+// dd/mm/yyyy date.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	t, err := delfos.ParseDate(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("-since: expected 7d, 12h or dd/mm/yyyy, got %q", s)
+	}
+	return t, nil
+}
+
+func fillPricesReport(report *output.Report, prices []history.Price) {
+	report.Data = prices
+	report.Table.Columns = []string{"observed_at", "kind", "item", "detail", "destination", "check_in", "check_out", "occupancy", "price", "currency", "account"}
+	for _, p := range prices {
+		report.Records = append(report.Records, output.Record{Kind: "price", Data: p})
+		report.Table.Rows = append(report.Table.Rows, []string{
+			p.At.Local().Format("2006-01-02 15:04"), p.Kind, p.Item, p.Detail, p.Destination,
+			dateCell(p.CheckIn), dateCell(p.CheckOut), p.Occupancy, p.Price.Decimal(), p.Price.Currency, p.Account,
+		})
+	}
+}
+
+func fillSearchesReport(report *output.Report, searches []history.Search) {
+	report.Data = searches
+	report.Table.Columns = []string{"at", "id", "trip_type", "origin", "destination", "check_in", "check_out", "occupancy", "results", "account"}
+	for _, s := range searches {
+		report.Records = append(report.Records, output.Record{Kind: "search", Data: s})
+		report.Table.Rows = append(report.Table.Rows, []string{
+			s.At.Local().Format("2006-01-02 15:04"), s.ID, s.TripType, s.Origin, s.Destination,
+			dateCell(s.CheckIn), dateCell(s.CheckOut), s.Occupancy, strconv.Itoa(s.Results), s.Account,
+		})
+	}
+}
+
+func fillTrendsReport(report *output.Report, trends []history.Trend) {
+	report.Data = trends
+	report.Table.Columns = []string{"kind", "item", "detail", "check_in", "occupancy", "observations", "first_seen", "last_seen", "first", "last", "min", "max", "change", "change_pct", "direction", "currency"}
+	for _, t := range trends {
+		report.Records = append(report.Records, output.Record{Kind: "trend", Data: t})
+		report.Table.Rows = append(report.Table.Rows, []string{
+			t.Kind, t.Item, t.Detail, dateCell(t.CheckIn), t.Occupancy, strconv.Itoa(t.Observations),
+			t.FirstSeen.Local().Format("2006-01-02 15:04"), t.LastSeen.Local().Format("2006-01-02 15:04"),
+			t.First.Decimal(), t.Last.Decimal(), t.Min.Decimal(), t.Max.Decimal(), t.Change.Decimal(),
+			strconv.FormatFloat(t.ChangePct, 'f', 1, 64), t.Direction(), t.Last.Currency,
+		})
+	}
+}
+
+func dateCell(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.DateOnly)
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"ExpeditusClient/internal/browser"
+	"ExpeditusClient/internal/config"
+	"ExpeditusClient/internal/delfos"
+	"ExpeditusClient/internal/inspector"
+	"ExpeditusClient/internal/jobs"
+	"ExpeditusClient/internal/watch"
+)
+
+// Kinds of queued jobs besides the search, watchlist, keepalive and logout job types.
+const (
+	jobDetails = "details"
+	jobInspect = "inspect"
+)
+
+// jobRetention is how long finished jobs stay queryable.
+const jobRetention = 7 * 24 * time.Hour
+
+// logoutTimeout bounds the logout run after a search or when the service
+// stops, once the caller's context may already be done.
+const logoutTimeout = time.Minute
+
+type watchlistParams struct {
+	Notify string `json:"notify,omitempty"` // default stdout
+	Limit  int    `json:"limit,omitempty"`
+}
+
+type inspectParams struct {
+	URL  string `json:"url"`
+	Wait string `json:"wait,omitempty"`
+}
+
+// workers runs the queued jobs of "login serve", each worker in its own
+// logged-in tab of the shared browser. Every browser job goes through the
+// queue, so bursts of requests wait there instead of opening more tabs.
+type workers struct {
+	cfg      *config.LoginConfig
+	handoff  *captchaHandoff
+	pool     *browser.Pool
+	queue    *jobs.Queue
+	sessions []*session
+	logger   *log.Logger
+
+	// challenges lists the CAPTCHAs waiting for a human in any session.
+	challenges challengeBoard
+
+	// exec runs a job in a worker's session; tests replace it to run
+	// without a browser.
+	exec func(ctx context.Context, sess *session, job jobs.Job) (any, error)
+
+	mu      sync.Mutex
+	streams map[string]searchEvents // observers of streamed searches by job ID
+}
+
+func newWorkers(n int, cfg *config.LoginConfig, handoff config.HandoffConfig, pool *browser.Pool, queue *jobs.Queue, logger *log.Logger) *workers {
+	wk := &workers{cfg: cfg, pool: pool, queue: queue, logger: logger, streams: make(map[string]searchEvents)}
+	wk.handoff = &captchaHandoff{config: handoff, board: &wk.challenges}
+	wk.exec = wk.runJob
+	for i := range n {
+		wk.sessions = append(wk.sessions, newSession(pool, cfg, wk.handoff, i+1))
+	}
+	return wk
+}
+
+func (wk *workers) run(ctx context.Context) {
+	wk.queue.Run(ctx, len(wk.sessions), wk.handle)
+}
+
+func (wk *workers) close() {
+	for _, s := range wk.sessions {
+		s.reset()
+	}
+}
+
+// logout ends the site session from sess's tab and clears the browser. The
+// tabs share cookies, so every worker logs in again before its next job.
+func (wk *workers) logout(ctx context.Context, sess *session) (delfos.LoginOutcome, error) {
+	var outcome delfos.LoginOutcome
+	err := sess.run(ctx, false, func(ctx context.Context) error {
+		var err error
+		outcome, err = delfos.Logout(ctx, wk.cfg.TargetURL)
+		return err
+	})
+	for _, s := range wk.sessions {
+		s.expire()
+	}
+	return outcome, err
+}
+
+// signOut logs out when the service stops, so a shared workstation is not
+// left with a live session.
+func (wk *workers) signOut() {
+	if !slices.ContainsFunc(wk.sessions, func(s *session) bool { return s.Status().LoggedIn }) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), logoutTimeout)
+	defer cancel()
+	if _, err := wk.logout(ctx, wk.sessions[0]); err != nil {
+		wk.logger.Printf("Warning: %v", err)
+		return
+	}
+	wk.logger.Printf("logged out")
+}
+
+func (wk *workers) statuses() []sessionStatus {
+	statuses := make([]sessionStatus, len(wk.sessions))
+	for i, s := range wk.sessions {
+		statuses[i] = s.Status()
+	}
+	return statuses
+}
+
+// submit queues a job; params is encoded as its JSON parameters.
+func (wk *workers) submit(id, kind string, priority int, params any) (jobs.Job, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return jobs.Job{}, err
+	}
+	return wk.queue.Submit(jobs.Job{ID: id, Kind: kind, Priority: priority, Params: data})
+}
+
+// wait waits for a job and turns a failed or canceled job into a
+// *jobFailure. The job is canceled when ctx ends first.
+func (wk *workers) wait(ctx context.Context, id string) (jobs.Job, error) {
+	job, err := wk.queue.Wait(ctx, id)
+	if err != nil {
+		wk.queue.Cancel(id)
+		return job, err
+	}
+	switch job.Status {
+	case jobs.StatusFailed:
+		code := job.ErrorCode
+		if code == "" {
+			code = "failed"
+		}
+		return job, &jobFailure{Code: code, Message: job.Error}
+	case jobs.StatusCanceled:
+		return job, &jobFailure{Code: "canceled", Message: "job canceled"}
+	}
+	return job, nil
+}
+
+// do submits a job, waits for it and decodes its result into result
+// (skipped when nil).
+func (wk *workers) do(ctx context.Context, kind string, priority int, params, result any) error {
+	job, err := wk.submit("", kind, priority, params)
+	if err != nil {
+		return err
+	}
+	if job, err = wk.wait(ctx, job.ID); err != nil {
+		return err
+	}
+	if result == nil || job.Result == nil {
+		return nil
+	}
+	return json.Unmarshal(job.Result, result)
+}
+
+// observe sends the events of the search job id to events until the
+// returned function is called. Register before submitting the job.
+func (wk *workers) observe(id string, events searchEvents) func() {
+	wk.mu.Lock()
+	defer wk.mu.Unlock()
+	wk.streams[id] = events
+	return func() {
+		wk.mu.Lock()
+		defer wk.mu.Unlock()
+		delete(wk.streams, id)
+	}
+}
+
+func (wk *workers) events(id string) searchEvents {
+	wk.mu.Lock()
+	defer wk.mu.Unlock()
+	return wk.streams[id]
+}
+
+func (wk *workers) handle(ctx context.Context, worker int, job jobs.Job) (any, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultJobTimeout)
+	defer cancel()
+	result, err := wk.exec(ctx, wk.sessions[worker-1], job)
+	if err != nil {
+		return nil, classify(ctx, err)
+	}
+	return result, nil
+}
+
+func (wk *workers) runJob(ctx context.Context, sess *session, job jobs.Job) (any, error) {
+	switch job.Kind {
+	case jobSearch:
+		var p searchParams
+		if err := json.Unmarshal(job.Params, &p); err != nil {
+			return nil, fmt.Errorf("job params: %w", err)
+		}
+		events := wk.events(job.ID)
+		search, record, err := buildSearch(wk.cfg, wk.handoff, p, time.Now(), events)
+		if err != nil {
+			return nil, err
+		}
+		result := &LoginResult{}
+		err = sess.do(ctx, func(ctx context.Context) error {
+			login := sess.Status().Login
+			events.emit("progress", searchProgress{Stage: stageLoggedIn, Message: login.UserName})
+			return withRetry(ctx, wk.cfg, wk.handoff, "search", events, func(ctx context.Context) error {
+				*result = LoginResult{Login: login}
+				return search(ctx, result)
+			})
+		})
+		if err != nil {
+			return nil, err
+		}
+		if result.Destination != "" {
+			record.Destination = result.Destination
+		}
+		if err := recordHistory(record, result); err != nil {
+			wk.logger.Printf("Warning: %v", err)
+		}
+		return result, nil
+
+	case jobDetails:
+		var h delfos.Hotel
+		if err := json.Unmarshal(job.Params, &h); err != nil {
+			return nil, fmt.Errorf("job params: %w", err)
+		}
+		result := &LoginResult{}
+		err := sess.do(ctx, func(ctx context.Context) error {
+			return withRetry(ctx, wk.cfg, wk.handoff, "details of "+h.Name, wk.events(job.ID), func(ctx context.Context) error {
+				detail, err := delfos.HotelDetails(ctx, h)
+				result.Login, result.Details = sess.Status().Login, []*delfos.HotelDetail{detail}
+				return err
+			})
+		})
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+
+	case jobWatchlist:
+		var p watchlistParams
+		if err := json.Unmarshal(job.Params, &p); err != nil {
+			return nil, fmt.Errorf("job params: %w", err)
+		}
+		return wk.checkWatchlist(ctx, sess, p)
+
+	case jobKeepAlive:
+		err := sess.do(ctx, func(ctx context.Context) error {
+			outcome, err := delfos.KeepAlive(ctx, wk.cfg.TargetURL)
+			if err == nil && outcome.Status != delfos.LoginSuccess {
+				err = &delfos.LoginError{Outcome: outcome}
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return sess.Status(), nil
+
+	case jobInspect:
+		var p inspectParams
+		if err := json.Unmarshal(job.Params, &p); err != nil {
+			return nil, fmt.Errorf("job params: %w", err)
+		}
+		return inspector.Inspect(ctx, wk.pool, p.URL, p.Wait)
+
+	case jobLogout:
+		return wk.logout(ctx, sess)
+	}
+	return nil, fmt.Errorf("unknown job kind %q", job.Kind)
+}
+
+func (wk *workers) checkWatchlist(ctx context.Context, sess *session, p watchlistParams) ([]watchCheck, error) {
+	if p.Notify == "" {
+		p.Notify = "stdout"
+	}
+	notifiers, err := buildNotifiers(p.Notify, config.LoadNotifyConfig(), os.Stdout)
+	if err != nil {
+		return nil, err
+	}
+	path, err := watchlistPath()
+	if err != nil {
+		return nil, err
+	}
+	watches, err := watch.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	store, err := openHistory()
+	if err != nil {
+		return nil, err
+	}
+	checks, active := pendingChecks(watches, time.Now())
+	if len(active) == 0 {
+		return checks, nil
+	}
+	err = sess.do(ctx, func(ctx context.Context) error {
+		var errs []error
+		for _, i := range active {
+			checkWatch(ctx, wk.cfg, wk.handoff, store, notifiers, p.Limit, &checks[i])
+			if checks[i].Error != "" {
+				errs = append(errs, fmt.Errorf("%s: %s", checks[i].Watch.Label(), checks[i].Error))
+			}
+		}
+		return errors.Join(errs...)
+	})
+	return checks, err
+}
+
+// codedError carries the error code stored with a failed job.
+type codedError struct {
+	code string
+	err  error
+}
+
+func (e *codedError) Error() string { return e.err.Error() }
+func (e *codedError) Unwrap() error { return e.err }
+func (e *codedError) Code() string  { return e.code }
+
+// classify tags a job error with the code reported by the API.
+func classify(ctx context.Context, err error) error {
+	_, code := exitCode(err)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		code = "timeout"
+	}
+	return &codedError{code: code, err: err}
+}
+
+// jobFailure is the error of a failed or canceled job.
+type jobFailure struct {
+	Code    string
+	Message string
+}
+
+func (e *jobFailure) Error() string { return e.Message }
+
+func (e *jobFailure) httpStatus() int {
+	switch e.Code {
+	case "timeout":
+		return http.StatusGatewayTimeout
+	case "canceled":
+		return http.StatusConflict
+	}
+	return http.StatusBadGateway
+}
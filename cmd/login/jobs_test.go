@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"ExpeditusClient/internal/config"
+	"ExpeditusClient/internal/delfos"
+	"ExpeditusClient/internal/jobs"
+)
+
+// fakeWorkers returns workers whose jobs run fakeJob instead of the browser:
+// "block" jobs wait for their context, keepalive jobs fail with a rejected
+// login and every other job succeeds. Started jobs are sent on started.
+func fakeWorkers(t *testing.T, n int, dir string) (*workers, <-chan jobs.Job) {
+	t.Helper()
+	queue, err := jobs.Open(dir, jobRetention)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wk := newWorkers(n, &config.LoginConfig{TargetURL: "https://www.delfos.tur.ar/"}, config.HandoffConfig{}, nil, queue, log.New(io.Discard, "", 0))
+	started := make(chan jobs.Job, 16)
+	wk.exec = func(ctx context.Context, sess *session, job jobs.Job) (any, error) {
+		job.Worker = sess.worker
+		started <- job
+		switch job.Kind {
+		case "block":
+			<-ctx.Done()
+			return nil, ctx.Err()
+		case jobKeepAlive:
+			return nil, &delfos.LoginError{Outcome: delfos.LoginOutcome{Status: delfos.LoginInvalidCredentials}}
+		}
+		return job.Kind, nil
+	}
+	return wk, started
+}
+
+func nextStarted(t *testing.T, started <-chan jobs.Job) jobs.Job {
+	t.Helper()
+	select {
+	case job := <-started:
+		return job
+	case <-time.After(5 * time.Second):
+		t.Fatal("no job started")
+		return jobs.Job{}
+	}
+}
+
+func TestWorkersPriority(t *testing.T) {
+	wk, started := fakeWorkers(t, 1, t.TempDir())
+	var ids []string
+	for _, spec := range []struct {
+		kind     string
+		priority int
+	}{
+		{jobWatchlist, jobs.PriorityBackground},
+		{jobWatchlist, jobs.PriorityBackground},
+		{jobSearch, jobs.PriorityInteractive},
+		{jobKeepAlive, jobs.PriorityInteractive},
+	} {
+		job, err := wk.submit("", spec.kind, spec.priority, struct{}{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, job.ID)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go wk.run(ctx)
+
+	var order []string
+	for range ids {
+		order = append(order, nextStarted(t, started).ID)
+	}
+	if want := []string{ids[2], ids[3], ids[0], ids[1]}; !slices.Equal(order, want) {
+		t.Errorf("run order = %v, want %v (interactive first, then by submission)", order, want)
+	}
+
+	job, err := wk.wait(ctx, ids[3])
+	var failure *jobFailure
+	if !errors.As(err, &failure) || failure.Code != "login_invalid_credentials" || job.Status != jobs.StatusFailed {
+		t.Errorf("rejected login: %s %v", job.Status, err)
+	}
+	if job, err := wk.wait(ctx, ids[1]); err != nil || job.Status != jobs.StatusSucceeded || string(job.Result) != `"watchlist"` {
+		t.Errorf("background job: %s %s %v", job.Status, job.Result, err)
+	}
+}
+
+func TestWorkersCancelAndShutdown(t *testing.T) {
+	dir := t.TempDir()
+	wk, started := fakeWorkers(t, 2, dir)
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		wk.run(ctx)
+	}()
+
+	canceled, err := wk.submit("", "block", jobs.PriorityInteractive, struct{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	running := nextStarted(t, started)
+	if running.ID != canceled.ID || running.Worker < 1 || running.Worker > 2 {
+		t.Fatalf("started %+v", running)
+	}
+	if _, err := wk.queue.Cancel(canceled.ID); err != nil {
+		t.Fatal(err)
+	}
+	if job, err := wk.wait(context.Background(), canceled.ID); job.Status != jobs.StatusCanceled || err == nil {
+		t.Errorf("canceled while running: %s %v", job.Status, err)
+	}
+
+	interrupted, err := wk.submit("", "block", jobs.PriorityBackground, struct{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nextStarted(t, started)
+	cancel()
+	wg.Wait()
+
+	if job, _ := wk.queue.Get(interrupted.ID); job.Status != jobs.StatusQueued {
+		t.Errorf("job interrupted by shutdown is %s, want queued", job.Status)
+	}
+	reopened, err := jobs.Open(dir, jobRetention)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job, _ := reopened.Get(interrupted.ID); job.Status != jobs.StatusQueued {
+		t.Errorf("job after restart is %s, want queued", job.Status)
+	}
+	if job, _ := reopened.Get(canceled.ID); job.Status != jobs.StatusCanceled {
+		t.Errorf("canceled job after restart is %s", job.Status)
+	}
+}
@@ -1,326 +1,624 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"ExpeditusClient/internal/browser"
 	"ExpeditusClient/internal/config"
+	"ExpeditusClient/internal/delfos"
+	"ExpeditusClient/internal/history"
+	"ExpeditusClient/internal/output"
+	"ExpeditusClient/internal/ratelimit"
 
 	"github.com/chromedp/chromedp"
 )
 
 const (
-	searchURL      = "https://www.delfos.tur.ar/home?directSubmit=true&latestSearch=true&tripType=ONLY_HOTEL&&departureDate=09/05/2026&arrivalDate=23/05/2026&hotelDestination=Destination::AUA"
 	defaultTimeout = 60 * time.Second
 )
 
+// Time allowed per unit of work when sizing the deadline of a run; each is
+// stretched by the retry policy (see runTimeout).
+const (
+	searchBudget = 3 * time.Minute  // destination lookup, search and first results page
+	pageBudget   = 45 * time.Second // each further results page
+	detailBudget = 90 * time.Second // each hotel opened for -details
+)
+
 type LoginResult struct {
-	SessionID string
-	URL       string
-	HotelName string
-	Price     string
-	Debug     string
+	Login       delfos.LoginOutcome   `json:"login"`
+	SessionID   string                `json:"session_id,omitempty"`
+	URL         string                `json:"url,omitempty"`
+	Hotels      []delfos.Hotel        `json:"hotels,omitempty"`
+	Details     []*delfos.HotelDetail `json:"details,omitempty"`
+	Itineraries []delfos.Itinerary    `json:"itineraries,omitempty"`
+	Packages    []delfos.PackageOffer `json:"packages,omitempty"`
+	Destination string                `json:"destination,omitempty"` // resolved destination code of hotel searches
+}
+
+// searchFunc runs a search in a logged-in browser and stores what it finds in result.
+type searchFunc func(ctx context.Context, result *LoginResult) error
+
+// searchEvents observes a running search: "progress" events carry a
+// searchProgress, and "hotel", "rate", "itinerary" and "package" events every
+// item as soon as it is found. A nil searchEvents ignores them.
+type searchEvents func(kind string, data any)
+
+func (e searchEvents) emit(kind string, data any) {
+	if e != nil {
+		e(kind, data)
+	}
+}
+
+// Stages reported in progress events.
+const (
+	stageQueued      = "queued"
+	stageLoggedIn    = "logged_in"
+	stageDestination = "destination_resolved"
+	stageLoading     = "results_loading"
+	stagePage        = "page"
+	stageDetails     = "details"
+	stageRetry       = "retry"   // a failed step starts over; its items are sent again
+	stageCaptcha     = "captcha" // waiting for a human; the message is the DevTools URL
+)
+
+type searchProgress struct {
+	Stage   string `json:"stage"`
+	Page    int    `json:"page,omitempty"`
+	Count   int    `json:"count,omitempty"` // items found so far
+	Message string `json:"message,omitempty"`
 }
 
 func main() {
+	os.Exit(run())
+}
+
+func run() int {
+	if err := loadRetryPolicy(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return output.ExitUsage
+	}
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "history":
+			return runHistory(os.Args[2:])
+		case "watch":
+			return runWatch(os.Args[2:])
+		case "serve":
+			return runServe(os.Args[2:])
+		}
+	}
+
 	debug := flag.Bool("debug", false, "Run in debug mode to analyze page structure")
+	dest := flag.String("dest", "", "Destination code (AUA, Destination::AUA) or name to resolve (Miami)")
+	origin := flag.String("origin", "", "Origin airport or city code for flight searches (EZE)")
+	checkIn := flag.String("checkin", "", "Check-in date, or departure date for flights (dd/mm/yyyy)")
+	checkOut := flag.String("checkout", "", "Check-out date, or return date for flights; empty for one-way (dd/mm/yyyy)")
+	tripType := flag.String("trip", string(delfos.TripOnlyHotel), "Trip type: ONLY_HOTEL, ONLY_FLIGHT or FLIGHT_HOTEL")
+	cabin := flag.String("cabin", string(delfos.CabinEconomy), "Cabin for flight searches: ECONOMY, PREMIUM_ECONOMY, BUSINESS, FIRST")
+	rooms := flag.Int("rooms", 1, "Number of rooms")
+	adults := flag.Int("adults", 2, "Adults per room")
+	occupancy := flag.String("occupancy", "", "Per-room occupancy, e.g. \"2;2:5,8\" (overrides -rooms/-adults)")
+	limit := flag.Int("limit", 0, "Maximum number of hotels to collect (0 = all pages)")
+	details := flag.Bool("details", false, "Open each hotel and extract its room rates and cancellation policies")
+	featured := flag.Bool("featured", false, "List the packages featured on the home page instead of searching")
+	formatFlag := flag.String("format", string(output.FormatTable), "Output format: json, ndjson, csv or table")
+	noHistory := flag.Bool("no-history", false, "Do not record the search and its prices in the price history")
+	logout := flag.Bool("logout", false, "Log out and clear the browser's cookies and storage when done")
+	direct := flag.Bool("direct", false, "Log in over plain HTTP without a browser; with -dest, also search hotels (first results page only)")
+	timeout := flag.Duration("timeout", 0, "Deadline for the whole run (0 = sized from -limit and -details)")
 	flag.Parse()
 
+	format, err := output.ParseFormat(*formatFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return output.ExitUsage
+	}
+	report := output.NewReport("login")
+	report.Meta.Params = flagParams()
+	finish := func(code int) int {
+		if err := report.Write(os.Stdout, os.Stderr, format); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+			return output.ExitFailure
+		}
+		return code
+	}
+
 	ctx := context.Background()
 
 	cfg, err := config.LoadLoginConfig()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
-		os.Exit(1)
+		report.Fail("config", fmt.Errorf("load config: %w", err))
+		return finish(output.ExitFailure)
 	}
 
-	browserCfg := browser.DefaultConfig()
-	browserCfg.Timeout = defaultTimeout
-	browserCfg.Headless = !*debug
-
-	pool, err := browser.NewPool(ctx, browserCfg)
+	handoffCfg, err := config.LoadHandoffConfig()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating browser pool: %v\n", err)
-		os.Exit(1)
+		report.Fail("config", err)
+		return finish(output.ExitFailure)
 	}
-	defer pool.Close()
+	handoff := &captchaHandoff{config: handoffCfg}
 
 	if *debug {
-		runDebug(ctx, pool, cfg.TargetURL)
-		return
+		return runDebugMode(ctx, cfg, handoffCfg, *timeout)
 	}
 
-	result, err := runLogin(ctx, pool, cfg)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+	params := searchParams{
+		Trip: *tripType, Origin: *origin, Dest: *dest, CheckIn: *checkIn, CheckOut: *checkOut,
+		Cabin: *cabin, Occupancy: *occupancy, Rooms: *rooms, Adults: *adults,
+		Limit: *limit, Details: *details, Featured: *featured,
 	}
 
-	printResult(result)
-}
+	if *direct {
+		if *featured || *details || delfos.TripType(*tripType) != delfos.TripOnlyHotel {
+			report.Fail("usage", errors.New("-direct only searches hotels, without -details or -featured"))
+			return finish(output.ExitUsage)
+		}
+		var record history.Search
+		if *dest != "" {
+			if _, record, err = buildSearch(cfg, nil, params, time.Now(), nil); err != nil {
+				report.Fail("usage", fmt.Errorf("invalid search: %w", err))
+				return finish(output.ExitUsage)
+			}
+		}
+		result, err := runDirect(ctx, cfg, params, *timeout)
+		if err != nil {
+			code, name := exitCode(err)
+			report.Fail(name, err)
+			return finish(code)
+		}
+		if *dest != "" && !*noHistory {
+			record.Destination = result.Destination
+			if err := recordHistory(record, result); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}
+		fillReport(report, result)
+		if format == output.FormatTable {
+			printSummary(result)
+		}
+		return finish(output.ExitOK)
+	}
 
-func runLogin(ctx context.Context, pool *browser.Pool, cfg *config.LoginConfig) (*LoginResult, error) {
-	browserCtx, cancel := pool.NewContext(ctx)
-	defer cancel()
+	search, record, err := buildSearch(cfg, handoff, params, time.Now(), nil)
+	if err != nil {
+		report.Fail("usage", fmt.Errorf("invalid search: %w", err))
+		return finish(output.ExitUsage)
+	}
 
-	var sessionID, currentURL string
-	var debugLog string
+	browserCfg, err := browserConfig(cfg, handoffCfg)
+	if err != nil {
+		report.Fail("config", err)
+		return finish(output.ExitFailure)
+	}
+	browserCfg.Timeout = *timeout
+	if browserCfg.Timeout == 0 {
+		browserCfg.Timeout = runTimeout(1, *limit, *details, handoff.wait())
+	}
 
-	err := chromedp.Run(browserCtx,
-		chromedp.Navigate(cfg.TargetURL),
-		chromedp.WaitReady("body", chromedp.ByQuery),
-		chromedp.Sleep(2*time.Second),
-	)
+	pool, err := browser.NewPool(ctx, browserCfg)
 	if err != nil {
-		return nil, fmt.Errorf("navigation failed: %w", err)
+		report.Fail("browser", fmt.Errorf("create browser pool: %w", err))
+		return finish(output.ExitFailure)
 	}
+	defer pool.Close()
 
-	err = chromedp.Run(browserCtx,
-		chromedp.Evaluate(`(() => {
-			// ANCHOR: Find "Entrar" by exact text match
-			const links = document.querySelectorAll('a, button');
-			for (const link of links) {
-				if (link.textContent?.trim().toLowerCase() === 'entrar') {
-					link.click();
-					return 'clicked-entrar';
-				}
-			}
-			return 'not-found';
-		})()`, nil),
-		chromedp.Sleep(2*time.Second),
-	)
+	result, err := runLogin(ctx, pool, cfg, handoff, *logout, search)
 	if err != nil {
-		return nil, fmt.Errorf("click entrar failed: %w", err)
+		code, name := exitCode(err)
+		report.Fail(name, err)
+		return finish(code)
 	}
 
-	fillScript := fmt.Sprintf(`(() => {
-		const debug = [];
-		
-		let loginForm = null;
-		const forms = document.querySelectorAll('form');
-		for (const form of forms) {
-			const hasPassword = form.querySelector('input[type="password"]');
-			const hasEmail = form.querySelector('input[type="text"]');
-			if (hasPassword && hasEmail) {
-				loginForm = form;
-				debug.push('Found login form');
-				break;
-			}
+	if !*noHistory {
+		if result.Destination != "" {
+			record.Destination = result.Destination
 		}
-		
-		const searchRoot = loginForm || document;
-		
-		let emailInput = null;
-		let passwordInput = null;
-		let submitButton = null;
-		
-		const passwordInputs = searchRoot.querySelectorAll('input[type="password"]');
-		if (passwordInputs.length > 0) {
-			passwordInput = passwordInputs[0];
-			debug.push('Found password: ' + passwordInput.id);
+		if err := recordHistory(record, result); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
 		}
-		
-		const textInputs = searchRoot.querySelectorAll('input[type="text"]');
-		for (const input of textInputs) {
-			const id = (input.id || '').toLowerCase();
-			const name = (input.name || '').toLowerCase();
-			
-			if (id.includes('email') || name.includes('email')) {
-				emailInput = input;
-				debug.push('Found email by id/name: ' + input.id);
-				break;
-			}
+	}
+
+	fillReport(report, result)
+	if format == output.FormatTable {
+		printSummary(result)
+	}
+	return finish(output.ExitOK)
+}
+
+// runTimeout sizes the deadline of a run that logs in and then runs searches
+// hotel searches of up to limit hotels each (0 = every page), opening each hotel
+// when details is set. Every step gets the time of its retries, and the run
+// captchaWait, the time a human has to clear a CAPTCHA. With details and no limit the
+// hotel count is unknown, so the run has no deadline (0); each hotel is still
+// bounded by detailTimeout.
+func runTimeout(searches, limit int, details bool, captchaWait time.Duration) time.Duration {
+	if details && limit == 0 {
+		return 0
+	}
+	pages := delfos.DefaultMaxPages
+	if limit > 0 {
+		pages = min(limit, pages) // at least one new hotel per page
+	}
+	search := retryPolicy.Budget(searchBudget + time.Duration(pages-1)*pageBudget)
+	if details {
+		search += time.Duration(limit) * detailTimeout(pages, captchaWait)
+	}
+	return retryPolicy.Budget(defaultTimeout) + time.Duration(searches)*search + captchaWait
+}
+
+// detailTimeout bounds opening one hotel listed on results page page for
+// -details, retries and a CAPTCHA included. Cards without a link need the
+// results paged through again first.
+func detailTimeout(page int, captchaWait time.Duration) time.Duration {
+	return retryPolicy.Budget(detailBudget+time.Duration(page-1)*pageBudget) + captchaWait
+}
+
+// searchParams are the search flags of the login command, also used by the
+// search jobs of "login serve".
+type searchParams struct {
+	Trip      string `json:"trip,omitempty"`
+	Origin    string `json:"origin,omitempty"`
+	Dest      string `json:"dest,omitempty"`
+	CheckIn   string `json:"checkin,omitempty"`
+	CheckOut  string `json:"checkout,omitempty"`
+	Cabin     string `json:"cabin,omitempty"`
+	Occupancy string `json:"occupancy,omitempty"`
+	Rooms     int    `json:"rooms,omitempty"`
+	Adults    int    `json:"adults,omitempty"`
+	Limit     int    `json:"limit,omitempty"`
+	Details   bool   `json:"details,omitempty"`
+	Featured  bool   `json:"featured,omitempty"`
+}
+
+// buildSearch validates p and returns the search to run once logged in and
+// the history record describing it.
+func buildSearch(cfg *config.LoginConfig, handoff *captchaHandoff, p searchParams, today time.Time, events searchEvents) (searchFunc, history.Search, error) {
+	record := history.Search{Account: cfg.Username, TripType: p.Trip, Occupancy: p.Occupancy}
+	if p.Featured {
+		record.TripType = "FEATURED"
+		return featuredPackages(cfg, events), record, nil
+	}
+
+	var search searchFunc
+	var err error
+	switch delfos.TripType(p.Trip) {
+	case delfos.TripOnlyFlight:
+		var req delfos.FlightSearchRequest
+		req, err = buildFlightRequest(p.Origin, p.Dest, p.CheckIn, p.CheckOut, p.Cabin, p.Occupancy, p.Adults)
+		if err == nil {
+			err = req.Validate(today)
 		}
-		
-		if (!emailInput && loginForm) {
-			for (const input of textInputs) {
-				const placeholder = (input.placeholder || '').toLowerCase();
-				const id = (input.id || '').toLowerCase();
-				if (placeholder === '...' && id.includes('login')) {
-					emailInput = input;
-					debug.push('Found email by placeholder in login form: ' + input.id);
-					break;
-				}
-			}
+		search = searchFlights(cfg, req, events)
+		record.Origin, record.Destination, record.CheckIn, record.CheckOut = req.Origin, req.Destination, req.Departure, req.Return
+	case delfos.TripFlightHotel:
+		var req delfos.PackageSearchRequest
+		req, err = buildPackageRequest(p.Origin, p.Dest, p.CheckIn, p.CheckOut, p.Occupancy, p.Rooms, p.Adults)
+		if err == nil {
+			err = req.Validate(today)
 		}
-		
-		const allButtons = searchRoot.querySelectorAll('button, input[type="submit"], [role="button"]');
-		for (const btn of allButtons) {
-			const text = (btn.textContent || btn.value || '').toLowerCase().trim();
-			if (text === 'iniciar sesión' || text === 'iniciar') {
-				submitButton = btn;
-				debug.push('Found submit button: ' + text + ' tag:' + btn.tagName);
-				break;
-			}
+		search = searchPackages(cfg, req, events)
+		record.Origin, record.Destination, record.CheckIn, record.CheckOut = req.Origin, req.Destination, req.Departure, req.Return
+		record.Occupancy = delfos.FormatOccupancy(req.Occupancy)
+	default:
+		var req delfos.SearchRequest
+		req, err = buildSearchRequest(p.Dest, p.CheckIn, p.CheckOut, p.Trip, p.Occupancy, p.Rooms, p.Adults)
+		if err == nil {
+			// free-text destinations are checked again once resolved
+			err = req.ValidateStay(today)
 		}
-		
-		if (!submitButton) {
-			for (const btn of allButtons) {
-				const btnType = (btn.type || '').toLowerCase();
-				if (btnType === 'submit') {
-					submitButton = btn;
-					debug.push('Found submit button by type: ' + btn.tagName);
-					break;
-				}
-			}
+		search = searchHotels(cfg, handoff, req, delfos.ResultsOptions{Limit: p.Limit}, p.Details, events)
+		record.Destination, record.CheckIn, record.CheckOut = req.Destination, req.CheckIn, req.CheckOut
+		record.Occupancy = delfos.FormatOccupancy(req.Occupancy)
+	}
+	return search, record, err
+}
+
+// flagParams returns the flags set on the command line for the run metadata.
+func flagParams() map[string]string {
+	params := make(map[string]string)
+	flag.Visit(func(f *flag.Flag) {
+		params[f.Name] = f.Value.String()
+	})
+	return params
+}
+
+func buildSearchRequest(dest, checkIn, checkOut, tripType, occupancy string, rooms, adults int) (delfos.SearchRequest, error) {
+	req := delfos.SearchRequest{
+		Destination: dest,
+		TripType:    delfos.TripType(tripType),
+	}
+
+	var err error
+	if occupancy != "" {
+		if req.Occupancy, err = delfos.ParseOccupancy(occupancy); err != nil {
+			return req, fmt.Errorf("-occupancy: %w", err)
 		}
-		
-		if (!emailInput) {
-			debug.push('ERROR: Email not found');
-		} else {
-			emailInput.value = '%s';
-			emailInput.dispatchEvent(new Event('input', { bubbles: true }));
-			emailInput.dispatchEvent(new Event('change', { bubbles: true }));
-			debug.push('Filled email OK');
+	} else if req.Occupancy, err = delfos.UniformOccupancy(rooms, adults); err != nil {
+		return req, fmt.Errorf("-rooms/-adults: %w", err)
+	}
+	if req.CheckIn, err = delfos.ParseDate(checkIn); err != nil {
+		return req, fmt.Errorf("-checkin: %w", err)
+	}
+	if req.CheckOut, err = delfos.ParseDate(checkOut); err != nil {
+		return req, fmt.Errorf("-checkout: %w", err)
+	}
+
+	return req, nil
+}
+
+func buildFlightRequest(origin, dest, departure, ret, cabin, occupancy string, adults int) (delfos.FlightSearchRequest, error) {
+	req := delfos.FlightSearchRequest{
+		Origin:      origin,
+		Destination: dest,
+		Passengers:  delfos.Room{Adults: adults},
+		Cabin:       delfos.Cabin(strings.ToUpper(cabin)),
+	}
+
+	var err error
+	if occupancy != "" {
+		rooms, err := delfos.ParseOccupancy(occupancy)
+		if err != nil {
+			return req, fmt.Errorf("-occupancy: %w", err)
 		}
-		
-		if (!passwordInput) {
-			debug.push('ERROR: Password not found');
-		} else {
-			passwordInput.value = '%s';
-			passwordInput.dispatchEvent(new Event('input', { bubbles: true }));
-			passwordInput.dispatchEvent(new Event('change', { bubbles: true }));
-			debug.push('Filled password OK');
+		if len(rooms) != 1 {
+			return req, fmt.Errorf("-occupancy: flight searches take a single passenger group")
 		}
-		
-		if (submitButton) {
-			submitButton.click();
-			debug.push('Clicked submit button');
+		req.Passengers = rooms[0]
+	}
+	if req.Departure, err = delfos.ParseDate(departure); err != nil {
+		return req, fmt.Errorf("-checkin: %w", err)
+	}
+	if ret != "" {
+		if req.Return, err = delfos.ParseDate(ret); err != nil {
+			return req, fmt.Errorf("-checkout: %w", err)
+		}
+	}
+
+	return req, nil
+}
+
+func buildPackageRequest(origin, dest, departure, ret, occupancy string, rooms, adults int) (delfos.PackageSearchRequest, error) {
+	hotel, err := buildSearchRequest(dest, departure, ret, string(delfos.TripFlightHotel), occupancy, rooms, adults)
+	return delfos.PackageSearchRequest{
+		Origin:      origin,
+		Destination: hotel.Destination,
+		Departure:   hotel.CheckIn,
+		Return:      hotel.CheckOut,
+		Occupancy:   hotel.Occupancy,
+	}, err
+}
+
+// resolveDestination replaces a free-text destination with the best
+// candidate of source, the site's autocomplete, using the on-disk cache when
+// available.
+func resolveDestination(ctx context.Context, req *delfos.SearchRequest, source delfos.DestinationSource) error {
+	if delfos.IsDestinationCode(req.Destination) {
+		return nil
+	}
+
+	resolver := &delfos.Resolver{Source: source}
+	if dir, err := config.CacheDir(); err == nil {
+		cache, err := delfos.LoadDestinationCache(filepath.Join(dir, "destinations.json"), 30*24*time.Hour)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
 		} else {
-			if (loginForm) {
-				loginForm.submit();
-				debug.push('Submitted form (last resort)');
-			} else {
-				debug.push('ERROR: No submit method');
-			}
+			resolver.Cache = cache
 		}
-		
-		return debug.join(' | ');
-	})()`, cfg.Username, cfg.Password)
+	}
 
-	var extractResult map[string]interface{}
-	err = chromedp.Run(browserCtx,
-		chromedp.Evaluate(fillScript, &debugLog),
-		chromedp.Sleep(4*time.Second),
-		chromedp.Location(&currentURL),
-		chromedp.Evaluate(`document.cookie.match(/JSESSIONID=([^;]+)/)?.[1] || ''`, &sessionID),
-		chromedp.Evaluate(`(() => {
-			return {
-				url: window.location.href,
-				title: document.title,
-				hasError: document.body.textContent.includes('incorrecta') || 
-					document.body.textContent.includes('inválido') ||
-					document.body.textContent.includes('error'),
-				bodyText: document.body.innerText.substring(0, 500)
-			};
-		})()`, &extractResult),
-	)
+	candidates, err := resolver.Resolve(ctx, req.Destination)
+	if err != nil && len(candidates) == 0 {
+		return err
+	}
+	if len(candidates) == 0 {
+		return fmt.Errorf("no destination matches %q", req.Destination)
+	}
+
+	best := candidates[0]
+	fmt.Fprintf(os.Stderr, "Destination %q resolved to %s (%s, %s)\n", req.Destination, best.Name, best.Code, best.Type)
+	req.Destination = best.Code
+	req.DestinationName = best.Name
+	return req.ValidateDestination()
+}
+
+// browserConfig is the default browser configuration with the supplier rate
+// limits applied to every tab, logging each refusal to stderr, and DevTools
+// open on the port of handoff.
+func browserConfig(cfg *config.LoginConfig, handoff config.HandoffConfig) (browser.Config, error) {
+	browserCfg := browser.DefaultConfig()
+	limits, err := config.LoadRateLimitConfig()
+	if err != nil {
+		return browserCfg, err
+	}
+	site, err := url.Parse(cfg.TargetURL)
 	if err != nil {
-		return nil, fmt.Errorf("login fill failed: %w", err)
+		return browserCfg, fmt.Errorf("parse DELFOS_URL: %w", err)
 	}
+	pacer := ratelimit.New(limits).Account(cfg.Username)
+	pacer.OnThrottle = func(pause time.Duration) {
+		fmt.Fprintf(os.Stderr, "Warning: %s is refusing requests; pausing %s\n", site.Host, pause.Round(time.Second))
+	}
+	browserCfg.Throttle, browserCfg.ThrottleHost = pacer, site.Host
+
+	browserCfg.DevToolsPort = handoff.DevToolsPort
+	return browserCfg, nil
+}
 
+// runLogin logs in and runs search in a new tab. With logout, the session is
+// ended and the browser cleared afterwards, even when the search failed.
+func runLogin(ctx context.Context, pool *browser.Pool, cfg *config.LoginConfig, handoff *captchaHandoff, logout bool, search searchFunc) (*LoginResult, error) {
+	browserCtx, cancel := pool.NewContext(ctx)
+	defer cancel()
+	if logout {
+		defer func() {
+			// the search may have used up ctx's deadline or been interrupted
+			logoutCtx, cancel := context.WithTimeout(context.WithoutCancel(browserCtx), logoutTimeout)
+			defer cancel()
+			if _, err := delfos.Logout(logoutCtx, cfg.TargetURL); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}()
+	}
+
+	outcome, err := loginWithRetry(browserCtx, cfg, handoff)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &LoginResult{Login: outcome}
 	err = chromedp.Run(browserCtx,
-		chromedp.Navigate(searchURL),
-		chromedp.WaitReady("body", chromedp.ByQuery),
-		chromedp.Sleep(5*time.Second),
-		chromedp.Location(&currentURL),
+		chromedp.Evaluate(`document.cookie.match(/JSESSIONID=([^;]+)/)?.[1] || ''`, &result.SessionID),
 	)
 	if err != nil {
-		return nil, fmt.Errorf("search navigation failed: %w", err)
+		return nil, fmt.Errorf("read session cookie: %w", err)
 	}
 
-	// Wait for results and extract
-	hotelData := extractHotels(browserCtx)
-	return parseResult(sessionID, currentURL, hotelData, debugLog), nil
+	err = withRetry(browserCtx, cfg, handoff, "search", nil, func(ctx context.Context) error {
+		*result = LoginResult{Login: result.Login, SessionID: result.SessionID}
+		return search(ctx, result)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
-func extractHotels(ctx context.Context) map[string]interface{} {
-	var result map[string]interface{}
-	chromedp.Run(ctx,
-		chromedp.Evaluate(buildExtractScript(), &result),
-	)
-	return result
-}
+func searchHotels(cfg *config.LoginConfig, handoff *captchaHandoff, req delfos.SearchRequest, opts delfos.ResultsOptions, withDetails bool, events searchEvents) searchFunc {
+	return func(ctx context.Context, result *LoginResult) error {
+		if err := resolveDestination(ctx, &req, delfos.QueryDestinations); err != nil {
+			return err
+		}
 
-func buildExtractScript() string {
-	return `(() => {
-		try {
-			const cookies = document.cookie;
-			const url = window.location.href;
-			const allText = document.body.innerText;
-			
-			// ANCHOR: Find first price from "Total:" line (first hotel result)
-			const firstTotalMatch = allText.match(/Total: US\$[\d,.]+/);
-			let finalPrice = null;
-			if (firstTotalMatch) {
-				const priceInLine = firstTotalMatch[0].match(/US\$[\d,.]+/);
-				finalPrice = priceInLine ? priceInLine[0] : null;
-			}
-			
-			// ANCHOR: Find FIRST occurrence of any known hotel brand name
-			// Look for specific known hotel names that come BEFORE or near the first price
-			const knownHotels = [
-				'Radisson Blu Aruba', 'Radisson Blu', 'Radisson',
-				'Hilton Aruba', 'Hilton',
-				'Marriott',
-				'Casa del Mar', 'Casa del Mar Aruba',
-				'Boardwalk', 'Boardwalk Aruba',
-				'ECLIPSE', 'Divi Aruba', 'Divi',
-				'Renaissance Aruba', 'Renaissance',
-				'Embassy Suites', 'Embassy Aruba',
-				'Holiday Inn Aruba', 'Holiday Inn',
-				'Gold Coast', 'Tamarijn'
-			];
-			
-			let hotelName = null;
-			for (const hotel of knownHotels) {
-				if (allText.includes(hotel)) {
-					hotelName = hotel;
-					break;
-				}
+		result.Destination = req.DestinationCode()
+		events.emit("progress", searchProgress{Stage: stageDestination, Message: result.Destination})
+
+		events.emit("progress", searchProgress{Stage: stageLoading})
+		if err := delfos.Search(ctx, cfg.TargetURL, req); err != nil {
+			return err
+		}
+		if err := chromedp.Run(ctx, chromedp.Location(&result.URL)); err != nil {
+			return fmt.Errorf("read results url: %w", err)
+		}
+
+		lastPage := 0
+		var pages []int // results page each hotel was first seen on
+		err := delfos.IterateHotels(ctx, opts, func(page int, h delfos.Hotel) error {
+			if page != lastPage {
+				lastPage = page
+				events.emit("progress", searchProgress{Stage: stagePage, Page: page, Count: len(result.Hotels)})
 			}
-			
-			// Fallback: look for any line with brand name
-			if (!hotelName) {
-				const lines = allText.split(/[\n\r]+/);
-				const brandNames = ['radisson', 'hilton', 'marriott', 'hyatt', 'sheraton', 'barcelo', 'melia', 'eagle', 'paradise', 'victoria', 'imperial'];
-				
-				for (const line of lines) {
-					const lower = line.toLowerCase();
-					const hasBrand = brandNames.some(b => lower.includes(b));
-					// Skip if it's a provider or category
-					if (hasBrand && !lower.includes('group') && !lower.includes('beds') && 
-						!lower.includes('choice') && !lower.includes('hotelbeds') && line.length > 6 && line.length < 50) {
-						hotelName = line.trim();
-						break;
+			pages = append(pages, page)
+			result.Hotels = append(result.Hotels, h)
+			events.emit("hotel", h)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if !withDetails {
+			return nil
+		}
+		events.emit("progress", searchProgress{Stage: stageDetails, Count: len(result.Hotels)})
+		for i, h := range result.Hotels {
+			var detail *delfos.HotelDetail
+			detailCtx, cancel := context.WithTimeout(ctx, detailTimeout(pages[i], handoff.wait()))
+			err := withRetry(detailCtx, cfg, handoff, "details of "+h.Name, events, func(ctx context.Context) error {
+				if h.DetailURL == "" {
+					// cards without a link are opened by clicking them, on the
+					// results page they were first listed on
+					if err := delfos.ReturnToResults(ctx, result.URL, pages[i], opts); err != nil {
+						return err
 					}
 				}
+				var err error
+				detail, err = delfos.HotelDetails(ctx, h)
+				return err
+			})
+			cancel()
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+				continue
+			}
+			result.Details = append(result.Details, detail)
+			for _, rate := range detail.Rates {
+				events.emit("rate", hotelRate{h.Name, rate})
 			}
-			
-			// Fallback prices
-			const allPriceMatches = allText.match(/US?\$[\d,.]+/g) || [];
-			const cleanPrices = [...new Set(allPriceMatches)].filter(p => p.length > 2 && p.length < 15);
-			
-			return { 
-				cookies: cookies,
-				url: url,
-				name: hotelName || 'Not found', 
-				price: finalPrice || cleanPrices[0] || 'Not found'
-			};
-		} catch(e) {
-			return { 
-				cookies: '',
-				name: 'Error: ' + e.message, 
-				price: 'N/A'
-			};
 		}
-	})()`
+		return nil
+	}
+}
+
+// hotelRate is a room rate tagged with its hotel, as listed in rate records.
+type hotelRate struct {
+	Hotel string `json:"hotel"`
+	delfos.RoomRate
+}
+
+func searchFlights(cfg *config.LoginConfig, req delfos.FlightSearchRequest, events searchEvents) searchFunc {
+	return func(ctx context.Context, result *LoginResult) error {
+		events.emit("progress", searchProgress{Stage: stageLoading})
+		itineraries, err := delfos.SearchFlights(ctx, cfg.TargetURL, req)
+		if err != nil {
+			return err
+		}
+		result.Itineraries = itineraries
+		for _, it := range itineraries {
+			events.emit("itinerary", it)
+		}
+		if err := chromedp.Run(ctx, chromedp.Location(&result.URL)); err != nil {
+			return fmt.Errorf("read results url: %w", err)
+		}
+		return nil
+	}
+}
+
+func searchPackages(cfg *config.LoginConfig, req delfos.PackageSearchRequest, events searchEvents) searchFunc {
+	return func(ctx context.Context, result *LoginResult) error {
+		events.emit("progress", searchProgress{Stage: stageLoading})
+		packages, err := delfos.SearchPackages(ctx, cfg.TargetURL, req)
+		if err != nil {
+			return err
+		}
+		result.Packages = packages
+		for _, p := range packages {
+			events.emit("package", p)
+		}
+		if err := chromedp.Run(ctx, chromedp.Location(&result.URL)); err != nil {
+			return fmt.Errorf("read results url: %w", err)
+		}
+		return nil
+	}
+}
+
+func featuredPackages(cfg *config.LoginConfig, events searchEvents) searchFunc {
+	return func(ctx context.Context, result *LoginResult) error {
+		events.emit("progress", searchProgress{Stage: stageLoading})
+		packages, err := delfos.FeaturedPackages(ctx, cfg.TargetURL)
+		if err != nil {
+			return err
+		}
+		result.Packages = packages
+		for _, p := range packages {
+			events.emit("package", p)
+		}
+		result.URL = cfg.TargetURL
+		return nil
+	}
 }
 
 func extractSessionFromCookies(cookies string) string {
@@ -339,25 +637,43 @@ func extractSessionFromCookies(cookies string) string {
 	return ""
 }
 
-func runDebug(ctx context.Context, pool *browser.Pool, url string) {
+// runDebugMode prints the structure of the login page in a visible browser,
+// within timeout (0 = defaultTimeout).
+func runDebugMode(ctx context.Context, cfg *config.LoginConfig, handoff config.HandoffConfig, timeout time.Duration) int {
+	browserCfg, err := browserConfig(cfg, handoff)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Debug failed: %v\n", err)
+		return output.ExitFailure
+	}
+	browserCfg.Timeout = cmp.Or(timeout, defaultTimeout)
+	browserCfg.Headless = false
+
+	pool, err := browser.NewPool(ctx, browserCfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Debug failed: %v\n", err)
+		return output.ExitFailure
+	}
+	defer pool.Close()
+
 	browserCtx, cancel := pool.NewContext(ctx)
 	defer cancel()
 
 	var pageStruct map[string]interface{}
 
-	err := chromedp.Run(browserCtx,
-		chromedp.Navigate(url),
+	err = chromedp.Run(browserCtx,
+		chromedp.Navigate(cfg.TargetURL),
 		chromedp.WaitReady("body", chromedp.ByQuery),
-		chromedp.Sleep(2*time.Second),
+		browser.WaitAjaxIdle(10*time.Second),
 		chromedp.Evaluate(buildDebugScript(), &pageStruct),
 	)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Debug failed: %v\n", err)
-		os.Exit(1)
+		return output.ExitFailure
 	}
 
 	data, _ := json.MarshalIndent(pageStruct, "", "  ")
 	fmt.Println(string(data))
+	return output.ExitOK
 }
 
 func buildDebugScript() string {
@@ -459,41 +775,15 @@ func buildDebugScript() string {
 	})()`
 }
 
-func parseResult(sessionID, url string, raw map[string]interface{}, debug string) *LoginResult {
-	result := &LoginResult{
-		URL:   url,
-		Debug: debug,
-	}
-
-	if !strings.Contains(url, "login.xhtml") && !strings.Contains(url, "login?") {
-		result.SessionID = "LOGGED_IN"
-	}
-
-	if name, ok := raw["name"].(string); ok {
-		result.HotelName = name
+// printSummary writes the login and search context that the table format
+// leaves out to stderr.
+func printSummary(r *LoginResult) {
+	fmt.Fprintf(os.Stderr, "Login: %s", r.Login.Status)
+	if r.Login.UserName != "" {
+		fmt.Fprintf(os.Stderr, " (%s)", r.Login.UserName)
 	}
-	if price, ok := raw["price"].(string); ok {
-		result.Price = price
-	}
-
-	if cookies, ok := raw["cookies"].(string); ok && cookies != "" {
-		result.Debug += " | Cookies: " + strings.Split(cookies, ";")[0] + "..."
-	}
-
-	return result
-}
-
-func printResult(r *LoginResult) {
-	fmt.Println("=== RESULT ===")
-	if r.SessionID == "" {
-		fmt.Println("Session ID: (not obtained)")
-		return
-	}
-	fmt.Printf("Session ID: %s\n", r.SessionID)
-	fmt.Printf("URL: %s\n", r.URL)
-	fmt.Printf("Hotel: %s\n", r.HotelName)
-	fmt.Printf("Price: %s\n", r.Price)
-	if r.Debug != "" {
-		fmt.Printf("Debug: %s\n", r.Debug)
+	fmt.Fprintln(os.Stderr)
+	if r.URL != "" {
+		fmt.Fprintf(os.Stderr, "URL: %s\n", r.URL)
 	}
 }
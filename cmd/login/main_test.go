@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"ExpeditusClient/internal/config"
+	"ExpeditusClient/internal/delfos"
+)
+
+func TestBuildSearchRequestOccupancy(t *testing.T) {
+	tests := []struct {
+		occupancy     string
+		rooms, adults int
+		want          string // "" for an error
+	}{
+		{"", 2, 3, "3;3"},
+		{"", -1, 2, ""},
+		{"", 1, -1, ""},
+		{"", 0, 2, ""},
+		{"2:5", -1, -1, "2:5"}, // -occupancy overrides -rooms/-adults
+		{"0", 1, 2, ""},
+	}
+	for _, tt := range tests {
+		req, err := buildSearchRequest("PUJ", "01/12/2026", "08/12/2026", string(delfos.TripOnlyHotel), tt.occupancy, tt.rooms, tt.adults)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("%q rooms=%d adults=%d: accepted", tt.occupancy, tt.rooms, tt.adults)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q rooms=%d adults=%d: %v", tt.occupancy, tt.rooms, tt.adults, err)
+		} else if got := delfos.FormatOccupancy(req.Occupancy); got != tt.want {
+			t.Errorf("%q rooms=%d adults=%d: occupancy %q, want %q", tt.occupancy, tt.rooms, tt.adults, got, tt.want)
+		}
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	if got := runTimeout(1, 0, true, 0); got != 0 {
+		t.Errorf("details of every page: %s, want no deadline", got)
+	}
+
+	// every page of a search must fit, retries included
+	all := runTimeout(1, 0, false, 0)
+	if want := time.Duration(delfos.DefaultMaxPages) * pageBudget; all < want {
+		t.Errorf("all pages: %s, want at least %s", all, want)
+	}
+	few := runTimeout(1, 5, false, 0)
+	if few >= all || few < defaultTimeout+searchBudget {
+		t.Errorf("5 hotels: %s (all pages %s)", few, all)
+	}
+	if got := runTimeout(1, 5, true, 0); got < few+5*detailBudget {
+		t.Errorf("5 hotels with details: %s, want at least %s", got, few+5*detailBudget)
+	}
+	if got := runTimeout(3, 5, false, 0); got < 3*(few-retryPolicy.Budget(defaultTimeout)) {
+		t.Errorf("3 searches: %s", got)
+	}
+
+	// the time a human gets for a CAPTCHA comes from the caller, not from
+	// whatever configuration was loaded last
+	if got := runTimeout(1, 5, false, 10*time.Minute); got != few+10*time.Minute {
+		t.Errorf("with a CAPTCHA wait: %s, want %s", got, few+10*time.Minute)
+	}
+	var none *captchaHandoff
+	if none.wait() != 0 || (&captchaHandoff{config: config.HandoffConfig{Wait: time.Minute}}).wait() != 0 {
+		t.Error("a handoff without a DevTools port waits for a human")
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"ExpeditusClient/internal/delfos"
+	"ExpeditusClient/internal/money"
+	"ExpeditusClient/internal/output"
+)
+
+// fillReport stores r in report: the whole result as data, and the most
+// detailed list it holds (rates, itineraries, packages or hotels) as records
+// and table rows.
+func fillReport(report *output.Report, r *LoginResult) {
+	report.Data = r
+
+	switch {
+	case len(r.Details) > 0:
+		report.Table.Columns = []string{"hotel", "room", "board", "occupancy", "nightly_price", "total_price", "currency", "refundable", "cancellation_policy", "first_deadline"}
+		for _, d := range r.Details {
+			for _, rate := range d.Rates {
+				report.Records = append(report.Records, output.Record{Kind: "rate", Data: hotelRate{d.Hotel.Name, rate}})
+				deadline := ""
+				if len(rate.CancellationDeadlines) > 0 {
+					deadline = rate.CancellationDeadlines[0].Format(time.RFC3339)
+				}
+				report.Table.Rows = append(report.Table.Rows, []string{
+					d.Hotel.Name, rate.Room, rate.Board, rate.Occupancy,
+					amount(rate.NightlyPrice), amount(rate.TotalPrice), rate.TotalPrice.Currency,
+					boolCell(rate.Refundable), rate.CancellationPolicy, deadline,
+				})
+			}
+		}
+
+	case r.Itineraries != nil:
+		report.Table.Columns = []string{"carriers", "stops", "route", "flights", "departs", "arrives", "return_departs", "return_arrives", "fare_family", "baggage", "refundable", "total_price", "currency"}
+		for _, it := range r.Itineraries {
+			report.Records = append(report.Records, output.Record{Kind: "itinerary", Data: it})
+			out, ret := legs(it.Segments)
+			report.Table.Rows = append(report.Table.Rows, []string{
+				strings.Join(it.Carriers, " "), strconv.Itoa(it.Stops()), route(it.Segments), flights(it.Segments),
+				departs(out), arrives(out), departs(ret), arrives(ret),
+				it.FareFamily, it.Baggage, boolCell(it.Refundable), amount(it.TotalPrice), it.TotalPrice.Currency,
+			})
+		}
+
+	case r.Packages != nil:
+		report.Table.Columns = []string{"title", "components", "nights", "destinations", "destination_count", "price_per_person", "total_price", "currency", "featured", "detail_url"}
+		for _, p := range r.Packages {
+			report.Records = append(report.Records, output.Record{Kind: "package", Data: p})
+			components := make([]string, len(p.Components))
+			for i, c := range p.Components {
+				components[i] = string(c)
+			}
+			cur := p.TotalPrice.Currency
+			if cur == "" {
+				cur = p.PricePerPerson.Currency
+			}
+			report.Table.Rows = append(report.Table.Rows, []string{
+				p.Title, strings.Join(components, " "), strconv.Itoa(p.Nights), strings.Join(p.Destinations, "; "),
+				strconv.Itoa(p.DestinationCount), amount(p.PricePerPerson), amount(p.TotalPrice), cur,
+				strconv.FormatBool(p.Featured), p.DetailURL,
+			})
+		}
+
+	default:
+		report.Table.Columns = []string{"name", "stars", "category", "address", "zone", "room_type", "board", "refundable", "provider", "nightly_price", "total_price", "currency", "detail_url"}
+		for _, h := range r.Hotels {
+			report.Records = append(report.Records, output.Record{Kind: "hotel", Data: h})
+			report.Table.Rows = append(report.Table.Rows, []string{
+				h.Name, strconv.FormatFloat(h.Stars, 'f', -1, 64), h.Category, h.Address, h.Zone, h.RoomType, h.Board,
+				boolCell(h.Refundable), h.Provider, amount(h.NightlyPrice), amount(h.TotalPrice), h.TotalPrice.Currency, h.DetailURL,
+			})
+		}
+	}
+}
+
+// exitCode maps a run error to the process exit code and report error code.
+func exitCode(err error) (int, string) {
+	var loginErr *delfos.LoginError
+	if errors.As(err, &loginErr) {
+		return output.ExitLoginFailed, "login_" + string(loginErr.Outcome.Status)
+	}
+	return output.ExitFailure, "failed"
+}
+
+func amount(m money.Money) string {
+	if m.IsZero() {
+		return ""
+	}
+	return m.Decimal()
+}
+
+func boolCell(b *bool) string {
+	if b == nil {
+		return ""
+	}
+	return strconv.FormatBool(*b)
+}
+
+func legs(segments []delfos.FlightSegment) (out, ret []delfos.FlightSegment) {
+	for _, s := range segments {
+		if s.Direction == "return" {
+			ret = append(ret, s)
+		} else {
+			out = append(out, s)
+		}
+	}
+	return out, ret
+}
+
+func route(segments []delfos.FlightSegment) string {
+	var stops []string
+	for i, s := range segments {
+		if i == 0 || segments[i-1].To != s.From || segments[i-1].Direction != s.Direction {
+			if i > 0 {
+				stops = append(stops, "/")
+			}
+			stops = append(stops, s.From)
+		}
+		stops = append(stops, s.To)
+	}
+	return strings.ReplaceAll(strings.Join(stops, "-"), "-/-", " / ")
+}
+
+func flights(segments []delfos.FlightSegment) string {
+	numbers := make([]string, 0, len(segments))
+	for _, s := range segments {
+		if s.FlightNumber != "" {
+			numbers = append(numbers, s.FlightNumber)
+		}
+	}
+	return strings.Join(numbers, " ")
+}
+
+func departs(leg []delfos.FlightSegment) string {
+	if len(leg) == 0 || leg[0].Departs.IsZero() {
+		return ""
+	}
+	return leg[0].Departs.Format("2006-01-02 15:04")
+}
+
+func arrives(leg []delfos.FlightSegment) string {
+	if len(leg) == 0 || leg[len(leg)-1].Arrives.IsZero() {
+		return ""
+	}
+	return leg[len(leg)-1].Arrives.Format("2006-01-02 15:04")
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"ExpeditusClient/internal/browser"
+	"ExpeditusClient/internal/config"
+	"ExpeditusClient/internal/delfos"
+	"ExpeditusClient/internal/retry"
+)
+
+// retryPolicy is loaded at startup from $EXPEDITUS_DATA_DIR/retry.json.
+var retryPolicy = retry.DefaultPolicy()
+
+func loadRetryPolicy() error {
+	dir, err := config.DataDir()
+	if err != nil {
+		return err
+	}
+	policy, err := retry.Load(filepath.Join(dir, "retry.json"))
+	if err != nil {
+		return err
+	}
+	retryPolicy = policy
+	return nil
+}
+
+// withRetry runs a navigation or extraction step in a logged-in tab under the
+// retry policy. An expired session is logged in again in the same tab and a
+// CAPTCHA is passed to handoff.
+func withRetry(ctx context.Context, cfg *config.LoginConfig, handoff *captchaHandoff, name string, events searchEvents, run func(ctx context.Context) error) error {
+	return retryPolicy.Do(ctx, retry.Step{
+		Name:     name,
+		Run:      run,
+		Classify: delfos.Classify,
+		Relogin: func(ctx context.Context) error {
+			_, err := delfos.Login(ctx, cfg, browser.HumanTyping())
+			return err
+		},
+		Handoff: func(ctx context.Context) error {
+			return handoff.await(ctx, events)
+		},
+		Logf: func(format string, args ...any) {
+			msg := fmt.Sprintf(format, args...)
+			fmt.Fprintf(os.Stderr, "Retry: %s\n", msg)
+			events.emit("progress", searchProgress{Stage: stageRetry, Message: msg})
+		},
+	})
+}
+
+// loginWithRetry logs in under the retry policy. After a CAPTCHA handoff the
+// human may have submitted the form already, so the page is checked first.
+func loginWithRetry(ctx context.Context, cfg *config.LoginConfig, handoff *captchaHandoff) (delfos.LoginOutcome, error) {
+	var outcome delfos.LoginOutcome
+	handedOff := false
+	err := retryPolicy.Do(ctx, retry.Step{
+		Name: "login",
+		Run: func(ctx context.Context) error {
+			if handedOff {
+				handedOff = false
+				if current, err := delfos.VerifyLogin(ctx); err == nil && current.Status == delfos.LoginSuccess {
+					outcome = current
+					return nil
+				}
+			}
+			var err error
+			outcome, err = delfos.Login(ctx, cfg, browser.HumanTyping())
+			return err
+		},
+		Classify: delfos.Classify,
+		Handoff: func(ctx context.Context) error {
+			handedOff = true
+			return handoff.await(ctx, nil)
+		},
+		Logf: func(format string, args ...any) {
+			fmt.Fprintf(os.Stderr, "Retry: "+format+"\n", args...)
+		},
+	})
+	return outcome, err
+}
@@ -0,0 +1,446 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"ExpeditusClient/internal/browser"
+	"ExpeditusClient/internal/config"
+	"ExpeditusClient/internal/delfos"
+	"ExpeditusClient/internal/jobs"
+	"ExpeditusClient/internal/output"
+	"ExpeditusClient/internal/ratelimit"
+	"ExpeditusClient/internal/scheduler"
+
+	"github.com/chromedp/chromedp"
+)
+
+// Job types of the serve configuration.
+const (
+	jobSearch    = "search"
+	jobWatchlist = "watchlist"
+	jobKeepAlive = "keepalive"
+	jobLogout    = "logout"
+	jobHealth    = "health"
+)
+
+const defaultJobTimeout = 10 * time.Minute
+
+// serveConfig is the JSON file listing the jobs of "login serve".
+type serveConfig struct {
+	Jobs []jobConfig `json:"jobs"`
+}
+
+type jobConfig struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Schedule string `json:"schedule"`          // "@every 30m" or a cron expression
+	Jitter   string `json:"jitter,omitempty"`  // e.g. "5m"
+	Timeout  string `json:"timeout,omitempty"` // default 10m
+	Notify   string `json:"notify,omitempty"`  // watchlist jobs: stdout, webhook, email
+	searchParams
+}
+
+// session is the logged-in tab of one worker of "login serve". Holding its
+// lock keeps two jobs from driving the tab at the same time.
+type session struct {
+	pool    *browser.Pool
+	cfg     *config.LoginConfig
+	handoff *captchaHandoff
+	worker  int
+	lock    chan struct{}
+
+	tab   context.Context
+	close context.CancelFunc
+	// expired is set when another worker logged out; the shared cookies are
+	// gone, so the next run logs in again.
+	expired atomic.Bool
+
+	statusMu sync.Mutex
+	status   sessionStatus
+}
+
+// sessionStatus is the session state reported by the API.
+type sessionStatus struct {
+	Worker   int                 `json:"worker"`
+	LoggedIn bool                `json:"logged_in"`
+	Login    delfos.LoginOutcome `json:"login,omitzero"`
+	Since    time.Time           `json:"since,omitzero"`
+	LastUsed time.Time           `json:"last_used,omitzero"`
+	Busy     bool                `json:"busy"`
+}
+
+func newSession(pool *browser.Pool, cfg *config.LoginConfig, handoff *captchaHandoff, worker int) *session {
+	return &session{pool: pool, cfg: cfg, handoff: handoff, worker: worker, lock: make(chan struct{}, 1), status: sessionStatus{Worker: worker}}
+}
+
+// do runs fn in the logged-in tab, logging in first when needed. Waiting for
+// the session and fn itself stop when ctx is done; a failed run drops the
+// session so the next one starts from a fresh login.
+func (s *session) do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return s.run(ctx, true, fn)
+}
+
+// run is do, logging in only when login is set.
+func (s *session) run(ctx context.Context, login bool, fn func(ctx context.Context) error) error {
+	select {
+	case s.lock <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-s.lock }()
+	s.setStatus(func(st *sessionStatus) { st.Busy = true })
+	defer s.setStatus(func(st *sessionStatus) { st.Busy, st.LastUsed = false, time.Now().UTC() })
+
+	fresh := s.tab == nil || s.tab.Err() != nil
+	if fresh {
+		s.tab, s.close = s.pool.NewContext(context.Background())
+	}
+	runCtx, cancel := context.WithCancel(s.tab)
+	defer cancel()
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
+
+	err := func() error {
+		if login && (fresh || s.expired.Swap(false)) {
+			// the tabs share cookies, so another worker may have logged in already
+			outcome, err := delfos.KeepAlive(runCtx, s.cfg.TargetURL)
+			if err != nil || outcome.Status != delfos.LoginSuccess {
+				if outcome, err = loginWithRetry(runCtx, s.cfg, s.handoff); err != nil {
+					return err
+				}
+			}
+			s.setStatus(func(st *sessionStatus) { st.LoggedIn, st.Login, st.Since = true, outcome, time.Now().UTC() })
+		}
+		return fn(runCtx)
+	}()
+	if err != nil {
+		s.reset()
+	}
+	return err
+}
+
+// Status returns the last known session state without touching the browser.
+func (s *session) Status() sessionStatus {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	return s.status
+}
+
+func (s *session) setStatus(fn func(*sessionStatus)) {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	fn(&s.status)
+}
+
+// expire marks the session logged out.
+func (s *session) expire() {
+	s.expired.Store(true)
+	s.setStatus(func(st *sessionStatus) { st.LoggedIn, st.Since = false, time.Time{} })
+}
+
+func (s *session) reset() {
+	if s.close != nil {
+		s.close()
+	}
+	s.tab, s.close = nil, nil
+	s.setStatus(func(st *sessionStatus) { st.LoggedIn, st.Since = false, time.Time{} })
+}
+
+// runServe implements "login serve": it keeps one Chromium process open, runs
+// the configured jobs and serves the HTTP API until interrupted. Browser work
+// goes through a persistent job queue processed by -workers logged-in tabs.
+func runServe(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	configPath := fs.String("config", "", "Jobs file (default $EXPEDITUS_DATA_DIR/serve.json if it exists)")
+	addr := fs.String("addr", defaultAddr(), "HTTP API listen address, empty to disable (default 127.0.0.1:$PORT when PORT is set)")
+	workerCount := fs.Int("workers", 1, "Browser workers, each with its own logged-in tab")
+	if err := fs.Parse(args); err != nil {
+		return output.ExitUsage
+	}
+
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	if *workerCount < 1 {
+		logger.Printf("Error: -workers must be at least 1")
+		return output.ExitUsage
+	}
+	dataDir, err := config.DataDir()
+	if err != nil {
+		logger.Printf("Error: %v", err)
+		return output.ExitFailure
+	}
+	jobsFile := *configPath
+	if jobsFile == "" {
+		jobsFile = filepath.Join(dataDir, "serve.json")
+	}
+	jobsCfg, err := loadServeConfig(jobsFile)
+	if errors.Is(err, os.ErrNotExist) && *configPath == "" {
+		jobsCfg, err = &serveConfig{}, nil
+	}
+	if err == nil && len(jobsCfg.Jobs) == 0 && *addr == "" {
+		err = fmt.Errorf("nothing to serve: no jobs in %s and no -addr", jobsFile)
+	}
+	token := config.APIToken()
+	if err == nil && *addr != "" && token == "" {
+		err = errors.New("EXPEDITUS_API_TOKEN is required to serve the API")
+	}
+	if err != nil {
+		logger.Printf("Error: %v", err)
+		return output.ExitUsage
+	}
+	cfg, err := config.LoadLoginConfig()
+	if err != nil {
+		logger.Printf("Error: load config: %v", err)
+		return output.ExitFailure
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	handoff, err := config.LoadHandoffConfig()
+	if err != nil {
+		logger.Printf("Error: %v", err)
+		return output.ExitUsage
+	}
+	browserCfg, err := browserConfig(cfg, handoff)
+	if err != nil {
+		logger.Printf("Error: %v", err)
+		return output.ExitUsage
+	}
+	site := &http.Client{Transport: &ratelimit.Transport{Host: browserCfg.ThrottleHost, Pacer: browserCfg.Throttle}}
+	browserCfg.Timeout = 0 // the session tabs live as long as the daemon; jobs carry their own timeouts
+	// the browser outlives ctx so the session can be logged out on shutdown
+	pool, err := browser.NewPool(context.Background(), browserCfg)
+	if err != nil {
+		logger.Printf("Error: create browser pool: %v", err)
+		return output.ExitFailure
+	}
+	defer pool.Close()
+
+	sched, err := scheduler.New(filepath.Join(dataDir, "scheduler-state.json"), logger.Printf)
+	if err != nil {
+		logger.Printf("Error: %v", err)
+		return output.ExitFailure
+	}
+	queue, err := jobs.Open(filepath.Join(dataDir, "jobs"), jobRetention)
+	if err != nil {
+		logger.Printf("Error: %v", err)
+		return output.ExitFailure
+	}
+	wk := newWorkers(*workerCount, cfg, handoff, pool, queue, logger)
+	defer wk.close()
+
+	for _, jc := range jobsCfg.Jobs {
+		job, err := buildJob(jc, cfg, pool, wk, site, logger)
+		if err == nil {
+			err = sched.Add(job)
+		}
+		if err != nil {
+			logger.Printf("Error: job %q: %v", jc.Name, err)
+			return output.ExitUsage
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		wk.run(ctx)
+	}()
+	if *addr != "" {
+		srv := &http.Server{
+			Addr:              *addr,
+			Handler:           newAPI(cfg, wk, sched, token, logger).routes(),
+			ReadHeaderTimeout: 10 * time.Second,
+			// stop waiting requests on shutdown so their jobs are canceled, not requeued
+			BaseContext: func(net.Listener) context.Context { return ctx },
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			logger.Printf("API listening on %s", *addr)
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Printf("Error: API: %v", err)
+				stop()
+			}
+		}()
+		context.AfterFunc(ctx, func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			srv.Shutdown(shutdownCtx)
+		})
+	}
+
+	if len(jobsCfg.Jobs) > 0 {
+		logger.Printf("serving %d job(s) from %s", len(jobsCfg.Jobs), jobsFile)
+	}
+	logger.Printf("%d browser worker(s)", *workerCount)
+	sched.Run(ctx)
+	<-ctx.Done()
+	wg.Wait()
+	wk.signOut()
+	logger.Printf("stopped")
+	return output.ExitOK
+}
+
+func defaultAddr() string {
+	if port := os.Getenv("PORT"); port != "" {
+		return "127.0.0.1:" + port
+	}
+	return ""
+}
+
+func loadServeConfig(path string) (*serveConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read jobs file: %w", err)
+	}
+	var cfg serveConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse jobs file %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+func buildJob(jc jobConfig, cfg *config.LoginConfig, pool *browser.Pool, wk *workers, site *http.Client, logger *log.Logger) (scheduler.Job, error) {
+	job := scheduler.Job{Name: jc.Name, Timeout: defaultJobTimeout}
+	var err error
+	if job.Schedule, err = scheduler.Parse(jc.Schedule); err != nil {
+		return job, err
+	}
+	if jc.Jitter != "" {
+		if job.Jitter, err = time.ParseDuration(jc.Jitter); err != nil {
+			return job, fmt.Errorf("jitter: %w", err)
+		}
+	}
+	if jc.Timeout != "" {
+		if job.Timeout, err = time.ParseDuration(jc.Timeout); err != nil {
+			return job, fmt.Errorf("timeout: %w", err)
+		}
+	}
+
+	// browser jobs wait in the queue behind interactive requests
+	switch jc.Type {
+	case jobSearch:
+		p := jc.searchParams.withDefaults()
+		// check the parameters now so a typo fails at startup, not at the first run
+		if _, _, err := buildSearch(cfg, nil, withRelativeDates(p, time.Now()), time.Now(), nil); err != nil {
+			return job, err
+		}
+		job.Run = func(ctx context.Context) error {
+			result := &LoginResult{}
+			if err := wk.do(ctx, jobSearch, jobs.PriorityBackground, withRelativeDates(p, time.Now()), result); err != nil {
+				return err
+			}
+			logger.Printf("job %s: %s", jc.Name, resultSummary(result))
+			return nil
+		}
+
+	case jobWatchlist:
+		params := watchlistParams{Notify: jc.Notify, Limit: jc.Limit}
+		if params.Notify != "" {
+			// fail at startup on an unknown channel or missing settings
+			if _, err := buildNotifiers(params.Notify, config.LoadNotifyConfig(), os.Stdout); err != nil {
+				return job, err
+			}
+		}
+		job.Run = func(ctx context.Context) error {
+			return wk.do(ctx, jobWatchlist, jobs.PriorityBackground, params, nil)
+		}
+
+	case jobKeepAlive:
+		job.Run = func(ctx context.Context) error {
+			return wk.do(ctx, jobKeepAlive, jobs.PriorityBackground, struct{}{}, nil)
+		}
+
+	case jobLogout:
+		job.Run = func(ctx context.Context) error {
+			return wk.do(ctx, jobLogout, jobs.PriorityBackground, struct{}{}, nil)
+		}
+
+	case jobHealth:
+		job.Run = func(ctx context.Context) error {
+			if err := pool.SingleRun(ctx, chromedp.Navigate("about:blank")); err != nil {
+				return fmt.Errorf("browser: %w", err)
+			}
+			return checkSite(ctx, site, cfg.TargetURL)
+		}
+
+	default:
+		return job, fmt.Errorf("unknown job type %q (want search, watchlist, keepalive, logout or health)", jc.Type)
+	}
+	return job, nil
+}
+
+// withDefaults fills the fields left empty with the login flag defaults.
+func (p searchParams) withDefaults() searchParams {
+	if p.Trip == "" {
+		p.Trip = string(delfos.TripOnlyHotel)
+	}
+	if p.Cabin == "" {
+		p.Cabin = string(delfos.CabinEconomy)
+	}
+	if p.Rooms == 0 {
+		p.Rooms = 1
+	}
+	if p.Adults == 0 {
+		p.Adults = 2
+	}
+	return p
+}
+
+// withRelativeDates turns "+30d" check-in and check-out dates into
+// dd/mm/yyyy dates counted from today, so recurring searches move forward.
+func withRelativeDates(p searchParams, today time.Time) searchParams {
+	relative := func(s string) string {
+		days, ok := strings.CutPrefix(strings.TrimSuffix(s, "d"), "+")
+		if n, err := strconv.Atoi(days); ok && err == nil && strings.HasSuffix(s, "d") {
+			return today.AddDate(0, 0, n).Format("02/01/2006")
+		}
+		return s
+	}
+	p.CheckIn, p.CheckOut = relative(p.CheckIn), relative(p.CheckOut)
+	return p
+}
+
+// checkSite reports whether the site answers without a server error.
+func checkSite(ctx context.Context, client *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("site: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("site: %s", resp.Status)
+	}
+	return nil
+}
+
+func resultSummary(r *LoginResult) string {
+	switch {
+	case r.Itineraries != nil:
+		return fmt.Sprintf("%d itineraries", len(r.Itineraries))
+	case r.Packages != nil:
+		return fmt.Sprintf("%d packages", len(r.Packages))
+	}
+	return fmt.Sprintf("%d hotels", len(r.Hotels))
+}
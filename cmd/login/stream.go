@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"ExpeditusClient/internal/delfos"
+	"ExpeditusClient/internal/jobs"
+	"ExpeditusClient/internal/output"
+)
+
+const sseHeartbeat = 15 * time.Second
+
+type sseEvent struct {
+	kind string
+	data any
+}
+
+// searchStream runs a search and streams it as Server-Sent Events: progress
+// events, every item as soon as it is extracted, and a final "done" or
+// "error" event holding the response envelope without the items. GET takes
+// the search fields as query parameters so EventSource can be used; POST
+// takes the same JSON body as /v1/search. The search runs as an interactive
+// job whose ID is the message of the "queued" progress event.
+func (a *api) searchStream(w http.ResponseWriter, r *http.Request) {
+	report := output.NewReport("api search stream")
+	p, err := streamParams(r)
+	if err != nil {
+		report.Fail("usage", err)
+		respond(w, http.StatusBadRequest, report)
+		return
+	}
+	report.Meta.Params = requestParams(p)
+	p, err = a.checkSearch(p)
+	if err != nil {
+		report.Fail("usage", err)
+		respond(w, http.StatusBadRequest, report)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), a.timeout)
+	defer cancel()
+	events := make(chan sseEvent, 64)
+	id := jobs.NewID()
+	defer a.wk.observe(id, func(kind string, data any) {
+		select {
+		case events <- sseEvent{kind, data}:
+		case <-ctx.Done():
+		}
+	})()
+	if _, err := a.wk.submit(id, jobSearch, jobs.PriorityInteractive, p); err != nil {
+		report.Fail("failed", err)
+		respond(w, http.StatusInternalServerError, report)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // keep reverse proxies from buffering the stream
+	w.WriteHeader(http.StatusOK)
+	stream := &sseWriter{w: w, rc: http.NewResponseController(w)}
+	stream.send("progress", searchProgress{Stage: stageQueued, Message: id})
+
+	type outcome struct {
+		job jobs.Job
+		err error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		job, err := a.wk.wait(ctx, id)
+		done <- outcome{job, err}
+	}()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case ev := <-events:
+			stream.send(ev.kind, ev.data)
+		case <-heartbeat.C:
+			stream.comment("keep-alive")
+		case out := <-done:
+			for len(events) > 0 {
+				ev := <-events
+				stream.send(ev.kind, ev.data)
+			}
+			result := &LoginResult{}
+			err := out.err
+			if err == nil {
+				err = json.Unmarshal(out.job.Result, result)
+			}
+			if err != nil {
+				if r.Context().Err() != nil {
+					return // client is gone
+				}
+				code := "failed"
+				var failure *jobFailure
+				switch {
+				case errors.As(err, &failure):
+					code = failure.Code
+				case ctx.Err() != nil:
+					code = "timeout"
+				}
+				report.Fail(code, err)
+				stream.envelope("error", report)
+				return
+			}
+
+			fillReport(report, result)
+			report.Data = struct {
+				Job         string              `json:"job"`
+				Login       delfos.LoginOutcome `json:"login"`
+				URL         string              `json:"url,omitempty"`
+				Destination string              `json:"destination,omitempty"`
+			}{id, result.Login, result.URL, result.Destination}
+			stream.envelope("done", report)
+			return
+		}
+	}
+}
+
+// streamParams reads the search fields from the JSON body of a POST or the
+// query of a GET.
+func streamParams(r *http.Request) (searchParams, error) {
+	var p searchParams
+	if r.Method == http.MethodPost {
+		return p, decodeJSON(r, &p)
+	}
+
+	var err error
+	integer := func(v string) int {
+		n, convErr := strconv.Atoi(v)
+		if convErr != nil && err == nil {
+			err = fmt.Errorf("invalid number %q", v)
+		}
+		return n
+	}
+	boolean := func(v string) bool {
+		b, convErr := strconv.ParseBool(v)
+		if convErr != nil && err == nil {
+			err = fmt.Errorf("invalid boolean %q", v)
+		}
+		return b
+	}
+	for key, values := range r.URL.Query() {
+		v := values[0]
+		switch key {
+		case "trip":
+			p.Trip = v
+		case "origin":
+			p.Origin = v
+		case "dest":
+			p.Dest = v
+		case "checkin":
+			p.CheckIn = v
+		case "checkout":
+			p.CheckOut = v
+		case "cabin":
+			p.Cabin = v
+		case "occupancy":
+			p.Occupancy = v
+		case "rooms":
+			p.Rooms = integer(v)
+		case "adults":
+			p.Adults = integer(v)
+		case "limit":
+			p.Limit = integer(v)
+		case "details":
+			p.Details = boolean(v)
+		case "featured":
+			p.Featured = boolean(v)
+		default:
+			return p, fmt.Errorf("unknown query parameter %q", url.QueryEscape(key))
+		}
+	}
+	return p, err
+}
+
+// sseWriter writes Server-Sent Events, flushing after each one. Write errors
+// mean the client left; the request context then stops the search.
+type sseWriter struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+	id int
+}
+
+func (s *sseWriter) send(kind string, data any) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		payload, _ = json.Marshal(map[string]string{"error": err.Error()})
+	}
+	s.id++
+	fmt.Fprintf(s.w, "id: %d\nevent: %s\ndata: %s\n\n", s.id, kind, payload)
+	s.rc.Flush()
+}
+
+func (s *sseWriter) envelope(kind string, report *output.Report) {
+	var buf bytes.Buffer
+	report.Write(&buf, nil, output.FormatJSON)
+	var compact bytes.Buffer
+	json.Compact(&compact, buf.Bytes())
+	s.send(kind, json.RawMessage(compact.Bytes()))
+}
+
+func (s *sseWriter) comment(text string) {
+	fmt.Fprintf(s.w, ": %s\n\n", text)
+	s.rc.Flush()
+}
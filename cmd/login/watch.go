@@ -0,0 +1,397 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"ExpeditusClient/internal/browser"
+	"ExpeditusClient/internal/config"
+	"ExpeditusClient/internal/delfos"
+	"ExpeditusClient/internal/history"
+	"ExpeditusClient/internal/money"
+	"ExpeditusClient/internal/output"
+	"ExpeditusClient/internal/watch"
+)
+
+// watchCheck is the outcome of re-running one watch.
+type watchCheck struct {
+	Watch   watch.Watch   `json:"watch"`
+	Hotels  int           `json:"hotels"`
+	Lowest  money.Money   `json:"lowest,omitzero"`
+	Alerts  []watch.Alert `json:"alerts,omitempty"`
+	Skipped string        `json:"skipped,omitempty"`
+	Error   string        `json:"error,omitempty"`
+}
+
+func watchlistPath() (string, error) {
+	dir, err := config.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "watchlist.json"), nil
+}
+
+// runWatch implements "login watch add|list|remove|run".
+func runWatch(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: login watch add|list|remove|run [flags]")
+		return output.ExitUsage
+	}
+
+	fs := flag.NewFlagSet("watch "+args[0], flag.ContinueOnError)
+	formatFlag := fs.String("format", string(output.FormatTable), "Output format: json, ndjson, csv or table")
+	var sub func(report *output.Report, format output.Format) int
+
+	switch args[0] {
+	case "add":
+		name := fs.String("name", "", "Name shown in alerts, e.g. the quote or client")
+		dest := fs.String("dest", "", "Destination code (AUA) or name (Miami)")
+		checkIn := fs.String("checkin", "", "Check-in date (dd/mm/yyyy)")
+		checkOut := fs.String("checkout", "", "Check-out date (dd/mm/yyyy)")
+		occupancy := fs.String("occupancy", "2", "Per-room occupancy, e.g. \"2;2:5,8\"")
+		hotel := fs.String("hotel", "", "Only alert on hotels whose name contains this")
+		below := fs.String("below", "", "Alert when a price drops below this amount (\"USD 1500\")")
+		drop := fs.Float64("drop", 0, "Alert when a price drops by this percentage since it was last seen")
+		sub = func(report *output.Report, _ output.Format) int {
+			w, err := buildWatch(*name, *dest, *checkIn, *checkOut, *occupancy, *hotel, *below, *drop)
+			if err != nil {
+				report.Fail("usage", fmt.Errorf("invalid watch: %w", err))
+				return output.ExitUsage
+			}
+			return updateWatchlist(report, func(watches []watch.Watch) ([]watch.Watch, error) {
+				watches, w = watch.Add(watches, w)
+				fillWatchesReport(report, []watch.Watch{w})
+				return watches, nil
+			})
+		}
+
+	case "list":
+		sub = func(report *output.Report, _ output.Format) int {
+			path, err := watchlistPath()
+			if err == nil {
+				var watches []watch.Watch
+				if watches, err = watch.Load(path); err == nil {
+					fillWatchesReport(report, watches)
+					return output.ExitOK
+				}
+			}
+			report.Fail("watchlist", err)
+			return output.ExitFailure
+		}
+
+	case "remove":
+		id := fs.String("id", "", "ID of the watch to remove")
+		sub = func(report *output.Report, _ output.Format) int {
+			return updateWatchlist(report, func(watches []watch.Watch) ([]watch.Watch, error) {
+				watches, err := watch.Remove(watches, *id)
+				fillWatchesReport(report, watches)
+				return watches, err
+			})
+		}
+
+	case "run":
+		notify := fs.String("notify", "stdout", "Comma-separated alert destinations: stdout, webhook, email")
+		limit := fs.Int("limit", 0, "Maximum number of hotels to collect per watch (0 = all pages)")
+		logout := fs.Bool("logout", false, "Log out and clear the browser's cookies and storage when done")
+		timeout := fs.Duration("timeout", 0, "Deadline for the whole run (0 = sized from the watches and -limit)")
+		sub = func(report *output.Report, format output.Format) int {
+			// alert lines would break the machine-readable formats on stdout
+			var console io.Writer = os.Stdout
+			if format != output.FormatTable {
+				console = os.Stderr
+			}
+			notifiers, err := buildNotifiers(*notify, config.LoadNotifyConfig(), console)
+			if err != nil {
+				report.Fail("usage", err)
+				return output.ExitUsage
+			}
+			return runWatches(report, notifiers, *limit, *logout, *timeout)
+		}
+
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown watch command %q (want add, list, remove or run)\n", args[0])
+		return output.ExitUsage
+	}
+
+	if err := fs.Parse(args[1:]); err != nil {
+		return output.ExitUsage
+	}
+	format, err := output.ParseFormat(*formatFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return output.ExitUsage
+	}
+
+	report := output.NewReport("login watch " + args[0])
+	report.Meta.Params = make(map[string]string)
+	fs.Visit(func(f *flag.Flag) { report.Meta.Params[f.Name] = f.Value.String() })
+	code := sub(report, format)
+	if err := report.Write(os.Stdout, os.Stderr, format); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+		return output.ExitFailure
+	}
+	return code
+}
+
+func buildWatch(name, dest, checkIn, checkOut, occupancy, hotel, below string, drop float64) (watch.Watch, error) {
+	w := watch.Watch{Name: name, Destination: dest, Hotel: hotel, DropPct: drop}
+
+	rooms, err := delfos.ParseOccupancy(occupancy)
+	if err != nil {
+		return w, fmt.Errorf("-occupancy: %w", err)
+	}
+	w.Occupancy = delfos.FormatOccupancy(rooms)
+	if w.CheckIn, err = delfos.ParseDate(checkIn); err != nil {
+		return w, fmt.Errorf("-checkin: %w", err)
+	}
+	if w.CheckOut, err = delfos.ParseDate(checkOut); err != nil {
+		return w, fmt.Errorf("-checkout: %w", err)
+	}
+	if below != "" {
+		if w.Below, err = money.Parse(below); err != nil {
+			return w, fmt.Errorf("-below: %w", err)
+		}
+	}
+	return w, w.Validate(time.Now())
+}
+
+// updateWatchlist loads the watchlist, applies fn and saves the result.
+func updateWatchlist(report *output.Report, fn func([]watch.Watch) ([]watch.Watch, error)) int {
+	path, err := watchlistPath()
+	if err != nil {
+		report.Fail("watchlist", err)
+		return output.ExitFailure
+	}
+	watches, err := watch.Load(path)
+	if err != nil {
+		report.Fail("watchlist", err)
+		return output.ExitFailure
+	}
+	if watches, err = fn(watches); err != nil {
+		report.Fail("usage", err)
+		return output.ExitUsage
+	}
+	if err := watch.Save(path, watches); err != nil {
+		report.Fail("watchlist", err)
+		return output.ExitFailure
+	}
+	return output.ExitOK
+}
+
+func buildNotifiers(list string, cfg *config.NotifyConfig, console io.Writer) (watch.Notifiers, error) {
+	var notifiers watch.Notifiers
+	for _, name := range strings.Split(list, ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+		case "stdout":
+			notifiers = append(notifiers, watch.WriterNotifier{W: console})
+		case "webhook":
+			if cfg.WebhookURL == "" {
+				return nil, fmt.Errorf("-notify webhook: ALERT_WEBHOOK_URL is not set")
+			}
+			notifiers = append(notifiers, watch.WebhookNotifier{URL: cfg.WebhookURL})
+		case "email":
+			if cfg.SMTPAddr == "" || len(cfg.MailTo) == 0 || cfg.MailFrom == "" {
+				return nil, fmt.Errorf("-notify email: SMTP_HOST, ALERT_EMAIL_TO and SMTP_USER or ALERT_EMAIL_FROM are required")
+			}
+			notifiers = append(notifiers, watch.SMTPNotifier{
+				Addr: cfg.SMTPAddr, Username: cfg.SMTPUser, Password: cfg.SMTPPassword,
+				From: cfg.MailFrom, To: cfg.MailTo,
+			})
+		default:
+			return nil, fmt.Errorf("-notify: unknown destination %q (want stdout, webhook or email)", name)
+		}
+	}
+	return notifiers, nil
+}
+
+// runWatches logs in once, re-runs every active watch, records the prices in
+// the history and sends the alerts of each watch as soon as it is checked,
+// all within timeout (0 = sized from the watches and limit).
+func runWatches(report *output.Report, notifiers watch.Notifiers, limit int, logout bool, timeout time.Duration) int {
+	path, err := watchlistPath()
+	if err != nil {
+		report.Fail("watchlist", err)
+		return output.ExitFailure
+	}
+	watches, err := watch.Load(path)
+	if err != nil {
+		report.Fail("watchlist", err)
+		return output.ExitFailure
+	}
+	store, err := openHistory()
+	if err != nil {
+		report.Fail("history", err)
+		return output.ExitFailure
+	}
+	cfg, err := config.LoadLoginConfig()
+	if err != nil {
+		report.Fail("config", fmt.Errorf("load config: %w", err))
+		return output.ExitFailure
+	}
+
+	checks, active := pendingChecks(watches, time.Now())
+	if len(active) > 0 {
+		ctx := context.Background()
+		handoffCfg, err := config.LoadHandoffConfig()
+		if err != nil {
+			report.Fail("config", err)
+			return output.ExitFailure
+		}
+		handoff := &captchaHandoff{config: handoffCfg}
+		browserCfg, err := browserConfig(cfg, handoffCfg)
+		if err != nil {
+			report.Fail("config", err)
+			return output.ExitFailure
+		}
+		browserCfg.Timeout = cmp.Or(timeout, runTimeout(len(active), limit, false, handoff.wait()))
+		pool, err := browser.NewPool(ctx, browserCfg)
+		if err != nil {
+			report.Fail("browser", fmt.Errorf("create browser pool: %w", err))
+			return output.ExitFailure
+		}
+		defer pool.Close()
+
+		_, err = runLogin(ctx, pool, cfg, handoff, logout, func(ctx context.Context, _ *LoginResult) error {
+			for _, i := range active {
+				checkWatch(ctx, cfg, handoff, store, notifiers, limit, &checks[i])
+			}
+			return nil
+		})
+		if err != nil {
+			code, name := exitCode(err)
+			report.Fail(name, err)
+			return code
+		}
+	}
+
+	fillChecksReport(report, checks)
+	for _, c := range checks {
+		if c.Error != "" {
+			fmt.Fprintf(os.Stderr, "Warning: %s: %s\n", c.Watch.Label(), c.Error)
+		}
+	}
+	return output.ExitOK
+}
+
+// pendingChecks prepares a check per watch and returns the indexes of those
+// whose stay has not started yet.
+func pendingChecks(watches []watch.Watch, today time.Time) ([]watchCheck, []int) {
+	checks := make([]watchCheck, len(watches))
+	var active []int
+	for i, w := range watches {
+		checks[i].Watch = w
+		if w.Expired(today) {
+			checks[i].Skipped = "check-in has passed"
+		} else {
+			active = append(active, i)
+		}
+	}
+	return checks, active
+}
+
+func checkWatch(ctx context.Context, cfg *config.LoginConfig, handoff *captchaHandoff, store *history.Store, notifiers watch.Notifiers, limit int, c *watchCheck) {
+	w := c.Watch
+	rooms, err := delfos.ParseOccupancy(w.Occupancy)
+	if err != nil {
+		c.Error = err.Error()
+		return
+	}
+	req := delfos.SearchRequest{
+		Destination: w.Destination,
+		CheckIn:     w.CheckIn,
+		CheckOut:    w.CheckOut,
+		TripType:    delfos.TripOnlyHotel,
+		Occupancy:   rooms,
+	}
+
+	result := &LoginResult{}
+	run := searchHotels(cfg, handoff, req, delfos.ResultsOptions{Limit: limit}, false, nil)
+	err = withRetry(ctx, cfg, handoff, "check "+w.Label(), nil, func(ctx context.Context) error {
+		*result = LoginResult{}
+		return run(ctx, result)
+	})
+	if err != nil {
+		c.Error = err.Error()
+		return
+	}
+	c.Hotels = len(result.Hotels)
+	occupancy := delfos.FormatOccupancy(rooms)
+	current := historyPrices(result)
+	for i := range current {
+		current[i].Destination, current[i].Occupancy = result.Destination, occupancy
+		if c.Lowest.IsZero() || watchBelow(current[i].Price, c.Lowest) {
+			c.Lowest = current[i].Price
+		}
+	}
+
+	previous, err := store.Prices(history.Query{
+		Kind: history.KindHotel, Destination: result.Destination,
+		CheckIn: w.CheckIn, CheckOut: w.CheckOut, Occupancy: occupancy,
+	})
+	if err != nil {
+		c.Error = err.Error()
+		return
+	}
+	c.Alerts = watch.Evaluate(w, current, previous)
+
+	search := history.Search{
+		Account: cfg.Username, TripType: string(delfos.TripOnlyHotel), Destination: result.Destination,
+		CheckIn: w.CheckIn, CheckOut: w.CheckOut, Occupancy: occupancy,
+	}
+	if _, err := store.Record(search, current); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	if err := notifiers.Notify(ctx, c.Alerts); err != nil {
+		c.Error = err.Error()
+	}
+}
+
+func watchBelow(a, b money.Money) bool {
+	cmp, err := a.Compare(b)
+	return err == nil && cmp < 0
+}
+
+func fillWatchesReport(report *output.Report, watches []watch.Watch) {
+	report.Data = watches
+	report.Table.Columns = []string{"id", "name", "destination", "check_in", "check_out", "occupancy", "hotel", "below", "drop_pct"}
+	for _, w := range watches {
+		report.Records = append(report.Records, output.Record{Kind: "watch", Data: w})
+		drop := ""
+		if w.DropPct > 0 {
+			drop = strconv.FormatFloat(w.DropPct, 'f', -1, 64)
+		}
+		below := ""
+		if !w.Below.IsZero() {
+			below = w.Below.String()
+		}
+		report.Table.Rows = append(report.Table.Rows, []string{
+			w.ID, w.Name, w.Destination, dateCell(w.CheckIn), dateCell(w.CheckOut), w.Occupancy, w.Hotel, below, drop,
+		})
+	}
+}
+
+func fillChecksReport(report *output.Report, checks []watchCheck) {
+	report.Data = checks
+	report.Table.Columns = []string{"watch", "reason", "item", "detail", "price", "previous", "threshold", "drop_pct", "currency"}
+	for _, c := range checks {
+		for _, a := range c.Alerts {
+			report.Records = append(report.Records, output.Record{Kind: "alert", Data: a})
+			drop := ""
+			if a.DropPct > 0 {
+				drop = strconv.FormatFloat(a.DropPct, 'f', 1, 64)
+			}
+			report.Table.Rows = append(report.Table.Rows, []string{
+				a.Watch, a.Reason, a.Item, a.Detail, a.Price.Decimal(), amount(a.Previous), amount(a.Threshold), drop, a.Price.Currency,
+			})
+		}
+	}
+}
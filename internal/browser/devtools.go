@@ -0,0 +1,20 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chromedp/chromedp"
+)
+
+// InspectURL returns the address of the DevTools front end attached to the
+// tab of ctx, in a browser started with Config.DevToolsPort set to port. It
+// returns "" for a tab that has not started.
+func InspectURL(ctx context.Context, port int) string {
+	c := chromedp.FromContext(ctx)
+	if c == nil || c.Target == nil || port <= 0 {
+		return ""
+	}
+	host := fmt.Sprintf("127.0.0.1:%d", port)
+	return fmt.Sprintf("http://%s/devtools/inspector.html?ws=%s/devtools/page/%s", host, host, c.Target.TargetID)
+}
@@ -0,0 +1,118 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/chromedp/cdproto/input"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+	"github.com/chromedp/chromedp/kb"
+)
+
+// TypeOptions controls how TypeText feeds characters into an element.
+type TypeOptions struct {
+	MinDelay time.Duration // minimum pause between keystrokes
+	MaxDelay time.Duration // maximum pause between keystrokes
+	Clear    bool          // empty the field before typing
+	NoBlur   bool          // keep focus afterwards, e.g. to leave an autocomplete panel open
+}
+
+// HumanTyping returns options with small randomized per-key delays.
+func HumanTyping() TypeOptions {
+	return TypeOptions{
+		MinDelay: 40 * time.Millisecond,
+		MaxDelay: 160 * time.Millisecond,
+		Clear:    true,
+	}
+}
+
+// TypeText focuses the element matched by sel and types text into it through
+// Input.dispatchKeyEvent, falling back to Input.insertText for characters
+// without a keyboard mapping. The text never becomes part of any script source.
+func TypeText(sel string, text string, opts TypeOptions) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if err := chromedp.Run(ctx,
+			chromedp.WaitVisible(sel, chromedp.ByQuery),
+			chromedp.Focus(sel, chromedp.ByQuery),
+		); err != nil {
+			return fmt.Errorf("focus %s: %w", sel, err)
+		}
+
+		if opts.Clear {
+			if err := chromedp.Run(ctx, chromedp.SetValue(sel, "", chromedp.ByQuery)); err != nil {
+				return fmt.Errorf("clear %s: %w", sel, err)
+			}
+		}
+
+		for _, r := range text {
+			if err := typeRune(ctx, r); err != nil {
+				return fmt.Errorf("type into %s: %w", sel, err)
+			}
+			if err := sleepContext(ctx, keyDelay(opts)); err != nil {
+				return err
+			}
+		}
+
+		if opts.NoBlur {
+			return nil
+		}
+		return chromedp.Run(ctx, chromedp.Blur(sel, chromedp.ByQuery))
+	})
+}
+
+func typeRune(ctx context.Context, r rune) error {
+	if _, ok := kb.Keys[r]; !ok {
+		return input.InsertText(string(r)).Do(ctx)
+	}
+	for _, ev := range kb.Encode(r) {
+		if err := ev.Do(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func keyDelay(opts TypeOptions) time.Duration {
+	if opts.MaxDelay <= opts.MinDelay {
+		return opts.MinDelay
+	}
+	return opts.MinDelay + rand.N(opts.MaxDelay-opts.MinDelay)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// CallFunction calls the JavaScript function declaration fn in the page's
+// global scope. Arguments are serialized as CDP call arguments instead of being
+// formatted into the script, so quotes, backslashes and secrets are safe.
+func CallFunction(fn string, res any, args ...any) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		var global *runtime.RemoteObject
+		if err := chromedp.Evaluate(`globalThis`, &global).Do(ctx); err != nil {
+			return fmt.Errorf("resolve global object: %w", err)
+		}
+		defer runtime.ReleaseObject(global.ObjectID).Do(ctx)
+
+		return chromedp.CallFunctionOn(fn, res,
+			func(p *runtime.CallFunctionOnParams) *runtime.CallFunctionOnParams {
+				return p.WithObjectID(global.ObjectID).WithAwaitPromise(true)
+			},
+			args...,
+		).Do(ctx)
+	})
+}
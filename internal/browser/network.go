@@ -0,0 +1,180 @@
+package browser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// NetworkIdle runs trigger (which may be nil) and waits until no request has
+// been in flight for quiet. Requests are tracked through Network domain events
+// from before trigger runs, so navigations and AJAX calls it starts are seen.
+// Long-lived WebSocket and EventSource connections are ignored.
+func NetworkIdle(trigger chromedp.Action, quiet, timeout time.Duration) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if err := network.Enable().Do(ctx); err != nil {
+			return fmt.Errorf("enable network events: %w", err)
+		}
+
+		tracker := newRequestTracker()
+		listenCtx, stop := context.WithCancel(ctx)
+		defer stop()
+		chromedp.ListenTarget(listenCtx, tracker.handle)
+
+		if trigger != nil {
+			if err := trigger.Do(ctx); err != nil {
+				return err
+			}
+		}
+
+		deadline := time.Now().Add(timeout)
+		for {
+			pending, idleFor := tracker.status()
+			if len(pending) == 0 && idleFor >= quiet {
+				return nil
+			}
+			if time.Now().After(deadline) {
+				return &WaitError{
+					What:    fmt.Sprintf("network idle (%d in flight: %s)", len(pending), strings.Join(pending, ", ")),
+					Timeout: timeout,
+					State:   pageState(ctx),
+				}
+			}
+			if err := sleepContext(ctx, pollInterval/2); err != nil {
+				return err
+			}
+		}
+	})
+}
+
+type requestTracker struct {
+	mu       sync.Mutex
+	inflight map[network.RequestID]string
+	last     time.Time
+}
+
+func newRequestTracker() *requestTracker {
+	return &requestTracker{
+		inflight: make(map[network.RequestID]string),
+		last:     time.Now(),
+	}
+}
+
+func (t *requestTracker) handle(ev any) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch e := ev.(type) {
+	case *network.EventRequestWillBeSent:
+		if e.Type == network.ResourceTypeWebSocket || e.Type == network.ResourceTypeEventSource {
+			return
+		}
+		t.inflight[e.RequestID] = e.Request.URL
+	case *network.EventLoadingFinished:
+		delete(t.inflight, e.RequestID)
+	case *network.EventLoadingFailed:
+		delete(t.inflight, e.RequestID)
+	default:
+		return
+	}
+	t.last = time.Now()
+}
+
+func (t *requestTracker) status() ([]string, time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pending := make([]string, 0, len(t.inflight))
+	for _, u := range t.inflight {
+		if len(u) > 80 {
+			u = u[:80] + "..."
+		}
+		pending = append(pending, u)
+	}
+	sort.Strings(pending)
+	return pending, time.Since(t.last)
+}
+
+// WaitDOMStable waits until the subtree rooted at sel (the body when sel is
+// empty) has had no mutations for quiet.
+func WaitDOMStable(sel string, quiet, timeout time.Duration) chromedp.Action {
+	what := "DOM stable"
+	if sel != "" {
+		what += " under " + sel
+	}
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		var result string
+		err := CallFunction(domStableFn, &result, sel, quiet.Milliseconds(), timeout.Milliseconds()).Do(ctx)
+		if err != nil {
+			return fmt.Errorf("wait %s: %w", what, err)
+		}
+		switch result {
+		case "stable":
+			return nil
+		case "missing":
+			return fmt.Errorf("wait %s: no element matches", what)
+		default:
+			return &WaitError{What: what, Timeout: timeout, State: pageState(ctx)}
+		}
+	})
+}
+
+// WaitURL waits until the page URL matches pattern.
+func WaitURL(pattern *regexp.Regexp, timeout time.Duration) chromedp.Action {
+	return waitURL(pattern, timeout, func(ctx context.Context) (string, error) {
+		var current string
+		err := chromedp.Location(&current).Do(ctx)
+		return current, err
+	})
+}
+
+func waitURL(pattern *regexp.Regexp, timeout time.Duration, location func(context.Context) (string, error)) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		return pollUntil(ctx, "URL matching "+pattern.String(), timeout, func(ctx context.Context) (bool, error) {
+			current, err := location(ctx)
+			return err == nil && pattern.MatchString(current), err
+		})
+	})
+}
+
+// BestEffort runs action and discards a WaitError, for waits that only
+// improve timing (pages with analytics beacons may never go fully idle).
+func BestEffort(action chromedp.Action) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		err := action.Do(ctx)
+		var waitErr *WaitError
+		if errors.As(err, &waitErr) {
+			return nil
+		}
+		return err
+	})
+}
+
+const domStableFn = `function(sel, quietMs, timeoutMs) {
+	const root = sel ? document.querySelector(sel) : document.body;
+	if (!root) return Promise.resolve('missing');
+	return new Promise(resolve => {
+		let quietTimer = null;
+		const finish = (state) => {
+			observer.disconnect();
+			clearTimeout(quietTimer);
+			clearTimeout(limitTimer);
+			resolve(state);
+		};
+		const observer = new MutationObserver(() => {
+			clearTimeout(quietTimer);
+			quietTimer = setTimeout(() => finish('stable'), quietMs);
+		});
+		observer.observe(root, { childList: true, subtree: true, attributes: true, characterData: true });
+		quietTimer = setTimeout(() => finish('stable'), quietMs);
+		const limitTimer = setTimeout(() => finish('timeout'), timeoutMs);
+	});
+}`
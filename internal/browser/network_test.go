@@ -0,0 +1,85 @@
+package browser
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+)
+
+func TestRequestTracker(t *testing.T) {
+	tr := newRequestTracker()
+	send := func(id, url string, typ network.ResourceType) {
+		tr.handle(&network.EventRequestWillBeSent{
+			RequestID: network.RequestID(id),
+			Request:   &network.Request{URL: url},
+			Type:      typ,
+		})
+	}
+
+	send("1", "https://example.com/app.js", network.ResourceTypeScript)
+	send("2", "https://example.com/faces/results.xhtml", network.ResourceTypeXHR)
+	send("3", "wss://example.com/push", network.ResourceTypeWebSocket)
+	send("4", "https://example.com/events", network.ResourceTypeEventSource)
+	send("5", "https://example.com/"+strings.Repeat("a", 100), network.ResourceTypeImage)
+
+	pending, idle := tr.status()
+	if len(pending) != 3 {
+		t.Fatalf("pending %q, want 3 requests (long-lived connections ignored)", pending)
+	}
+	if pending[0] != "https://example.com/"+strings.Repeat("a", 60)+"..." {
+		t.Errorf("long url not shortened: %q", pending[0])
+	}
+	if idle > time.Second {
+		t.Errorf("idle for %s right after a request", idle)
+	}
+
+	tr.handle(&network.EventLoadingFinished{RequestID: "1"})
+	tr.handle(&network.EventLoadingFailed{RequestID: "2"})
+	tr.handle(&network.EventLoadingFinished{RequestID: "5"})
+	if pending, _ := tr.status(); len(pending) != 0 {
+		t.Errorf("pending %q after every request ended", pending)
+	}
+
+	// unrelated events do not reset the quiet period
+	time.Sleep(20 * time.Millisecond)
+	tr.handle(&network.EventDataReceived{RequestID: "1"})
+	if _, idle := tr.status(); idle < 20*time.Millisecond {
+		t.Errorf("idle for %s, want at least 20ms", idle)
+	}
+}
+
+func TestWaitURL(t *testing.T) {
+	results := regexp.MustCompile(`^https://example\.com/home\b`)
+	errGone := errors.New("execution context was destroyed")
+	tests := []struct {
+		name    string
+		urls    []string // "" stands for a failed read, as during a navigation
+		wantErr bool
+	}{
+		{"already there", []string{"https://example.com/home?directSubmit=true"}, false},
+		{"after a redirect", []string{"about:blank", "", "https://example.com/home"}, false},
+		{"elsewhere", []string{"https://example.com/login"}, true},
+	}
+	for _, tt := range tests {
+		calls := 0
+		location := func(context.Context) (string, error) {
+			u := tt.urls[min(calls, len(tt.urls)-1)]
+			calls++
+			if u == "" {
+				return "", errGone
+			}
+			return u, nil
+		}
+
+		err := waitURL(results, 250*time.Millisecond, location).Do(context.Background())
+		var waitErr *WaitError
+		if tt.wantErr != errors.As(err, &waitErr) || (!tt.wantErr && err != nil) {
+			t.Errorf("%s: err = %v, want a WaitError %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package browser
 
 import (
 	"context"
+	"strconv"
 	"sync"
 	"time"
 
@@ -18,6 +19,15 @@ type Config struct {
 	WindowHeight  int
 	DisableGPU    bool
 	DisableDevShm bool
+
+	// Throttle, when set, paces the page loads and AJAX requests every tab
+	// sends to ThrottleHost.
+	Throttle     Throttler
+	ThrottleHost string
+
+	// DevToolsPort, when set, serves the DevTools of every tab on
+	// 127.0.0.1:DevToolsPort so a human can take one over (see InspectURL).
+	DevToolsPort int
 }
 
 func DefaultConfig() Config {
@@ -33,11 +43,15 @@ func DefaultConfig() Config {
 	}
 }
 
+// Pool shares one Chromium process between tabs. The browser starts with the
+// first tab and is restarted if it exits.
 type Pool struct {
-	mu       sync.RWMutex
-	allocCtx context.Context
-	cancel   context.CancelFunc
-	config   Config
+	mu           sync.Mutex
+	allocCtx     context.Context
+	cancel       context.CancelFunc
+	browserCtx   context.Context // first tab, keeps the browser open
+	closeBrowser context.CancelFunc
+	config       Config
 }
 
 func NewPool(ctx context.Context, cfg Config) (*Pool, error) {
@@ -52,23 +66,41 @@ func NewPool(ctx context.Context, cfg Config) (*Pool, error) {
 	}, nil
 }
 
+// NewContext opens a new tab, closed by the returned cancel, by parent being
+// done or after the configured timeout.
 func (p *Pool) NewContext(parent context.Context) (context.Context, context.CancelFunc) {
-	p.mu.RLock()
-	defer p.mu.RUnlock()
-
-	ctx, cancel := chromedp.NewContext(p.allocCtx)
+	p.mu.Lock()
+	if p.browserCtx == nil || p.browserCtx.Err() != nil {
+		p.browserCtx, p.closeBrowser = chromedp.NewContext(p.allocCtx)
+		// a failed start is reported again by the tab's first Run
+		_ = chromedp.Run(p.browserCtx)
+	}
+	ctx, closeTab := chromedp.NewContext(p.browserCtx)
+	p.mu.Unlock()
 
+	cancel := closeTab
 	if p.config.Timeout > 0 {
-		ctx, cancel = context.WithTimeout(ctx, p.config.Timeout)
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, p.config.Timeout)
+		cancel = func() { cancelTimeout(); closeTab() }
 	}
 
-	return ctx, cancel
+	if p.config.Throttle != nil {
+		// a failed start is reported again by the tab's first Run
+		_ = throttle(ctx, p.config.Throttle, p.config.ThrottleHost)
+	}
+
+	stop := context.AfterFunc(parent, cancel)
+	return ctx, func() { stop(); cancel() }
 }
 
 func (p *Pool) Close() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	if p.closeBrowser != nil {
+		p.closeBrowser()
+	}
 	if p.cancel != nil {
 		p.cancel()
 	}
@@ -94,6 +126,13 @@ func buildAllocatorOptions(cfg Config) []chromedp.ExecAllocatorOption {
 		chromedp.WindowSize(cfg.WindowWidth, cfg.WindowHeight),
 	)
 
+	if cfg.DevToolsPort > 0 {
+		opts = append(opts,
+			chromedp.Flag("remote-debugging-port", strconv.Itoa(cfg.DevToolsPort)),
+			chromedp.Flag("remote-debugging-address", "127.0.0.1"),
+		)
+	}
+
 	if cfg.UserAgent != "" {
 		opts = append(opts, chromedp.UserAgent(cfg.UserAgent))
 	}
@@ -0,0 +1,81 @@
+package browser
+
+import (
+	"context"
+	"strings"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// Throttler paces the page loads and AJAX requests a tab sends to one host.
+// ratelimit.Pacer implements it.
+type Throttler interface {
+	// Wait blocks until the next request may go out.
+	Wait(ctx context.Context) error
+	// Observe is told the status, Retry-After header and body of every
+	// response to a paced request.
+	Observe(status int, retryAfter string, body []byte)
+}
+
+// pacedTypes are the requests that reach the application server; images,
+// scripts and styles are not paced.
+var pacedTypes = []network.ResourceType{network.ResourceTypeDocument, network.ResourceTypeXHR, network.ResourceTypeFetch}
+
+// throttle pauses every paced request of the tab to host through the Fetch
+// domain until t lets it go, and shows t the responses.
+func throttle(ctx context.Context, t Throttler, host string) error {
+	patterns := make([]*fetch.RequestPattern, len(pacedTypes))
+	for i, rt := range pacedTypes {
+		patterns[i] = &fetch.RequestPattern{URLPattern: "*://" + host + "/*", ResourceType: rt}
+	}
+
+	chromedp.ListenTarget(ctx, func(ev any) {
+		e, ok := ev.(*fetch.EventRequestPaused)
+		if !ok {
+			return
+		}
+		// commands cannot be sent from the listener itself
+		go func() {
+			c := chromedp.FromContext(ctx)
+			if c == nil || c.Target == nil {
+				return
+			}
+			exec := cdp.WithExecutor(ctx, c.Target)
+			switch {
+			case e.ResponseErrorReason != "":
+				_ = fetch.ContinueRequest(e.RequestID).Do(exec)
+				return
+			case e.ResponseStatusCode == 0:
+				if err := t.Wait(ctx); err != nil {
+					_ = fetch.FailRequest(e.RequestID, network.ErrorReasonAborted).Do(exec)
+					return
+				}
+				_ = fetch.ContinueRequest(e.RequestID).WithInterceptResponse(true).Do(exec)
+				return
+			}
+
+			var body []byte
+			if e.ResponseStatusCode < 300 || e.ResponseStatusCode >= 400 {
+				body, _ = fetch.GetResponseBody(e.RequestID).Do(exec)
+			}
+			t.Observe(int(e.ResponseStatusCode), header(e.ResponseHeaders, "Retry-After"), body)
+			if err := fetch.ContinueResponse(e.RequestID).Do(exec); err != nil {
+				// browsers without Fetch.continueResponse resume with continueRequest
+				_ = fetch.ContinueRequest(e.RequestID).Do(exec)
+			}
+		}()
+	})
+	return chromedp.Run(ctx, fetch.Enable().WithPatterns(patterns))
+}
+
+func header(headers []*fetch.HeaderEntry, name string) string {
+	for _, h := range headers {
+		if strings.EqualFold(h.Name, name) {
+			return h.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,204 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+const pollInterval = 100 * time.Millisecond
+
+// WaitError is returned when a wait action times out. State holds a snapshot
+// of the page (URL, ready state, pending AJAX requests) taken at the deadline.
+type WaitError struct {
+	What    string
+	Timeout time.Duration
+	State   PageState
+	Last    error
+}
+
+func (e *WaitError) Error() string {
+	msg := fmt.Sprintf("timed out after %s waiting for %s (url=%s readyState=%s pfQueue=%d jQueryActive=%d)",
+		e.Timeout, e.What, e.State.URL, e.State.ReadyState, e.State.QueueLength, e.State.JQueryActive)
+	if e.Last != nil {
+		msg += ": " + e.Last.Error()
+	}
+	return msg
+}
+
+func (e *WaitError) Unwrap() error {
+	return e.Last
+}
+
+// PageState is the diagnostic snapshot attached to a WaitError.
+type PageState struct {
+	URL          string `json:"url"`
+	ReadyState   string `json:"ready_state"`
+	QueueLength  int    `json:"queue_length"`
+	JQueryActive int    `json:"jquery_active"`
+}
+
+// WaitAjaxIdle waits until the document is complete and both the PrimeFaces
+// AJAX queue and jQuery.active are empty. Pages without PrimeFaces or jQuery
+// only wait for the ready state. Navigations while waiting are tolerated.
+func WaitAjaxIdle(timeout time.Duration) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		return pollUntil(ctx, "AJAX idle", timeout, func(ctx context.Context) (bool, error) {
+			var idle bool
+			err := chromedp.Evaluate(ajaxIdleExpr, &idle).Do(ctx)
+			return idle, err
+		})
+	})
+}
+
+// WaitPartialUpdate runs trigger and waits until the component whose client id
+// is, or ends with, componentID has been re-rendered by a JSF partial update
+// and the AJAX queue is empty again. The component is tagged and observed
+// before the trigger fires: a PrimeFaces update either swaps the element,
+// dropping the tag, or replaces its content in place, as data tables do when
+// paging. A component that disappears, as when the trigger navigates away,
+// also ends the wait once the new page is idle.
+func WaitPartialUpdate(componentID string, trigger chromedp.Action, timeout time.Duration) chromedp.Action {
+	return waitPartialUpdate(domComponents{}, componentID, trigger, timeout)
+}
+
+// componentProbe tags a component before a partial update and later reports
+// whether that update has landed.
+type componentProbe interface {
+	mark(ctx context.Context, id, token string) (bool, error)
+	updated(ctx context.Context, id, token string) (bool, error)
+}
+
+func waitPartialUpdate(probe componentProbe, componentID string, trigger chromedp.Action, timeout time.Duration) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		token := strconv.FormatInt(time.Now().UnixNano(), 36)
+
+		marked, err := probe.mark(ctx, componentID, token)
+		if err != nil {
+			return fmt.Errorf("mark component %s: %w", componentID, err)
+		}
+		if !marked {
+			return fmt.Errorf("component *%s not found", componentID)
+		}
+
+		if err := trigger.Do(ctx); err != nil {
+			return err
+		}
+
+		return pollUntil(ctx, "partial update of "+componentID, timeout, func(ctx context.Context) (bool, error) {
+			return probe.updated(ctx, componentID, token)
+		})
+	})
+}
+
+// domComponents is the componentProbe backed by the page.
+type domComponents struct{}
+
+func (domComponents) mark(ctx context.Context, id, token string) (bool, error) {
+	var marked bool
+	err := CallFunction(markComponentFn, &marked, id, token).Do(ctx)
+	return marked, err
+}
+
+func (domComponents) updated(ctx context.Context, id, token string) (bool, error) {
+	var updated bool
+	err := CallFunction(componentUpdatedFn, &updated, id, token).Do(ctx)
+	return updated, err
+}
+
+// WaitSelector waits until sel matches a visible element.
+func WaitSelector(sel string, timeout time.Duration) chromedp.Action {
+	return waitSelector(sel, true, timeout)
+}
+
+// WaitSelectorGone waits until no visible element matches sel.
+func WaitSelectorGone(sel string, timeout time.Duration) chromedp.Action {
+	return waitSelector(sel, false, timeout)
+}
+
+func waitSelector(sel string, present bool, timeout time.Duration) chromedp.Action {
+	what := "selector " + sel
+	if !present {
+		what += " to disappear"
+	}
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		return pollUntil(ctx, what, timeout, func(ctx context.Context) (bool, error) {
+			var visible bool
+			err := CallFunction(selectorVisibleFn, &visible, sel).Do(ctx)
+			return visible == present, err
+		})
+	})
+}
+
+// pollUntil calls check every pollInterval until it reports true or timeout
+// elapses. Evaluation errors (e.g. the execution context being destroyed by a
+// navigation) are retried and reported only if the wait times out.
+func pollUntil(ctx context.Context, what string, timeout time.Duration, check func(context.Context) (bool, error)) error {
+	deadline := time.Now().Add(timeout)
+	var last error
+
+	for {
+		ok, err := check(ctx)
+		if err == nil && ok {
+			return nil
+		}
+		if err != nil {
+			last = err
+		}
+
+		if time.Now().After(deadline) {
+			return &WaitError{What: what, Timeout: timeout, State: pageState(ctx), Last: last}
+		}
+		if err := sleepContext(ctx, pollInterval); err != nil {
+			return err
+		}
+	}
+}
+
+func pageState(ctx context.Context) PageState {
+	var state PageState
+	_ = chromedp.Evaluate(pageStateExpr, &state).Do(ctx)
+	return state
+}
+
+const ajaxIdleExpr = `(() => {
+	const queue = window.PrimeFaces?.ajax?.Queue;
+	const queueEmpty = !queue || (typeof queue.isEmpty === 'function' ? queue.isEmpty() : !(queue.requests?.length));
+	const jqIdle = !window.jQuery || window.jQuery.active === 0;
+	return document.readyState === 'complete' && queueEmpty && jqIdle;
+})()`
+
+const pageStateExpr = `(() => ({
+	url: window.location.href,
+	ready_state: document.readyState,
+	queue_length: window.PrimeFaces?.ajax?.Queue?.requests?.length || 0,
+	jquery_active: window.jQuery?.active || 0
+}))()`
+
+const markComponentFn = `function(id, token) {
+	const el = document.getElementById(id) || document.querySelector('[id$="' + CSS.escape(id) + '"]');
+	if (!el) return false;
+	el.__expeditusObserver?.disconnect();
+	el.__expeditusMark = token;
+	el.__expeditusChanged = false;
+	el.__expeditusObserver = new MutationObserver(() => { el.__expeditusChanged = true; });
+	el.__expeditusObserver.observe(el, {childList: true, subtree: true});
+	return true;
+}`
+
+const componentUpdatedFn = `function(id, token) {
+	const el = document.getElementById(id) || document.querySelector('[id$="' + CSS.escape(id) + '"]');
+	if (el && el.__expeditusMark === token && !el.__expeditusChanged) return false;
+	const queue = window.PrimeFaces?.ajax?.Queue;
+	const queueEmpty = !queue || (typeof queue.isEmpty === 'function' ? queue.isEmpty() : !(queue.requests?.length));
+	if (document.readyState !== 'complete' || !queueEmpty || (window.jQuery && window.jQuery.active !== 0)) return false;
+	el?.__expeditusObserver?.disconnect();
+	return true;
+}`
+
+const selectorVisibleFn = `function(sel) {
+	return Array.from(document.querySelectorAll(sel)).some(el => el.offsetParent !== null || el.getClientRects().length > 0);
+}`
@@ -0,0 +1,138 @@
+package browser
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+func TestPollUntil(t *testing.T) {
+	errGone := errors.New("execution context was destroyed")
+	tests := []struct {
+		name    string
+		results []error // nil means ready; a non-nil error means a failed check
+		timeout time.Duration
+		wantErr bool
+		last    error
+	}{
+		{"ready at once", []error{nil}, time.Second, false, nil},
+		{"errors are retried", []error{errGone, errGone, nil}, time.Second, false, nil},
+		{"not ready", nil, 250 * time.Millisecond, true, nil},
+		{"last error kept", []error{errGone}, 250 * time.Millisecond, true, errGone},
+	}
+	for _, tt := range tests {
+		calls := 0
+		check := func(context.Context) (bool, error) {
+			defer func() { calls++ }()
+			if calls >= len(tt.results) {
+				return false, nil
+			}
+			return tt.results[calls] == nil, tt.results[calls]
+		}
+
+		err := pollUntil(context.Background(), "thing", tt.timeout, check)
+		if !tt.wantErr {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+			if calls != len(tt.results) {
+				t.Errorf("%s: %d checks, want %d", tt.name, calls, len(tt.results))
+			}
+			continue
+		}
+
+		var waitErr *WaitError
+		if !errors.As(err, &waitErr) {
+			t.Errorf("%s: got %v, want a WaitError", tt.name, err)
+			continue
+		}
+		if waitErr.What != "thing" || waitErr.Timeout != tt.timeout || waitErr.Last != tt.last {
+			t.Errorf("%s: got %+v", tt.name, waitErr)
+		}
+	}
+}
+
+func TestPollUntilCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	err := pollUntil(ctx, "thing", time.Minute, func(context.Context) (bool, error) { return false, nil })
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("cancel took %s", elapsed)
+	}
+}
+
+// fakeComponent is a componentProbe for one component. It reports the update
+// after the trigger has fired and updateAfter more checks have run.
+type fakeComponent struct {
+	id          string
+	token       string
+	triggered   bool
+	updateAfter int
+}
+
+func (c *fakeComponent) mark(_ context.Context, id, token string) (bool, error) {
+	if id != c.id {
+		return false, nil
+	}
+	c.token = token
+	return true, nil
+}
+
+func (c *fakeComponent) updated(_ context.Context, id, token string) (bool, error) {
+	if !c.triggered || token != c.token || c.updateAfter < 0 {
+		return false, nil
+	}
+	c.updateAfter--
+	return c.updateAfter < 0, nil
+}
+
+func TestWaitPartialUpdate(t *testing.T) {
+	errClick := errors.New("click failed")
+	tests := []struct {
+		name       string
+		component  string
+		update     int // checks after the trigger before the update lands; -1 never
+		triggerErr error
+		wantErr    bool
+		waitErr    bool
+	}{
+		{"updated", "form:results", 2, nil, false, false},
+		{"missing component", "form:other", 0, nil, true, false},
+		{"trigger fails", "form:results", 0, errClick, true, false},
+		{"never updated", "form:results", -1, nil, true, true},
+	}
+	for _, tt := range tests {
+		c := &fakeComponent{id: "form:results", updateAfter: tt.update}
+		trigger := chromedp.ActionFunc(func(context.Context) error {
+			if c.token == "" {
+				t.Errorf("%s: trigger fired before the component was marked", tt.name)
+			}
+			c.triggered = true
+			return tt.triggerErr
+		})
+
+		err := waitPartialUpdate(c, tt.component, trigger, 300*time.Millisecond).Do(context.Background())
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		var waitErr *WaitError
+		if errors.As(err, &waitErr) != tt.waitErr {
+			t.Errorf("%s: err = %v, want a WaitError %v", tt.name, err, tt.waitErr)
+		}
+		if tt.triggerErr != nil && !errors.Is(err, tt.triggerErr) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.triggerErr)
+		}
+		if tt.component != c.id && c.triggered {
+			t.Errorf("%s: trigger fired without a component to watch", tt.name)
+		}
+	}
+}
@@ -2,9 +2,15 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"ExpeditusClient/internal/ratelimit"
 
 	"github.com/joho/godotenv"
 )
@@ -37,6 +43,150 @@ func LoadLoginConfig() (*LoginConfig, error) {
 	return cfg, nil
 }
 
+// NotifyConfig holds where price alerts are delivered.
+type NotifyConfig struct {
+	WebhookURL   string
+	SMTPAddr     string // host:port
+	SMTPUser     string
+	SMTPPassword string
+	MailFrom     string
+	MailTo       []string
+}
+
+// LoadNotifyConfig loads the alert destinations from environment variables,
+// also read from the .env file.
+func LoadNotifyConfig() *NotifyConfig {
+	loadEnvFile()
+
+	cfg := &NotifyConfig{
+		WebhookURL:   os.Getenv("ALERT_WEBHOOK_URL"),
+		SMTPUser:     os.Getenv("SMTP_USER"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+		MailFrom:     getEnvOrDefault("ALERT_EMAIL_FROM", os.Getenv("SMTP_USER")),
+	}
+	if host := os.Getenv("SMTP_HOST"); host != "" {
+		cfg.SMTPAddr = net.JoinHostPort(host, getEnvOrDefault("SMTP_PORT", "587"))
+	}
+	for _, to := range strings.Split(os.Getenv("ALERT_EMAIL_TO"), ",") {
+		if to = strings.TrimSpace(to); to != "" {
+			cfg.MailTo = append(cfg.MailTo, to)
+		}
+	}
+	return cfg
+}
+
+// LoadRateLimitConfig loads the supplier politeness settings from environment
+// variables, also read from the .env file: DELFOS_RATE_LIMIT and
+// DELFOS_ACCOUNT_RATE_LIMIT in requests per minute (0 disables them),
+// DELFOS_DELAY as "1s-3s" and DELFOS_BACKOFF as "30s".
+func LoadRateLimitConfig() (ratelimit.Config, error) {
+	loadEnvFile()
+
+	cfg := ratelimit.DefaultConfig()
+	perMinute := func(key string, limit *ratelimit.Limit) error {
+		v := os.Getenv(key)
+		if v == "" {
+			return nil
+		}
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil || n < 0 {
+			return fmt.Errorf("%s: want requests per minute, got %q", key, v)
+		}
+		limit.PerMinute = n
+		return nil
+	}
+	if err := perMinute("DELFOS_RATE_LIMIT", &cfg.Global); err != nil {
+		return cfg, err
+	}
+	if err := perMinute("DELFOS_ACCOUNT_RATE_LIMIT", &cfg.Account); err != nil {
+		return cfg, err
+	}
+	if v := os.Getenv("DELFOS_DELAY"); v != "" {
+		lo, hi, ranged := strings.Cut(v, "-")
+		if !ranged {
+			hi = lo
+		}
+		minDelay, err1 := time.ParseDuration(strings.TrimSpace(lo))
+		maxDelay, err2 := time.ParseDuration(strings.TrimSpace(hi))
+		if err1 != nil || err2 != nil || minDelay < 0 || maxDelay < minDelay {
+			return cfg, fmt.Errorf("DELFOS_DELAY: want a range like 1s-3s, got %q", v)
+		}
+		cfg.MinDelay, cfg.MaxDelay = minDelay, maxDelay
+	}
+	if v := os.Getenv("DELFOS_BACKOFF"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return cfg, fmt.Errorf("DELFOS_BACKOFF: want a duration like 30s, got %q", v)
+		}
+		cfg.Backoff = d
+	}
+	return cfg, nil
+}
+
+// HandoffConfig controls how a CAPTCHA is handed to a human.
+type HandoffConfig struct {
+	DevToolsPort int           // serves the tabs' DevTools on 127.0.0.1; 0 disables the handoff
+	Wait         time.Duration // how long a human has to solve the challenge
+}
+
+// LoadHandoffConfig loads EXPEDITUS_DEVTOOLS_PORT and EXPEDITUS_CAPTCHA_WAIT
+// (default 5m) from environment variables, also read from the .env file.
+func LoadHandoffConfig() (HandoffConfig, error) {
+	loadEnvFile()
+
+	cfg := HandoffConfig{Wait: 5 * time.Minute}
+	if v := os.Getenv("EXPEDITUS_DEVTOOLS_PORT"); v != "" {
+		port, err := strconv.Atoi(v)
+		if err != nil || port < 1 || port > 65535 {
+			return cfg, fmt.Errorf("EXPEDITUS_DEVTOOLS_PORT: want a port number, got %q", v)
+		}
+		cfg.DevToolsPort = port
+	}
+	if v := os.Getenv("EXPEDITUS_CAPTCHA_WAIT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return cfg, fmt.Errorf("EXPEDITUS_CAPTCHA_WAIT: want a duration like 5m, got %q", v)
+		}
+		cfg.Wait = d
+	}
+	return cfg, nil
+}
+
+// APIToken returns EXPEDITUS_API_TOKEN, the bearer token clients of the serve
+// API must send, also read from the .env file.
+func APIToken() string {
+	loadEnvFile()
+	return os.Getenv("EXPEDITUS_API_TOKEN")
+}
+
+// CacheDir returns the directory for local caches such as resolved destinations.
+// It uses EXPEDITUS_CACHE_DIR when set and falls back to the user cache directory.
+func CacheDir() (string, error) {
+	if dir := os.Getenv("EXPEDITUS_CACHE_DIR"); dir != "" {
+		return dir, nil
+	}
+
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("locate cache directory: %w", err)
+	}
+	return filepath.Join(base, "expeditus"), nil
+}
+
+// DataDir returns the directory for persistent data such as the price history.
+// It uses EXPEDITUS_DATA_DIR when set and falls back to ~/.local/share/expeditus.
+func DataDir() (string, error) {
+	if dir := os.Getenv("EXPEDITUS_DATA_DIR"); dir != "" {
+		return dir, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("locate data directory: %w", err)
+	}
+	return filepath.Join(home, ".local", "share", "expeditus"), nil
+}
+
 // loadEnvFile attempts to load the .env file from the project root.
 // It searches relative to this file's location to find the project root.
 func loadEnvFile() {
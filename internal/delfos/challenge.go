@@ -0,0 +1,91 @@
+package delfos
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// challengePoll is how often WaitChallengeSolved looks at the page.
+const challengePoll = 2 * time.Second
+
+// Challenge is a CAPTCHA or bot check shown instead of the expected page.
+type Challenge struct {
+	Kind string `json:"kind"` // recaptcha, hcaptcha, turnstile, cloudflare or captcha
+	URL  string `json:"url"`
+}
+
+// DetectChallenge looks for a visible challenge on the current page of the
+// tab and returns nil when there is none.
+func DetectChallenge(ctx context.Context) (*Challenge, error) {
+	ctx, cancel := context.WithTimeout(ctx, diagnoseTimeout)
+	defer cancel()
+	var c Challenge
+	if err := chromedp.Run(ctx, chromedp.Evaluate(buildChallengeScript(), &c)); err != nil {
+		return nil, fmt.Errorf("detect challenge: %w", err)
+	}
+	if c.Kind == "" {
+		return nil, nil
+	}
+	return &c, nil
+}
+
+// WaitChallengeSolved waits up to timeout for the page to move past c: the
+// challenge is gone or the tab is on another URL.
+func WaitChallengeSolved(ctx context.Context, c *Challenge, timeout time.Duration) error {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	tick := time.NewTicker(challengePoll)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline.C:
+			return fmt.Errorf("%s challenge on %s not solved within %s", c.Kind, c.URL, timeout)
+		case <-tick.C:
+		}
+		current, err := DetectChallenge(ctx)
+		if err != nil {
+			// the page is navigating; look again on the next tick
+			continue
+		}
+		if current == nil || current.URL != c.URL {
+			return nil
+		}
+	}
+}
+
+func buildChallengeScript() string {
+	return `(() => {
+		const visible = (el) => {
+			if (!el || el.offsetParent === null) return false;
+			const r = el.getBoundingClientRect();
+			return r.width > 0 && r.height > 0;
+		};
+		const any = (sel) => Array.from(document.querySelectorAll(sel)).some(visible);
+		const result = (kind) => ({ kind: kind, url: window.location.href });
+
+		// ANCHOR: Cloudflare interstitial ("Just a moment...") replaces the whole page
+		if (document.getElementById('challenge-form') || document.getElementById('cf-challenge-running') ||
+			/^just a moment/i.test(document.title)) {
+			return result('cloudflare');
+		}
+
+		// ANCHOR: Widget iframes; invisible reCAPTCHA v3 badges only count once they open a challenge
+		if (any('iframe[src*="challenges.cloudflare.com"]')) return result('turnstile');
+		if (any('iframe[src*="hcaptcha.com"]')) return result('hcaptcha');
+		if (any('iframe[src*="recaptcha/api2/bframe"], iframe[src*="recaptcha/enterprise/bframe"]') ||
+			Array.from(document.querySelectorAll('iframe[src*="recaptcha"][src*="anchor"]'))
+				.some(f => visible(f) && !f.src.includes('size=invisible'))) {
+			return result('recaptcha');
+		}
+
+		// ANCHOR: Home-grown image CAPTCHAs in JSF forms
+		if (any('[id*="captcha" i] img, img[src*="captcha" i], input[name*="captcha" i]')) return result('captcha');
+
+		return result('');
+	})()`
+}
@@ -0,0 +1,143 @@
+package delfos
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"syscall"
+	"time"
+
+	"ExpeditusClient/internal/browser"
+	"ExpeditusClient/internal/ratelimit"
+	"ExpeditusClient/internal/retry"
+
+	"github.com/chromedp/chromedp"
+)
+
+// diagnoseTimeout bounds the look at the page after a failed step.
+const diagnoseTimeout = 5 * time.Second
+
+// PageDiagnosis is what the page left by a failed step shows.
+type PageDiagnosis struct {
+	URL         string `json:"url"`
+	Status      int    `json:"status"` // HTTP status of the document, 0 if unknown
+	LoggedIn    bool   `json:"logged_in"`
+	LoginShown  bool   `json:"login_shown"`
+	Expired     bool   `json:"expired"`
+	Maintenance bool   `json:"maintenance"`
+	TooMany     bool   `json:"too_many"`
+}
+
+// Diagnose inspects the current page of the tab.
+func Diagnose(ctx context.Context) (PageDiagnosis, error) {
+	ctx, cancel := context.WithTimeout(ctx, diagnoseTimeout)
+	defer cancel()
+	var d PageDiagnosis
+	err := chromedp.Run(ctx, chromedp.Evaluate(buildDiagnoseScript(), &d))
+	return d, err
+}
+
+// Classify maps the failure of a browser step to its retry class, looking at
+// the page when the error alone does not tell.
+func Classify(ctx context.Context, err error) retry.Class {
+	if ctx.Err() != nil {
+		return retry.ClassCanceled
+	}
+	var loginErr *LoginError
+	if errors.As(err, &loginErr) {
+		switch loginErr.Outcome.Status {
+		case LoginInvalidCredentials:
+			return retry.ClassInvalidCredentials
+		case LoginAccountLocked:
+			return retry.ClassForbidden
+		case LoginCaptchaRequired:
+			return retry.ClassCaptcha
+		case LoginMaintenance:
+			return retry.ClassServerError
+		}
+	}
+	if isConnectionError(err) {
+		return retry.ClassConnection
+	}
+
+	if c, _ := DetectChallenge(ctx); c != nil {
+		return retry.ClassCaptcha
+	}
+	if d, diagErr := Diagnose(ctx); diagErr == nil {
+		switch {
+		case d.Status == 429 || d.TooMany:
+			return retry.ClassRateLimited
+		case d.Status >= 500 || d.Maintenance:
+			return retry.ClassServerError
+		case d.Status == 403:
+			return retry.ClassForbidden
+		case d.Status == 401 || d.Expired || (d.LoginShown && !d.LoggedIn):
+			return retry.ClassSessionExpired
+		}
+	}
+
+	var waitErr *browser.WaitError
+	if errors.As(err, &waitErr) || errors.Is(err, context.DeadlineExceeded) {
+		return retry.ClassTimeout
+	}
+	if ratelimit.TooManyRequests(0, []byte(err.Error())) {
+		return retry.ClassRateLimited
+	}
+	return retry.ClassOther
+}
+
+// connectionErrors are the Chromium net errors and messages of a dropped
+// connection.
+var connectionErrors = []string{
+	"net::ERR_CONNECTION_RESET", "net::ERR_CONNECTION_CLOSED", "net::ERR_CONNECTION_REFUSED",
+	"net::ERR_CONNECTION_TIMED_OUT", "net::ERR_TIMED_OUT", "net::ERR_EMPTY_RESPONSE",
+	"net::ERR_NETWORK_CHANGED", "net::ERR_INTERNET_DISCONNECTED", "net::ERR_NAME_NOT_RESOLVED",
+	"net::ERR_HTTP2_PROTOCOL_ERROR", "net::ERR_SSL_PROTOCOL_ERROR",
+	"connection reset by peer", "broken pipe", "unexpected EOF",
+}
+
+func isConnectionError(err error) bool {
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	msg := err.Error()
+	for _, s := range connectionErrors {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func buildDiagnoseScript() string {
+	return `(() => {
+		const visible = (el) => !!el && el.offsetParent !== null;
+		const body = (document.body?.innerText || '').toLowerCase();
+
+		// ANCHOR: HTTP status of the document (Chromium 109+)
+		const nav = performance.getEntriesByType('navigation')[0];
+
+		// ANCHOR: Same logout markers as buildVerifyScript
+		const logoutWords = ['cerrar sesión', 'cerrar sesion', 'salir', 'logout', 'desconectar'];
+		const logout = Array.from(document.querySelectorAll('a, button, [role="button"]')).some(el => {
+			const href = (el.getAttribute('href') || '').toLowerCase();
+			const id = (el.id || '').toLowerCase();
+			const label = (el.innerText || el.textContent || '').trim().toLowerCase();
+			return href.includes('logout') || id.includes('logout') || logoutWords.includes(label);
+		});
+
+		// ANCHOR: JSF ViewExpiredException and session timeout notices
+		const expired = body.includes('viewexpired') || body.includes('sesión ha expirado') ||
+			body.includes('sesion ha expirado') || body.includes('sesión expirada') || body.includes('session expired');
+
+		return {
+			url: window.location.href,
+			status: nav?.responseStatus || 0,
+			logged_in: logout,
+			login_shown: visible(document.getElementById('openLogin')) || visible(document.querySelector('input[type="password"]')),
+			expired: expired,
+			maintenance: body.includes('mantenimiento') || body.includes('maintenance'),
+			too_many: /demasiadas\s+(solicitudes|peticiones|consultas)|too many requests/.test(body)
+		};
+	})()`
+}
@@ -0,0 +1,362 @@
+package delfos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ExpeditusClient/internal/browser"
+	"ExpeditusClient/internal/jsf"
+	"ExpeditusClient/internal/textnorm"
+
+	"github.com/chromedp/chromedp"
+)
+
+// DestinationType is the kind of place an autocomplete candidate refers to.
+type DestinationType string
+
+const (
+	DestinationCity  DestinationType = "city"
+	DestinationZone  DestinationType = "zone"
+	DestinationHotel DestinationType = "hotel"
+	DestinationOther DestinationType = "other"
+)
+
+const (
+	destinationComponentSuffix = ":destinationOnlyAccommodation"
+	destinationLabelSuffix     = ":destinationOnlyAccommodation_input"
+	destinationCodeSuffix      = ":destinationOnlyAccommodation_hinput"
+	destinationInputSel        = `input[id$=":destinationOnlyAccommodation_input"]`
+	autocompleteItemSel        = `.ui-autocomplete-panel .ui-autocomplete-item`
+)
+
+var autocompleteItemRe = regexp.MustCompile(`(?is)<li\b([^>]*\bui-autocomplete-item\b[^>]*)>(.*?)</li>`)
+
+// Destination is one candidate returned by the destination autocomplete.
+type Destination struct {
+	Code  string          `json:"code"`
+	Name  string          `json:"name"`
+	Type  DestinationType `json:"type"`
+	Score int             `json:"score"`
+}
+
+// DestinationSource queries the site's autocomplete for free text.
+// QueryDestinations (browser) and HTTPClient.QueryDestinations both satisfy it.
+type DestinationSource func(ctx context.Context, text string) ([]Destination, error)
+
+// Resolver turns free text such as "Punta Cana" into ranked destination codes,
+// consulting Cache before querying Source.
+type Resolver struct {
+	Source DestinationSource
+	Cache  *DestinationCache
+}
+
+// Resolve returns the candidates for text, best match first.
+func (r *Resolver) Resolve(ctx context.Context, text string) ([]Destination, error) {
+	key := textnorm.Fold(text)
+	if key == "" {
+		return nil, errors.New("empty destination query")
+	}
+
+	if r.Cache != nil {
+		if cached, ok := r.Cache.Get(key); ok {
+			return cached, nil
+		}
+	}
+
+	candidates, err := r.Source(ctx, text)
+	if err != nil {
+		return nil, fmt.Errorf("query destinations for %q: %w", text, err)
+	}
+	ranked := rankDestinations(text, candidates)
+
+	if r.Cache != nil && len(ranked) > 0 {
+		if err := r.Cache.Put(key, ranked); err != nil {
+			return ranked, fmt.Errorf("cache destinations: %w", err)
+		}
+	}
+	return ranked, nil
+}
+
+// QueryDestinations types text into the destination field of the search form
+// on the current page and reads the autocomplete suggestions.
+func QueryDestinations(ctx context.Context, text string) ([]Destination, error) {
+	typing := browser.HumanTyping()
+	typing.NoBlur = true
+
+	var items []struct {
+		Code string `json:"code"`
+		Name string `json:"name"`
+		Hint string `json:"hint"`
+	}
+	err := chromedp.Run(ctx,
+		browser.TypeText(destinationInputSel, text, typing),
+		browser.WaitSelector(autocompleteItemSel, pageTimeout),
+		browser.WaitAjaxIdle(pageTimeout),
+		chromedp.Evaluate(buildAutocompleteScript(), &items),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	destinations := make([]Destination, 0, len(items))
+	for _, it := range items {
+		if it.Code == "" {
+			continue
+		}
+		destinations = append(destinations, Destination{
+			Code: it.Code,
+			Name: it.Name,
+			Type: classifyDestination(it.Code, it.Hint),
+		})
+	}
+	return destinations, nil
+}
+
+// QueryDestinations fires the autocomplete "query" behavior as a partial
+// request and parses the suggestion list from the response.
+func (c *HTTPClient) QueryDestinations(ctx context.Context, text string) ([]Destination, error) {
+	home, err := c.jsf.Get(ctx, c.cfg.TargetURL)
+	if err != nil {
+		return nil, fmt.Errorf("load home: %w", err)
+	}
+	form := home.FormWith(destinationCodeSuffix)
+	if form == nil {
+		return nil, fmt.Errorf("search form not found in %s", home.URL)
+	}
+	input := form.Find(destinationLabelSuffix)
+	if input == nil {
+		return nil, fmt.Errorf("destination field not found in %s", form.ID)
+	}
+	component := strings.TrimSuffix(input.ID, "_input")
+
+	params := url.Values{}
+	params.Set(component+"_query", text)
+	params.Set(input.Name, text)
+
+	resp, err := c.jsf.Submit(ctx, jsf.PartialRequest{
+		Form:    form,
+		Source:  component,
+		Execute: []string{component},
+		Render:  []string{component},
+		Event:   "query",
+		Params:  params,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.Err(); err != nil {
+		return nil, err
+	}
+
+	markup, ok := resp.Update(destinationComponentSuffix)
+	if !ok {
+		return nil, nil
+	}
+	return parseAutocompleteItems(markup), nil
+}
+
+func parseAutocompleteItems(markup string) []Destination {
+	var destinations []Destination
+	for _, m := range autocompleteItemRe.FindAllStringSubmatch(markup, -1) {
+		attrs := attrMap(m[1])
+		code := attrs["data-item-value"]
+		if code == "" {
+			continue
+		}
+		name := attrs["data-item-label"]
+		if name == "" {
+			name = strings.Join(strings.Fields(stripTags(m[2])), " ")
+		}
+		destinations = append(destinations, Destination{
+			Code: code,
+			Name: name,
+			Type: classifyDestination(code, attrs["class"]+" "+m[2]),
+		})
+	}
+	return destinations
+}
+
+var attrPairRe = regexp.MustCompile(`(?s)([\w:.-]+)\s*=\s*"([^"]*)"`)
+
+func attrMap(s string) map[string]string {
+	attrs := make(map[string]string)
+	for _, m := range attrPairRe.FindAllStringSubmatch(s, -1) {
+		attrs[strings.ToLower(m[1])] = html.UnescapeString(m[2])
+	}
+	return attrs
+}
+
+// classifyDestination infers the candidate type from its code prefix
+// ("Destination::", "Zone::", "Hotel::") and falls back to icon/class hints.
+func classifyDestination(code, hint string) DestinationType {
+	prefix, _, _ := strings.Cut(strings.ToLower(code), "::")
+	switch prefix {
+	case "destination", "city":
+		return DestinationCity
+	case "zone", "area":
+		return DestinationZone
+	case "hotel", "accommodation":
+		return DestinationHotel
+	}
+
+	hint = strings.ToLower(hint)
+	switch {
+	case strings.Contains(hint, "hotel") || strings.Contains(hint, "bed"):
+		return DestinationHotel
+	case strings.Contains(hint, "zone") || strings.Contains(hint, "zona"):
+		return DestinationZone
+	case strings.Contains(hint, "city") || strings.Contains(hint, "ciudad") || strings.Contains(hint, "map-marker"):
+		return DestinationCity
+	}
+	return DestinationOther
+}
+
+// rankDestinations scores candidates by how well their name matches query,
+// preferring cities over zones over hotels. Ties keep the site's order.
+func rankDestinations(query string, candidates []Destination) []Destination {
+	q := textnorm.Fold(query)
+	ranked := make([]Destination, len(candidates))
+	copy(ranked, candidates)
+
+	for i := range ranked {
+		name := textnorm.Fold(ranked[i].Name)
+		head, _, _ := strings.Cut(name, ",")
+		score := 10
+		switch {
+		case name == q || strings.TrimSpace(head) == q:
+			score = 100
+		case strings.HasPrefix(name, q):
+			score = 80
+		case strings.Contains(" "+name, " "+q):
+			score = 60
+		case strings.Contains(name, q):
+			score = 40
+		}
+		switch ranked[i].Type {
+		case DestinationCity:
+			score += 3
+		case DestinationZone:
+			score += 2
+		case DestinationHotel:
+			score++
+		}
+		ranked[i].Score = score
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Score > ranked[j].Score
+	})
+	return ranked
+}
+
+// DestinationCache is a JSON file of resolved queries with a time-to-live.
+type DestinationCache struct {
+	path string
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]destinationCacheEntry
+}
+
+type destinationCacheEntry struct {
+	Fetched time.Time     `json:"fetched"`
+	Results []Destination `json:"results"`
+}
+
+// LoadDestinationCache opens the cache at path. A missing file is an empty cache.
+func LoadDestinationCache(path string, ttl time.Duration) (*DestinationCache, error) {
+	c := &DestinationCache{
+		path:    path,
+		ttl:     ttl,
+		entries: make(map[string]destinationCacheEntry),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read destination cache: %w", err)
+	}
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		return nil, fmt.Errorf("parse destination cache %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Get returns the cached results for key if they have not expired.
+func (c *DestinationCache) Get(key string) ([]Destination, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok || (c.ttl > 0 && time.Since(e.Fetched) > c.ttl) {
+		return nil, false
+	}
+	return e.Results, true
+}
+
+// Put stores results under key and rewrites the cache file.
+func (c *DestinationCache) Put(key string, results []Destination) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = destinationCacheEntry{Fetched: time.Now(), Results: results}
+
+	data, err := json.MarshalIndent(c.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(c.path, data)
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func buildAutocompleteScript() string {
+	return `(() => {
+		// ANCHOR: PrimeFaces renders suggestions as li.ui-autocomplete-item with data-item-value/label
+		const panels = Array.from(document.querySelectorAll('.ui-autocomplete-panel'))
+			.filter(p => p.offsetParent !== null || p.style.display !== 'none');
+		const items = [];
+		for (const panel of panels) {
+			for (const li of panel.querySelectorAll('.ui-autocomplete-item')) {
+				const icon = li.querySelector('i, img, [class*="icon"]');
+				items.push({
+					code: li.dataset.itemValue || '',
+					name: (li.dataset.itemLabel || li.innerText || '').trim(),
+					hint: li.className + ' ' + (icon ? (icon.className || icon.getAttribute('src') || '') : '')
+				});
+			}
+		}
+		return items;
+	})()`
+}
@@ -0,0 +1,67 @@
+package delfos
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseAutocompleteItems(t *testing.T) {
+	markup := `<span id="f:destinationOnlyAccommodation_panel"><ul class="ui-autocomplete-items">
+<li class="ui-autocomplete-item ui-autocomplete-list-item" data-item-value="Hotel::12345" data-item-label="Miami Beach Resort">Miami Beach Resort</li>
+<li class="ui-autocomplete-item ui-autocomplete-list-item" data-item-value="Destination::MIA" data-item-label="Miami, Florida, Estados Unidos">Miami</li>
+<li class="ui-autocomplete-item ui-autocomplete-list-item" data-item-value="Zone::MIA-SB"><i class="fa fa-map"></i> South Beach &amp; Miami</li>
+</ul></span>`
+
+	items := parseAutocompleteItems(markup)
+	if len(items) != 3 {
+		t.Fatalf("got %d items: %+v", len(items), items)
+	}
+	if items[1].Type != DestinationCity || items[0].Type != DestinationHotel || items[2].Type != DestinationZone {
+		t.Errorf("types = %s %s %s", items[0].Type, items[1].Type, items[2].Type)
+	}
+	if items[2].Name != "South Beach & Miami" {
+		t.Errorf("label fallback = %q", items[2].Name)
+	}
+
+	ranked := rankDestinations("miami", items)
+	if ranked[0].Code != "Destination::MIA" {
+		t.Errorf("best = %+v", ranked[0])
+	}
+}
+
+func TestResolverCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "destinations.json")
+	cache, err := LoadDestinationCache(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	r := &Resolver{
+		Cache: cache,
+		Source: func(ctx context.Context, text string) ([]Destination, error) {
+			calls++
+			return []Destination{{Code: "Destination::PUJ", Name: "Punta Cana", Type: DestinationCity}}, nil
+		},
+	}
+
+	for _, q := range []string{"Punta Cana", "punta  caná"} {
+		got, err := r.Resolve(context.Background(), q)
+		if err != nil || len(got) != 1 || got[0].Code != "Destination::PUJ" {
+			t.Fatalf("Resolve(%q) = %+v, %v", q, got, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("source called %d times, want 1", calls)
+	}
+
+	reloaded, err := LoadDestinationCache(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reloaded.Get("punta cana"); !ok {
+		t.Error("cache entry not persisted")
+	}
+}
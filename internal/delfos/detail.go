@@ -0,0 +1,255 @@
+package delfos
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"ExpeditusClient/internal/browser"
+	"ExpeditusClient/internal/money"
+
+	"github.com/chromedp/chromedp"
+)
+
+var deadlineRe = regexp.MustCompile(`(\d{2}/\d{2}/\d{4})(?:\D{1,8}(\d{1,2}:\d{2}))?`)
+
+// RoomRate is one room/board/price combination offered by a hotel.
+type RoomRate struct {
+	Room                  string      `json:"room"`
+	Board                 string      `json:"board,omitempty"`
+	Occupancy             string      `json:"occupancy,omitempty"`
+	NightlyPrice          money.Money `json:"nightly_price,omitzero"`
+	TotalPrice            money.Money `json:"total_price,omitzero"`
+	Refundable            *bool       `json:"refundable,omitempty"`
+	CancellationPolicy    string      `json:"cancellation_policy,omitempty"`
+	CancellationDeadlines []time.Time `json:"cancellation_deadlines,omitempty"`
+	Promotions            []string    `json:"promotions,omitempty"`
+	Remarks               []string    `json:"remarks,omitempty"`
+	Warnings              []string    `json:"warnings,omitempty"` // prices that could not be read
+}
+
+// rawRate is a rate as read from the page, with its prices still as text.
+type rawRate struct {
+	RoomRate
+	NightlyPrice string `json:"nightly_price"`
+	TotalPrice   string `json:"total_price"`
+}
+
+func (r rawRate) rate() RoomRate {
+	rate := r.RoomRate
+	rate.NightlyPrice = parsePrice("nightly price", r.NightlyPrice, &rate.Warnings)
+	rate.TotalPrice = parsePrice("total price", r.TotalPrice, &rate.Warnings)
+	rate.CancellationDeadlines = parseDeadlines(rate.CancellationPolicy)
+	return rate
+}
+
+// HotelDetail is the detail view of a hotel with all of its rates.
+type HotelDetail struct {
+	Hotel Hotel      `json:"hotel"`
+	URL   string     `json:"url"`
+	Rates []RoomRate `json:"rates"`
+}
+
+// HotelDetails opens the detail view of h and extracts every room rate.
+// Hotels with a DetailURL are opened by navigation; otherwise the card is
+// clicked, which requires the results page to be the current page.
+func HotelDetails(ctx context.Context, h Hotel) (*HotelDetail, error) {
+	if err := openHotel(ctx, h); err != nil {
+		return nil, fmt.Errorf("open %s: %w", h.Name, err)
+	}
+
+	var expanded int
+	var raw []rawRate
+	var current string
+	err := chromedp.Run(ctx,
+		chromedp.Evaluate(buildExpandPoliciesScript(), &expanded),
+		browser.WaitAjaxIdle(pageTimeout),
+		browser.BestEffort(browser.WaitDOMStable("body", 500*time.Millisecond, pageTimeout)),
+		chromedp.Evaluate(buildExtractRatesScript(), &raw),
+		chromedp.Location(&current),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("extract rates for %s: %w", h.Name, err)
+	}
+
+	rates := make([]RoomRate, len(raw))
+	for i, r := range raw {
+		rates[i] = r.rate()
+	}
+
+	return &HotelDetail{Hotel: h, URL: current, Rates: rates}, nil
+}
+
+func openHotel(ctx context.Context, h Hotel) error {
+	if h.DetailURL != "" {
+		return chromedp.Run(ctx,
+			browser.BestEffort(browser.NetworkIdle(chromedp.Navigate(h.DetailURL), 750*time.Millisecond, pageTimeout)),
+			chromedp.WaitReady("body", chromedp.ByQuery),
+			browser.WaitAjaxIdle(pageTimeout),
+		)
+	}
+
+	var card struct {
+		Found     bool   `json:"found"`
+		Component string `json:"component"`
+	}
+	if err := chromedp.Run(ctx, browser.CallFunction(locateHotelCardFn, &card, h.Name)); err != nil {
+		return err
+	}
+	if !card.Found {
+		return fmt.Errorf("no result card for %q on the current page", h.Name)
+	}
+
+	click := chromedp.Evaluate(clickHotelCardExpr, nil)
+	if card.Component == "" {
+		return chromedp.Run(ctx,
+			browser.BestEffort(browser.NetworkIdle(click, 750*time.Millisecond, pageTimeout)),
+			chromedp.WaitReady("body", chromedp.ByQuery),
+			browser.WaitAjaxIdle(pageTimeout),
+		)
+	}
+	// the card's form is re-rendered with the detail, or left behind when
+	// the click navigates
+	return chromedp.Run(ctx,
+		browser.BestEffort(browser.WaitPartialUpdate(card.Component, click, pageTimeout)),
+		chromedp.WaitReady("body", chromedp.ByQuery),
+	)
+}
+
+// parseDeadlines returns the dd/MM/yyyy [HH:mm] dates found in a cancellation
+// policy, in chronological order.
+func parseDeadlines(policy string) []time.Time {
+	var deadlines []time.Time
+	seen := make(map[time.Time]bool)
+	for _, m := range deadlineRe.FindAllStringSubmatch(policy, -1) {
+		layout, value := DateLayout, m[1]
+		if m[2] != "" {
+			layout, value = DateLayout+" 15:04", m[1]+" "+m[2]
+		}
+		t, err := time.Parse(layout, value)
+		if err != nil || seen[t] {
+			continue
+		}
+		seen[t] = true
+		deadlines = append(deadlines, t)
+	}
+	sort.Slice(deadlines, func(i, j int) bool { return deadlines[i].Before(deadlines[j]) })
+	return deadlines
+}
+
+const hotelCardSel = `[data-expeditus="hotel-open"]`
+
+const clickHotelCardExpr = `document.querySelector('` + hotelCardSel + `')?.click()`
+
+// locateHotelCardFn tags the control that opens the named hotel's card and
+// returns whether it was found and the client id of the form around it.
+const locateHotelCardFn = `function(name) {
+	const norm = (s) => (s || '').replace(/\s+/g, ' ').trim().toLowerCase();
+	const target = norm(name);
+	document.querySelectorAll('` + hotelCardSel + `').forEach(el => el.removeAttribute('data-expeditus'));
+	const heading = Array.from(document.querySelectorAll('h2, h3, h4, [class*="name" i], [class*="title" i]'))
+		.find(el => el.offsetParent !== null && norm(el.innerText).startsWith(target));
+	if (!heading) return {found: false, component: ''};
+
+	// ANCHOR: Prefer the card's own call to action over the heading link
+	let card = heading;
+	while (card.parentElement && card.parentElement !== document.body && !/Total/i.test(card.innerText)) {
+		card = card.parentElement;
+	}
+	const actions = ['ver habitaciones', 'ver detalle', 'ver hotel', 'seleccionar', 'ver', 'reservar'];
+	const button = Array.from(card.querySelectorAll('a, button, [role="button"]'))
+		.find(el => el.offsetParent !== null && actions.includes(norm(el.innerText)));
+	(button || heading.querySelector('a') || heading).setAttribute('data-expeditus', 'hotel-open');
+	return {found: true, component: card.closest('form[id]')?.id || ''};
+}`
+
+func buildExpandPoliciesScript() string {
+	return `(() => {
+		// ANCHOR: Cancellation policies are often loaded on demand behind a link
+		const toggles = Array.from(document.querySelectorAll('a, button, [role="button"], span[onclick]'))
+			.filter(el => el.offsetParent !== null &&
+				/pol[ií]tica(s)? de cancelaci[oó]n|ver condiciones|gastos de cancelaci[oó]n|condiciones de cancelaci[oó]n/i.test(el.innerText || ''));
+		toggles.slice(0, 40).forEach(el => el.click());
+		return toggles.length;
+	})()`
+}
+
+func buildExtractRatesScript() string {
+	return `(() => {
+		const PRICE = /(?:US\$|U\$S|U\$D|USD|ARS|EUR|€|\$)\s?[\d.,]+/;
+		const PRICE_G = /(?:US\$|U\$S|U\$D|USD|ARS|EUR|€|\$)\s?[\d.,]+/g;
+		const ROWS = 'tr, li, [class*="rate" i], [class*="room" i], [class*="option" i], [class*="tarifa" i]';
+		const clean = (s) => (s || '').replace(/\s+/g, ' ').trim();
+		const visible = (el) => el.offsetParent !== null;
+		const texts = (root, sel) => Array.from(root.querySelectorAll(sel)).filter(visible).map(el => clean(el.innerText)).filter(Boolean);
+		const lines = (el) => (el.innerText || '').split('\n').map(clean).filter(Boolean);
+
+		// ANCHOR: A rate row is the innermost row-like element holding a price
+		const rows = Array.from(document.querySelectorAll(ROWS)).filter(el =>
+			visible(el) && PRICE.test(el.innerText || '') &&
+			!Array.from(el.querySelectorAll(ROWS)).some(child => visible(child) && PRICE.test(child.innerText || '')));
+
+		const boards = [
+			'Todo incluido', 'All inclusive', 'Pensión completa', 'Full board', 'Media pensión', 'Half board',
+			'Alojamiento y desayuno', 'Desayuno incluido', 'Bed and breakfast', 'Desayuno', 'Solo alojamiento', 'Room only', 'Sólo alojamiento'
+		];
+		const roomRe = /habitaci[oó]n|room|suite|doble|double|twin|triple|standard|est[aá]ndar|superior|deluxe|junior|villa|bungalow|studio/i;
+
+		return rows.map(row => {
+			const text = row.innerText || '';
+			const lower = text.toLowerCase();
+			const rowLines = lines(row);
+
+			// the room name may live on an enclosing room block shared by several rates
+			let room = texts(row, '[class*="room-name" i], [class*="roomName" i], [class*="room-type" i]')[0] ||
+				rowLines.find(l => roomRe.test(l) && !PRICE.test(l) && l.length < 120) || '';
+			if (!room) {
+				const block = row.parentElement?.closest('[class*="room" i], section, article, tbody, table');
+				const heading = block && block.querySelector('h2, h3, h4, h5, [class*="name" i], [class*="title" i], caption, th');
+				room = clean((heading?.innerText || '').split('\n')[0]);
+			}
+
+			const prices = (text.match(PRICE_G) || []).map(clean);
+			const totalLine = rowLines.find(l => /total/i.test(l) && PRICE.test(l));
+			const nightLine = rowLines.find(l => /noche|night/i.test(l) && PRICE.test(l));
+			const total = totalLine ? clean(totalLine.match(PRICE)[0]) : prices[prices.length - 1] || '';
+			const nightly = nightLine ? clean(nightLine.match(PRICE)[0]) : '';
+
+			let refundable = null;
+			if (/no reembolsable|non[- ]?refundable|sin reembolso/i.test(text)) {
+				refundable = false;
+			} else if (/reembolsable|cancelaci[oó]n gratuita|free cancellation|cancelaci[oó]n sin cargo/i.test(text)) {
+				refundable = true;
+			}
+
+			const policyParts = texts(row, '[class*="cancel" i], [class*="policy" i], [class*="politica" i]');
+			Array.from(row.querySelectorAll('[title]')).forEach(el => {
+				if (/cancel/i.test(el.title)) policyParts.push(clean(el.title));
+			});
+			rowLines.filter(l => /cancelaci[oó]n|cancellation|gastos/i.test(l)).forEach(l => policyParts.push(l));
+
+			const promotions = texts(row, '[class*="promo" i], [class*="offer" i], [class*="oferta" i], [class*="discount" i], [class*="descuento" i]');
+			rowLines.filter(l => /promo|oferta|descuento|% off|early booking/i.test(l)).forEach(l => promotions.push(l));
+
+			const remarks = texts(row, '[class*="remark" i], [class*="observ" i], [class*="comment" i], [class*="note" i]');
+			rowLines.filter(l => /^(observaciones|notas?|remarks?|importante)\b/i.test(l)).forEach(l => remarks.push(l));
+
+			const occupancy = rowLines.find(l => /\d+\s*(adultos?|adults?|pax|personas?)/i.test(l)) || '';
+
+			return {
+				room: room,
+				board: texts(row, '[class*="board" i], [class*="regimen" i], [class*="meal" i]')[0] ||
+					boards.find(b => lower.includes(b.toLowerCase())) || '',
+				occupancy: occupancy,
+				nightly_price: nightly,
+				total_price: total,
+				refundable: refundable,
+				cancellation_policy: [...new Set(policyParts)].join(' | '),
+				promotions: [...new Set(promotions)],
+				remarks: [...new Set(remarks)]
+			};
+		}).filter(r => r.total_price);
+	})()`
+}
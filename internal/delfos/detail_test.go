@@ -0,0 +1,28 @@
+package delfos
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDeadlines(t *testing.T) {
+	policy := "Gastos de cancelación del 100% desde el 05/05/2026 a las 12:00 | Cancelación gratuita hasta 01/05/2026 | Desde 05/05/2026 12:00"
+
+	got := parseDeadlines(policy)
+	want := []time.Time{
+		time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2026, 5, 5, 12, 0, 0, 0, time.UTC),
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Errorf("deadline %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	if d := parseDeadlines("No reembolsable"); len(d) != 0 {
+		t.Errorf("unexpected deadlines %v", d)
+	}
+}
@@ -0,0 +1,245 @@
+package delfos
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"maps"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"ExpeditusClient/internal/browser"
+	"ExpeditusClient/internal/config"
+	"ExpeditusClient/internal/jsf"
+	"ExpeditusClient/internal/ratelimit"
+)
+
+// Stable tails of the generated JSF client ids used by the direct client.
+const (
+	loginEmailSuffix    = ":login:Email"
+	loginPasswordSuffix = ":login:j_password"
+	loginSigninSuffix   = ":login:signin"
+
+	checkInSuffix   = ":arrivalOnlyAccommodation:input"
+	checkOutSuffix  = ":departureOnlyAccommodation:input"
+	startTripSuffix = ":startTrip"
+)
+
+var (
+	logoutRe      = regexp.MustCompile(`(?is)(href|id)="[^"]*logout[^"]*"|>\s*(cerrar sesi[oó]n|salir|logout)\s*<`)
+	captchaRe     = regexp.MustCompile(`(?i)g-recaptcha|h-captcha|recaptcha/api|hcaptcha\.com|challenges\.cloudflare\.com`)
+	userNameRe    = regexp.MustCompile(`(?is)<[^>]+(?:id|class)="[^"]*(?:userName|loggedUser|user-name|username|user-info)[^"]*"[^>]*>\s*([^<]{1,80}?)\s*<`)
+	errorDetailRe = regexp.MustCompile(`(?is)class="[^"]*ui-messages?-error-(?:summary|detail)[^"]*"[^>]*>([^<]+)<`)
+	growlErrorRe  = regexp.MustCompile(`(?s)summary:"([^"]*)",detail:"([^"]*)",severity:'error'`)
+	tagRe         = regexp.MustCompile(`(?s)<[^>]*>`)
+)
+
+// HTTPClient logs in to Delfos without a browser, speaking JSF partial
+// requests directly. It keeps its own cookie jar and view state, so a value
+// holds one user session and must not be shared between goroutines.
+type HTTPClient struct {
+	cfg *config.LoginConfig
+	jsf *jsf.Client
+}
+
+// NewHTTPClient returns a browserless client for cfg.TargetURL.
+func NewHTTPClient(cfg *config.LoginConfig) (*HTTPClient, error) {
+	client, err := jsf.NewClient(cfg.TargetURL)
+	if err != nil {
+		return nil, err
+	}
+	return &HTTPClient{cfg: cfg, jsf: client}, nil
+}
+
+// Pace sends the client's requests to the site through the same throttle as
+// the browser tabs.
+func (c *HTTPClient) Pace(throttle browser.Throttler) {
+	c.jsf.HTTP.Transport = &ratelimit.Transport{Base: c.jsf.HTTP.Transport, Host: c.jsf.BaseURL.Host, Pacer: throttle}
+}
+
+// SessionID returns the current JSESSIONID cookie.
+func (c *HTTPClient) SessionID() string {
+	return c.jsf.Cookie("JSESSIONID")
+}
+
+// Login submits the login form as a PrimeFaces partial request and verifies
+// the resulting page with the same classification used by VerifyLogin.
+func (c *HTTPClient) Login(ctx context.Context) (LoginOutcome, error) {
+	home, err := c.jsf.Get(ctx, c.cfg.TargetURL)
+	if err != nil {
+		return LoginOutcome{Status: LoginUnknown}, fmt.Errorf("load home: %w", err)
+	}
+
+	form := home.FormWith(loginEmailSuffix)
+	if form == nil {
+		return LoginOutcome{Status: LoginUnknown, URL: home.URL}, fmt.Errorf("login form not found in %s", home.URL)
+	}
+	user, pass, signin := form.Find(loginEmailSuffix), form.Find(loginPasswordSuffix), form.Find(loginSigninSuffix)
+	if pass == nil || signin == nil {
+		return LoginOutcome{Status: LoginUnknown, URL: home.URL}, fmt.Errorf("login form %s is incomplete", form.ID)
+	}
+
+	params := url.Values{}
+	params.Set(user.Name, c.cfg.Username)
+	params.Set(pass.Name, c.cfg.Password)
+
+	resp, err := c.jsf.Submit(ctx, jsf.PartialRequest{
+		Form:    form,
+		Source:  signin.ID,
+		Execute: []string{form.ID},
+		Params:  params,
+	})
+	if err != nil {
+		return LoginOutcome{Status: LoginUnknown}, fmt.Errorf("submit login: %w", err)
+	}
+	if err := resp.Err(); err != nil {
+		return LoginOutcome{Status: LoginUnknown}, fmt.Errorf("submit login: %w", err)
+	}
+
+	messages := updateMessages(resp)
+
+	next := c.cfg.TargetURL
+	if resp.Redirect != "" {
+		next = resp.Redirect
+	}
+	page, err := c.jsf.Get(ctx, next)
+	if err != nil {
+		return LoginOutcome{Status: LoginUnknown}, fmt.Errorf("load page after login: %w", err)
+	}
+
+	signals := htmlLoginSignals(page)
+	signals.Messages = append(messages, signals.Messages...)
+
+	outcome := classifyLogin(signals)
+	if outcome.Status != LoginSuccess {
+		return outcome, &LoginError{Outcome: outcome}
+	}
+	return outcome, nil
+}
+
+// SearchHotels submits req through the home page's accommodation search form
+// as a PrimeFaces partial request and parses the hotel cards of the first
+// results page, from the response itself or from the page it redirects to.
+// The form is loaded from req's directSubmit URL, so the site fills in the
+// occupancy and every other field the URL carries. The client must be logged
+// in.
+func (c *HTTPClient) SearchHotels(ctx context.Context, req SearchRequest) ([]Hotel, error) {
+	if err := req.Validate(time.Now()); err != nil {
+		return nil, err
+	}
+	target, err := req.URL(c.cfg.TargetURL)
+	if err != nil {
+		return nil, err
+	}
+	home, err := c.jsf.Get(ctx, target)
+	if err != nil {
+		return nil, fmt.Errorf("load search form: %w", err)
+	}
+
+	form := home.FormWith(destinationCodeSuffix)
+	if form == nil {
+		return nil, fmt.Errorf("search form not found in %s", home.URL)
+	}
+	// the search button is a command link, not a form control
+	startTrip := home.FindID(startTripSuffix)
+	if startTrip == "" {
+		return nil, fmt.Errorf("search button not found in %s", home.URL)
+	}
+
+	label := req.DestinationName
+	if label == "" {
+		label = req.DestinationCode()
+	}
+	params := url.Values{}
+	for suffix, value := range map[string]string{
+		destinationLabelSuffix: label,
+		destinationCodeSuffix:  req.DestinationCode(),
+		checkInSuffix:          req.CheckIn.Format(DateLayout),
+		checkOutSuffix:         req.CheckOut.Format(DateLayout),
+	} {
+		in := form.Find(suffix)
+		if in == nil {
+			return nil, fmt.Errorf("search field *%s not found in %s", suffix, form.ID)
+		}
+		params.Set(in.Name, value)
+	}
+
+	resp, err := c.jsf.Submit(ctx, jsf.PartialRequest{
+		Form:    form,
+		Source:  startTrip,
+		Execute: []string{form.ID},
+		Params:  params,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("submit search: %w", err)
+	}
+	if err := resp.Err(); err != nil {
+		return nil, fmt.Errorf("submit search: %w", err)
+	}
+	if resp.ValidationFailed() {
+		return nil, fmt.Errorf("search rejected: %s", strings.Join(updateMessages(resp), " | "))
+	}
+
+	if resp.Redirect != "" {
+		page, err := c.jsf.Get(ctx, resp.Redirect)
+		if err != nil {
+			return nil, fmt.Errorf("load results: %w", err)
+		}
+		return parseHotelCards(page.URL, page.Body), nil
+	}
+	var hotels []Hotel
+	for _, id := range slices.Sorted(maps.Keys(resp.Updates)) {
+		hotels = append(hotels, parseHotelCards(home.URL, resp.Updates[id])...)
+	}
+	return hotels, nil
+}
+
+// updateMessages returns the error messages rendered by the updates of resp.
+func updateMessages(resp *jsf.PartialResponse) []string {
+	var messages []string
+	for _, id := range slices.Sorted(maps.Keys(resp.Updates)) {
+		messages = append(messages, errorMessages(resp.Updates[id])...)
+	}
+	return messages
+}
+
+// htmlLoginSignals derives login markers from server-rendered markup. Unlike the
+// in-browser check it cannot see visibility, so the password field is ignored
+// and the "Entrar" trigger is the negative marker.
+func htmlLoginSignals(page *jsf.Page) loginSignals {
+	body := page.Body
+	lower := strings.ToLower(stripTags(body))
+
+	s := loginSignals{
+		URL:             page.URL,
+		HasLogout:       logoutRe.MatchString(body),
+		HasLoginTrigger: strings.Contains(body, `id="openLogin"`),
+		Messages:        errorMessages(body),
+		Captcha:         captchaRe.MatchString(body),
+		Maintenance:     strings.Contains(lower, "mantenimiento"),
+	}
+	if m := userNameRe.FindStringSubmatch(body); m != nil {
+		s.UserName = html.UnescapeString(m[1])
+	}
+	return s
+}
+
+func errorMessages(markup string) []string {
+	var messages []string
+	for _, m := range errorDetailRe.FindAllStringSubmatch(markup, -1) {
+		if msg := strings.TrimSpace(html.UnescapeString(m[1])); msg != "" {
+			messages = append(messages, msg)
+		}
+	}
+	for _, m := range growlErrorRe.FindAllStringSubmatch(markup, -1) {
+		messages = append(messages, strings.TrimSpace(m[1]+" "+m[2]))
+	}
+	return messages
+}
+
+func stripTags(s string) string {
+	return html.UnescapeString(tagRe.ReplaceAllString(s, " "))
+}
@@ -0,0 +1,241 @@
+package delfos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ExpeditusClient/internal/config"
+	"ExpeditusClient/internal/money"
+)
+
+const (
+	testLoginForm = `<html><body><a id="openLogin" href="#">Entrar</a>
+<form id="j_id_4s:login" name="j_id_4s:login" method="post" action="/home.xhtml">
+	<input type="hidden" name="j_id_4s:login" value="j_id_4s:login" />
+	<input id="j_id_4s:login:Email" name="j_id_4s:login:Email" type="text" />
+	<input id="j_id_4s:login:j_password" name="j_id_4s:login:j_password" type="password" />
+	<button id="j_id_4s:login:signin" name="j_id_4s:login:signin" type="submit">Iniciar sesión</button>
+	<input type="hidden" name="javax.faces.ViewState" id="j_id1:javax.faces.ViewState:0" value="vs-1" />
+</form></body></html>`
+	testHome = `<html><body><span id="userName">JPARDO</span><a href="/logout">Cerrar sesión</a></body></html>`
+
+	testPartial = `<?xml version="1.0" encoding="UTF-8"?>
+<partial-response id="j_id1"><changes>%s<update id="j_id1:javax.faces.ViewState:0"><![CDATA[vs-2]]></update></changes>%s</partial-response>`
+	testBadCredentials = `<update id="j_id_4s:login:messages"><![CDATA[<span class="ui-messages-error-detail">Usuario o contraseña incorrectos</span>]]></update>`
+
+	// testSearchForm is the home page as rendered for a directSubmit URL,
+	// with the occupancy prefilled from the distribution parameter.
+	testSearchForm = `<html><body><a href="/logout">Cerrar sesión</a>
+<form id="j_id_4s:search" name="j_id_4s:search" method="post" action="/home.xhtml">
+	<input type="hidden" name="j_id_4s:search" value="j_id_4s:search" />
+	<input id="j_id_4s:search:destinationOnlyAccommodation_input" name="j_id_4s:search:destinationOnlyAccommodation_input" type="text" value="" />
+	<input id="j_id_4s:search:destinationOnlyAccommodation_hinput" name="j_id_4s:search:destinationOnlyAccommodation_hinput" type="hidden" value="" />
+	<input id="j_id_4s:search:arrivalOnlyAccommodation:input" name="j_id_4s:search:arrivalOnlyAccommodation:input" type="text" value="" />
+	<input id="j_id_4s:search:departureOnlyAccommodation:input" name="j_id_4s:search:departureOnlyAccommodation:input" type="text" value="" />
+	<input id="j_id_4s:search:distribution" name="j_id_4s:search:distribution" type="hidden" value="%s" />
+	<a id="j_id_4s:search:startTrip" href="#" class="ui-commandlink" onclick="PrimeFaces.ab({s:&quot;j_id_4s:search:startTrip&quot;});return false;">Buscar</a>
+	<input type="hidden" name="javax.faces.ViewState" id="j_id1:javax.faces.ViewState:0" value="vs-3" />
+</form></body></html>`
+
+	// testSearchResults is a partial response recorded from a hotel search,
+	// trimmed to four result items: two hotels, a package promotion and an
+	// item without a price.
+	testSearchResults = `<?xml version="1.0" encoding="UTF-8"?>
+<partial-response id="j_id1"><changes><update id="j_id_4s:results"><![CDATA[<div id="j_id_4s:results" class="ui-datagrid ui-widget"><div class="ui-datagrid-content ui-widget-content"><div class="ui-datagrid-row">
+<div class="ui-datagrid-column ui-g-12"><div class="hotel-card">
+	<h3 class="hotel-name"><a href="/hotel/123">Meliá Caribe Beach</a></h3>
+	<span class="stars-4"></span>
+	<span class="hotel-zone">Bávaro</span>
+	<div class="room-name">Habitación Doble Superior</div>
+	<div class="board">Todo incluido</div>
+	<span>Cancelación gratuita</span>
+	<div>US$ 250 por noche</div>
+	<div class="price">Total: <strong>US$ 1.750</strong></div>
+</div></div>
+<div class="ui-datagrid-column ui-g-12"><div class="hotel-card">
+	<h3>Hotel Riu Palace &amp; Spa</h3>
+	<p>5 estrellas</p>
+	<p>No reembolsable</p>
+	<div class="price">Total: U$S 2.100,50</div>
+	<a href="javascript:void(0)" onclick="PrimeFaces.ab({s:&quot;j_id_4s:results:1:open&quot;})">Ver</a>
+</div></div>
+<div class="ui-datagrid-column ui-g-12"><div class="promo-card">
+	<h3>Caribe soñado</h3><p>Paquete 1 destino, 7 noches</p><div>Total: US$ 3.000</div>
+</div></div>
+<div class="ui-datagrid-column ui-g-12"><div class="hotel-card"><h3>Hotel Sin Tarifa</h3><p>Consultar</p></div></div>
+</div></div></div>]]></update><update id="j_id1:javax.faces.ViewState:0"><![CDATA[vs-4]]></update><extension ln="primefaces" type="args">{"validationFailed":false}</extension></changes></partial-response>`
+
+	testSearchRejected = `<?xml version="1.0" encoding="UTF-8"?>
+<partial-response id="j_id1"><changes><update id="j_id_4s:search:messages"><![CDATA[<span class="ui-messages-error-detail">Destino inválido</span>]]></update><extension ln="primefaces" type="args">{"validationFailed":true}</extension></changes></partial-response>`
+)
+
+// fakeSite serves the login form, accepts agent/secret through a partial
+// request carrying the page's view state and then renders the logged-in home.
+func fakeSite(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("auth"); err == nil && c.Value == "1" {
+			fmt.Fprint(w, testHome)
+			return
+		}
+		fmt.Fprint(w, testLoginForm)
+	})
+	mux.HandleFunc("GET /home", func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("auth"); err != nil || c.Value != "1" {
+			fmt.Fprint(w, testLoginForm)
+			return
+		}
+		if r.URL.Query().Get("directSubmit") == "true" {
+			fmt.Fprintf(w, testSearchForm, html.EscapeString(r.URL.Query().Get("distribution")))
+			return
+		}
+		fmt.Fprint(w, testHome)
+	})
+	mux.HandleFunc("POST /home.xhtml", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("javax.faces.source") == "j_id_4s:search:startTrip" {
+			searchHotels(w, r)
+			return
+		}
+		if r.Header.Get("Faces-Request") != "partial/ajax" || r.FormValue("javax.faces.ViewState") != "vs-1" ||
+			r.FormValue("javax.faces.source") != "j_id_4s:login:signin" {
+			http.Error(w, "not a partial login request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/xml")
+		if r.FormValue("j_id_4s:login:Email") != "agent" || r.FormValue("j_id_4s:login:j_password") != "secret" {
+			fmt.Fprintf(w, testPartial, testBadCredentials, "")
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "JSESSIONID", Value: "abc123", Path: "/"})
+		http.SetCookie(w, &http.Cookie{Name: "auth", Value: "1", Path: "/"})
+		fmt.Fprintf(w, testPartial, "", `<redirect url="/home"></redirect>`)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// searchHotels answers the search form's partial request for the Aruba
+// search with two adults and a couple with two children.
+func searchHotels(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Faces-Request") != "partial/ajax" || r.FormValue("javax.faces.ViewState") != "vs-3" ||
+		r.FormValue("j_id_4s:search:distribution") != "2!2-5-8" || r.FormValue("j_id_4s:search:arrivalOnlyAccommodation:input") == "" {
+		http.Error(w, "not a partial search request", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "text/xml")
+	if r.FormValue("j_id_4s:search:destinationOnlyAccommodation_hinput") != "Destination::AUA" {
+		fmt.Fprint(w, testSearchRejected)
+		return
+	}
+	fmt.Fprint(w, testSearchResults)
+}
+
+type countingThrottle struct{ waits atomic.Int32 }
+
+func (c *countingThrottle) Wait(context.Context) error {
+	c.waits.Add(1)
+	return nil
+}
+
+func (c *countingThrottle) Observe(int, string, []byte) {}
+
+func TestHTTPClientLogin(t *testing.T) {
+	srv := fakeSite(t)
+
+	tests := []struct {
+		name     string
+		password string
+		want     LoginStatus
+	}{
+		{"valid credentials", "secret", LoginSuccess},
+		{"bad password", "wrong", LoginInvalidCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewHTTPClient(&config.LoginConfig{TargetURL: srv.URL + "/", Username: "agent", Password: tt.password})
+			if err != nil {
+				t.Fatal(err)
+			}
+			throttle := &countingThrottle{}
+			client.Pace(throttle)
+
+			outcome, err := client.Login(context.Background())
+			if outcome.Status != tt.want {
+				t.Fatalf("status = %s (%q), want %s", outcome.Status, outcome.Message, tt.want)
+			}
+			if got := throttle.waits.Load(); got != 3 {
+				t.Errorf("paced %d requests, want 3", got)
+			}
+
+			if tt.want != LoginSuccess {
+				var loginErr *LoginError
+				if !errors.As(err, &loginErr) || !strings.Contains(outcome.Message, "incorrectos") {
+					t.Errorf("err = %v, message %q", err, outcome.Message)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if outcome.UserName != "JPARDO" || client.SessionID() != "abc123" {
+				t.Errorf("user %q session %q", outcome.UserName, client.SessionID())
+			}
+			// the partial response hands out the next view state
+			if vs := client.jsf.ViewState(); vs != "vs-2" {
+				t.Errorf("view state = %q, want vs-2", vs)
+			}
+		})
+	}
+}
+
+func TestHTTPClientSearchHotels(t *testing.T) {
+	srv := fakeSite(t)
+	client, err := NewHTTPClient(&config.LoginConfig{TargetURL: srv.URL + "/", Username: "agent", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Login(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	checkIn := time.Now().AddDate(0, 1, 0).Truncate(24 * time.Hour)
+	req := SearchRequest{
+		Destination: "AUA", CheckIn: checkIn, CheckOut: checkIn.AddDate(0, 0, 7),
+		Occupancy: []Room{{Adults: 2}, {Adults: 2, ChildrenAges: []int{5, 8}}},
+	}
+	hotels, err := client.SearchHotels(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hotels) != 2 {
+		t.Fatalf("hotels = %+v, want the two priced hotel cards", hotels)
+	}
+	melia, riu := hotels[0], hotels[1]
+	if melia.Name != "Meliá Caribe Beach" || melia.Stars != 4 || melia.Zone != "Bávaro" || melia.RoomType != "Habitación Doble Superior" ||
+		melia.Board != "Todo incluido" || melia.Refundable == nil || !*melia.Refundable || melia.DetailURL != srv.URL+"/hotel/123" ||
+		melia.TotalPrice != money.New(1750, 0, money.USD) || melia.NightlyPrice != money.New(250, 0, money.USD) {
+		t.Errorf("first hotel = %+v", melia)
+	}
+	if riu.Name != "Hotel Riu Palace & Spa" || riu.Stars != 5 || riu.Refundable == nil || *riu.Refundable || riu.DetailURL != "" ||
+		riu.TotalPrice != money.New(2100, 50, money.USD) || len(riu.Warnings) != 0 {
+		t.Errorf("second hotel = %+v", riu)
+	}
+	if vs := client.jsf.ViewState(); vs != "vs-4" {
+		t.Errorf("view state = %q, want vs-4", vs)
+	}
+
+	req.Destination = "Destination::PUJ"
+	if _, err := client.SearchHotels(context.Background(), req); err == nil || !strings.Contains(err.Error(), "Destino inválido") {
+		t.Errorf("rejected search: %v", err)
+	}
+}
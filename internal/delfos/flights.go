@@ -0,0 +1,461 @@
+package delfos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"ExpeditusClient/internal/browser"
+	"ExpeditusClient/internal/money"
+
+	"github.com/chromedp/chromedp"
+)
+
+// Cabin is the cabin class requested for a flight search.
+type Cabin string
+
+const (
+	CabinEconomy        Cabin = "ECONOMY"
+	CabinPremiumEconomy Cabin = "PREMIUM_ECONOMY"
+	CabinBusiness       Cabin = "BUSINESS"
+	CabinFirst          Cabin = "FIRST"
+)
+
+const (
+	maxFlightPassengers = 9
+	infantMaxAge        = 1
+)
+
+// FlightSearchRequest is a flight search as accepted by the home page's
+// directSubmit handler.
+type FlightSearchRequest struct {
+	Origin      string    // airport or city code, e.g. "EZE" or "Destination::BUE"
+	Destination string    // airport or city code
+	Departure   time.Time // outbound date
+	Return      time.Time // zero for a one-way search
+	Passengers  Room      // adults plus child ages; children under 2 travel as infants
+	Cabin       Cabin
+}
+
+// OneWay reports whether the search has no return flight.
+func (r FlightSearchRequest) OneWay() bool {
+	return r.Return.IsZero()
+}
+
+// PassengerCounts splits Passengers into adults, children and infants.
+func (r FlightSearchRequest) PassengerCounts() (adults, children, infants int) {
+	for _, age := range r.Passengers.ChildrenAges {
+		if age <= infantMaxAge {
+			infants++
+		} else {
+			children++
+		}
+	}
+	return r.Passengers.Adults, children, infants
+}
+
+// Validate checks the request against today's date.
+func (r FlightSearchRequest) Validate(today time.Time) error {
+	var errs []error
+
+	for _, f := range []struct{ name, value string }{{"origin", r.Origin}, {"destination", r.Destination}} {
+		switch {
+		case strings.TrimSpace(f.value) == "":
+			errs = append(errs, fmt.Errorf("%s is required", f.name))
+		case siteCode(f.value) == "":
+			errs = append(errs, fmt.Errorf("%s %q is not an airport or city code", f.name, f.value))
+		}
+	}
+	if origin := siteCode(r.Origin); origin != "" && origin == siteCode(r.Destination) {
+		errs = append(errs, errors.New("origin and destination must differ"))
+	}
+
+	if r.Departure.IsZero() {
+		errs = append(errs, errors.New("departure date is required"))
+	} else {
+		day := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, r.Departure.Location())
+		if r.Departure.Before(day) {
+			errs = append(errs, fmt.Errorf("departure %s is in the past", r.Departure.Format(DateLayout)))
+		}
+		if !r.OneWay() && r.Return.Before(r.Departure) {
+			errs = append(errs, fmt.Errorf("return %s is before departure %s", r.Return.Format(DateLayout), r.Departure.Format(DateLayout)))
+		}
+	}
+
+	switch r.Cabin {
+	case "", CabinEconomy, CabinPremiumEconomy, CabinBusiness, CabinFirst:
+	default:
+		errs = append(errs, fmt.Errorf("unsupported cabin %q", r.Cabin))
+	}
+
+	if err := validateOccupancy([]Room{r.Passengers}); err != nil {
+		errs = append(errs, err)
+	}
+	adults, children, infants := r.PassengerCounts()
+	if adults+children+infants > maxFlightPassengers {
+		errs = append(errs, fmt.Errorf("at most %d passengers per search", maxFlightPassengers))
+	}
+	if infants > adults {
+		errs = append(errs, errors.New("each infant must travel with an adult"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// URL builds the home?directSubmit=true flight search URL relative to base.
+func (r FlightSearchRequest) URL(base string) (string, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("parse base url: %w", err)
+	}
+	u = u.ResolveReference(&url.URL{Path: "home"})
+
+	cabin := r.Cabin
+	if cabin == "" {
+		cabin = CabinEconomy
+	}
+
+	q := url.Values{}
+	q.Set("directSubmit", "true")
+	q.Set("latestSearch", "true")
+	q.Set("tripType", string(TripOnlyFlight))
+	q.Set("departureDate", r.Departure.Format(DateLayout))
+	if r.OneWay() {
+		q.Set("oneWay", "true")
+	} else {
+		q.Set("arrivalDate", r.Return.Format(DateLayout))
+	}
+	q.Set("flightOrigin", siteCode(r.Origin))
+	q.Set("flightDestination", siteCode(r.Destination))
+	q.Set("distribution", distribution([]Room{r.Passengers}))
+	q.Set("cabinClass", string(cabin))
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// FlightSegment is one flight of an itinerary.
+type FlightSegment struct {
+	Direction    string    `json:"direction"` // "outbound" or "return"
+	Carrier      string    `json:"carrier,omitempty"`
+	FlightNumber string    `json:"flight_number,omitempty"`
+	From         string    `json:"from"`
+	To           string    `json:"to"`
+	Departs      time.Time `json:"departs"`
+	Arrives      time.Time `json:"arrives"`
+}
+
+// Itinerary is one flight result with its fare.
+type Itinerary struct {
+	Segments   []FlightSegment `json:"segments"`
+	Carriers   []string        `json:"carriers,omitempty"`
+	Baggage    string          `json:"baggage,omitempty"`
+	FareFamily string          `json:"fare_family,omitempty"`
+	Refundable *bool           `json:"refundable,omitempty"`
+	TotalPrice money.Money     `json:"total_price"`
+	Warnings   []string        `json:"warnings,omitempty"` // prices that could not be read
+}
+
+// Stops returns the number of connections on the outbound leg.
+func (it Itinerary) Stops() int {
+	n := 0
+	for _, s := range it.Segments {
+		if s.Direction == "outbound" {
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return n - 1
+}
+
+// rawSegment is a segment as read from the page, before dates are resolved.
+type rawSegment struct {
+	Direction    string `json:"direction"`
+	Carrier      string `json:"carrier"`
+	FlightNumber string `json:"flight_number"`
+	From         string `json:"from"`
+	To           string `json:"to"`
+	Date         string `json:"date"`
+	DepartTime   string `json:"depart_time"`
+	ArriveTime   string `json:"arrive_time"`
+	DayOffset    int    `json:"day_offset"`
+}
+
+type rawItinerary struct {
+	Segments   []rawSegment `json:"segments"`
+	Baggage    string       `json:"baggage"`
+	FareFamily string       `json:"fare_family"`
+	Refundable *bool        `json:"refundable"`
+	TotalPrice string       `json:"total_price"`
+}
+
+// SearchFlights navigates to the flight results for req and extracts every
+// itinerary. The browser must already be logged in.
+func SearchFlights(ctx context.Context, base string, req FlightSearchRequest) ([]Itinerary, error) {
+	if err := req.Validate(time.Now()); err != nil {
+		return nil, err
+	}
+	target, err := req.URL(base)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw []rawItinerary
+	err = chromedp.Run(ctx,
+		browser.BestEffort(browser.NetworkIdle(chromedp.Navigate(target), time.Second, 45*time.Second)),
+		chromedp.WaitReady("body", chromedp.ByQuery),
+		browser.WaitAjaxIdle(45*time.Second),
+		browser.BestEffort(browser.WaitDOMStable("body", time.Second, 20*time.Second)),
+		chromedp.Evaluate(buildExtractItinerariesScript(), &raw),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("flight search failed: %w", err)
+	}
+
+	itineraries := make([]Itinerary, 0, len(raw))
+	for _, r := range raw {
+		itineraries = append(itineraries, buildItinerary(r, req))
+	}
+	return itineraries, nil
+}
+
+func buildItinerary(r rawItinerary, req FlightSearchRequest) Itinerary {
+	it := Itinerary{
+		Baggage:    r.Baggage,
+		FareFamily: r.FareFamily,
+		Refundable: r.Refundable,
+	}
+	it.TotalPrice = parsePrice("total price", r.TotalPrice, &it.Warnings)
+
+	_, dest, _ := strings.Cut(siteCode(req.Destination), "::")
+	assignDirections(r.Segments, dest)
+	seen := make(map[string]bool)
+	for _, s := range r.Segments {
+		ref := req.Departure
+		if s.Direction == "return" && !req.Return.IsZero() {
+			ref = req.Return
+		}
+		departs, arrives := segmentTimes(s, ref)
+		it.Segments = append(it.Segments, FlightSegment{
+			Direction:    s.Direction,
+			Carrier:      s.Carrier,
+			FlightNumber: s.FlightNumber,
+			From:         s.From,
+			To:           s.To,
+			Departs:      departs,
+			Arrives:      arrives,
+		})
+		if s.Carrier != "" && !seen[s.Carrier] {
+			seen[s.Carrier] = true
+			it.Carriers = append(it.Carriers, s.Carrier)
+		}
+	}
+	return it
+}
+
+// assignDirections labels segments the page did not place under an
+// "Ida"/"Vuelta" heading. The return leg starts after the segment landing at
+// destination; when no segment matches (city versus airport codes) an
+// itinerary ending where it started is split in half.
+func assignDirections(segments []rawSegment, destination string) {
+	if len(segments) == 0 {
+		return
+	}
+	turn := len(segments)
+	for i, s := range segments {
+		if s.To == destination {
+			turn = i + 1
+			break
+		}
+	}
+	if turn == len(segments) && len(segments) > 1 && segments[len(segments)-1].To == segments[0].From {
+		turn = len(segments) / 2
+	}
+
+	direction := "outbound"
+	for i := range segments {
+		if segments[i].Direction != "" {
+			direction = segments[i].Direction
+			continue
+		}
+		if i >= turn {
+			direction = "return"
+		}
+		segments[i].Direction = direction
+	}
+}
+
+var (
+	numericDateRe = regexp.MustCompile(`(\d{1,2})/(\d{1,2})(?:/(\d{4}))?`)
+	monthDateRe   = regexp.MustCompile(`(?i)(\d{1,2})\s*(?:de\s+)?(ene|feb|mar|abr|may|jun|jul|ago|sep|set|oct|nov|dic|jan|apr|aug|dec)`)
+	clockRe       = regexp.MustCompile(`^(\d{1,2}):(\d{2})$`)
+)
+
+var shortMonths = map[string]time.Month{
+	"ene": time.January, "jan": time.January, "feb": time.February, "mar": time.March,
+	"abr": time.April, "apr": time.April, "may": time.May, "jun": time.June, "jul": time.July,
+	"ago": time.August, "aug": time.August, "sep": time.September, "set": time.September,
+	"oct": time.October, "nov": time.November, "dic": time.December, "dec": time.December,
+}
+
+// segmentDate parses the date shown on a segment ("12/11", "12/11/2026",
+// "mié 12 nov"), taking the year from ref. Unparseable dates return ref.
+func segmentDate(s string, ref time.Time) time.Time {
+	var day, year int
+	var month time.Month
+	if m := numericDateRe.FindStringSubmatch(s); m != nil {
+		day, _ = strconv.Atoi(m[1])
+		mm, _ := strconv.Atoi(m[2])
+		month = time.Month(mm)
+		year, _ = strconv.Atoi(m[3])
+	} else if m := monthDateRe.FindStringSubmatch(s); m != nil {
+		day, _ = strconv.Atoi(m[1])
+		month = shortMonths[strings.ToLower(m[2])]
+	}
+	if day < 1 || day > 31 || month < time.January || month > time.December {
+		return ref
+	}
+
+	explicitYear := year != 0
+	if !explicitYear {
+		year = ref.Year()
+	}
+	d := time.Date(year, month, day, 0, 0, 0, 0, ref.Location())
+	// a December search can show January dates of the following year
+	if !explicitYear && d.Before(ref.AddDate(0, -6, 0)) {
+		d = d.AddDate(1, 0, 0)
+	}
+	return d
+}
+
+// segmentTimes combines a segment's clock times with its date. Arrivals
+// earlier than the departure without an explicit "+N" day marker are taken to
+// land the next day.
+func segmentTimes(s rawSegment, ref time.Time) (departs, arrives time.Time) {
+	day := segmentDate(s.Date, ref)
+	departs = atClock(day, s.DepartTime)
+	arrives = atClock(day.AddDate(0, 0, s.DayOffset), s.ArriveTime)
+	if s.DayOffset == 0 && !departs.IsZero() && !arrives.IsZero() && arrives.Before(departs) {
+		arrives = arrives.AddDate(0, 0, 1)
+	}
+	return departs, arrives
+}
+
+func atClock(day time.Time, clock string) time.Time {
+	m := clockRe.FindStringSubmatch(strings.TrimSpace(clock))
+	if m == nil || day.IsZero() {
+		return time.Time{}
+	}
+	h, _ := strconv.Atoi(m[1])
+	min, _ := strconv.Atoi(m[2])
+	return time.Date(day.Year(), day.Month(), day.Day(), h, min, 0, 0, day.Location())
+}
+
+func buildExtractItinerariesScript() string {
+	return `(() => {
+		const PRICE = '(?:US\\$|U\\$S|U\\$D|USD|ARS|EUR|€|\\$)\\s?[\\d.,]+';
+		const priceRe = new RegExp(PRICE);
+		const totalRe = new RegExp('(?:Total|Precio final)[^\\d$€]{0,20}?(' + PRICE + ')', 'i');
+		const totalCount = (el) => ((el.innerText || '').match(/(?:Total|Precio final)[^\d$€]{0,20}(?:US\$|U\$S|U\$D|USD|ARS|EUR|€|\$)/gi) || []).length;
+		const clean = (s) => (s || '').replace(/\s+/g, ' ').trim();
+		const visible = (el) => el.offsetParent !== null;
+		const texts = (root, sel) => Array.from(root.querySelectorAll(sel)).filter(visible).map(el => clean(el.innerText)).filter(Boolean);
+		const CLOCK = /\b(?:[01]?\d|2[0-3]):[0-5]\d\b/g;
+		const NOT_IATA = new Set(['USD', 'ARS', 'EUR', 'IDA', 'HS', 'HRS', 'MIN', 'AM', 'PM', 'TAX', 'IVA', 'VER', 'LUN', 'MAR', 'MIE', 'JUE', 'VIE', 'SAB', 'DOM']);
+		const iata = (text) => (text.match(/\b[A-Z]{3}\b/g) || []).filter(c => !NOT_IATA.has(c));
+		const isSegment = (el) => {
+			const text = el.innerText || '';
+			return (text.match(CLOCK) || []).length >= 2 && iata(text).length >= 2;
+		};
+
+		// ANCHOR: Like hotel cards, every itinerary has a single "Total" price line
+		const cards = [];
+		const walker = document.createTreeWalker(document.body, NodeFilter.SHOW_TEXT);
+		while (walker.nextNode()) {
+			const node = walker.currentNode;
+			if (!/Total|Precio final/i.test(node.textContent)) continue;
+			let el = node.parentElement;
+			if (!el || !visible(el)) continue;
+			while (el && el !== document.body && !totalRe.test(el.innerText || '')) el = el.parentElement;
+			if (!el || el === document.body) continue;
+			let card = el;
+			while (card.parentElement && card.parentElement !== document.body && totalCount(card.parentElement) <= 1) {
+				card = card.parentElement;
+			}
+			if (totalCount(card) === 1 && !cards.includes(card)) cards.push(card);
+		}
+
+		const fareNames = ['Light', 'Basic', 'Básica', 'Promo', 'Economy', 'Classic', 'Clásica', 'Smart', 'Plus', 'Flex', 'Full', 'Top', 'Premium', 'Business'];
+
+		return cards.map(card => {
+			const text = card.innerText || '';
+			const lines = text.split('\n').map(clean).filter(Boolean);
+
+			// ANCHOR: A segment is the innermost element with two clock times and two airport codes
+			const segEls = Array.from(card.querySelectorAll('*')).filter(el =>
+				visible(el) && isSegment(el) && !Array.from(el.children).some(isSegment));
+
+			const segments = segEls.map(el => {
+				const segText = el.innerText || '';
+				const codes = iata(segText);
+				const clocks = segText.match(CLOCK) || [];
+				const offset = segText.match(/\+\s?(\d)\s*(?:d[ií]a|day)?/i);
+				const date = segText.match(/\d{1,2}\/\d{1,2}(?:\/\d{4})?|\d{1,2}\s*(?:de\s+)?(?:ene|feb|mar|abr|may|jun|jul|ago|sep|set|oct|nov|dic)[a-z]*/i);
+				const logo = el.querySelector('img[alt]') || el.closest('[class*="flight" i], [class*="vuelo" i]')?.querySelector('img[alt]');
+				const carrier = texts(el, '[class*="airline" i], [class*="carrier" i], [class*="aerolinea" i], [class*="company" i]')[0] ||
+					clean(logo?.getAttribute('alt'));
+				const number = segText.match(/\b([A-Z]{2}|[A-Z]\d|\d[A-Z])\s?-?\s?(\d{1,4})\b/);
+
+				let direction = '';
+				for (let p = el; p && p !== card.parentElement; p = p.parentElement) {
+					const head = clean((p.innerText || '').split('\n')[0]);
+					if (/^(ida|outbound|salida)\b/i.test(head)) { direction = 'outbound'; break; }
+					if (/^(vuelta|regreso|return)\b/i.test(head)) { direction = 'return'; break; }
+				}
+
+				return {
+					direction: direction,
+					carrier: carrier,
+					flight_number: number ? number[1] + number[2] : '',
+					from: codes[0],
+					to: codes[codes.length - 1],
+					date: date ? date[0] : '',
+					depart_time: clocks[0],
+					arrive_time: clocks[clocks.length - 1],
+					day_offset: offset ? parseInt(offset[1], 10) : 0
+				};
+			});
+
+			const baggage = texts(card, '[class*="bag" i], [class*="equipaje" i], [class*="luggage" i]');
+			lines.filter(l => /equipaje|valija|baggage|carry[- ]?on|art[ií]culo personal|\b\d+\s?(?:pc|kg)\b/i.test(l) && l.length < 120)
+				.forEach(l => baggage.push(l));
+
+			const fareLine = lines.find(l => /^(tarifa|fare|familia)\b/i.test(l) && !priceRe.test(l));
+			const fareFamily = texts(card, '[class*="fare-family" i], [class*="fareFamily" i], [class*="brand" i], [class*="familia" i]')[0] ||
+				fareLine || fareNames.find(n => lines.includes(n)) || '';
+
+			let refundable = null;
+			if (/no reembolsable|non[- ]?refundable|sin reembolso/i.test(text)) {
+				refundable = false;
+			} else if (/reembolsable|refundable/i.test(text)) {
+				refundable = true;
+			}
+
+			const totalMatch = text.match(totalRe);
+			return {
+				segments: segments,
+				baggage: [...new Set(baggage)].join(' | '),
+				fare_family: fareFamily,
+				refundable: refundable,
+				total_price: clean(totalMatch?.[1])
+			};
+		}).filter(it => it.segments.length > 0 && it.total_price);
+	})()`
+}
@@ -0,0 +1,117 @@
+package delfos
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFlightSearchRequest(t *testing.T) {
+	today := time.Date(2026, 5, 1, 15, 0, 0, 0, time.UTC)
+	dep, _ := ParseDate("09/05/2026")
+	ret, _ := ParseDate("23/05/2026")
+
+	req := FlightSearchRequest{Origin: "EZE", Destination: "MIA", Departure: dep, Return: ret, Passengers: Room{Adults: 2, ChildrenAges: []int{7, 1}}}
+	if err := req.Validate(today); err != nil {
+		t.Fatalf("valid request: %v", err)
+	}
+	if a, c, i := req.PassengerCounts(); a != 2 || c != 1 || i != 1 {
+		t.Errorf("passengers = %d/%d/%d", a, c, i)
+	}
+
+	raw, err := req.URL("https://www.delfos.tur.ar/")
+	if err != nil {
+		t.Fatalf("URL: %v", err)
+	}
+	u, _ := url.Parse(raw)
+	want := map[string]string{
+		"tripType":          "ONLY_FLIGHT",
+		"departureDate":     "09/05/2026",
+		"arrivalDate":       "23/05/2026",
+		"flightOrigin":      "Destination::EZE",
+		"flightDestination": "Destination::MIA",
+		"distribution":      "2-7-1",
+		"cabinClass":        "ECONOMY",
+	}
+	for k, v := range want {
+		if got := u.Query().Get(k); got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+
+	invalid := []FlightSearchRequest{
+		{Origin: "EZE", Departure: dep, Passengers: Room{Adults: 1}},
+		{Origin: "EZE", Destination: "EZE", Departure: dep, Passengers: Room{Adults: 1}},
+		{Origin: "EZE", Destination: "MIA", Departure: ret, Return: dep, Passengers: Room{Adults: 1}},
+		{Origin: "EZE", Destination: "MIA", Departure: dep, Passengers: Room{Adults: 1, ChildrenAges: []int{0, 1}}},
+		{Origin: "EZE", Destination: "MIA", Departure: dep, Passengers: Room{Adults: 1}, Cabin: "COACH"},
+	}
+	for i, r := range invalid {
+		if err := r.Validate(today); err == nil {
+			t.Errorf("invalid[%d]: expected error", i)
+		}
+	}
+
+	differ := []struct {
+		origin, dest string
+		want         bool
+	}{
+		{"EZE", " EZE ", true},
+		{"Buenos Aires", "Buenos Aires", false}, // already reported as invalid codes
+		{"??", "!!", false},
+	}
+	for _, tt := range differ {
+		r := FlightSearchRequest{Origin: tt.origin, Destination: tt.dest, Departure: dep, Passengers: Room{Adults: 1}}
+		err := r.Validate(today)
+		if got := err != nil && strings.Contains(err.Error(), "must differ"); got != tt.want {
+			t.Errorf("%s -> %s: %v, want \"must differ\" %v", tt.origin, tt.dest, err, tt.want)
+		}
+	}
+}
+
+func TestSegmentTimes(t *testing.T) {
+	ref := time.Date(2026, 12, 28, 0, 0, 0, 0, time.UTC)
+	at := func(y int, m time.Month, d, h, min int) time.Time {
+		return time.Date(y, m, d, h, min, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name    string
+		seg     rawSegment
+		departs time.Time
+		arrives time.Time
+	}{
+		{"same day", rawSegment{DepartTime: "08:10", ArriveTime: "11:45"}, at(2026, 12, 28, 8, 10), at(2026, 12, 28, 11, 45)},
+		{"overnight", rawSegment{DepartTime: "23:30", ArriveTime: "07:05"}, at(2026, 12, 28, 23, 30), at(2026, 12, 29, 7, 5)},
+		{"day marker", rawSegment{DepartTime: "10:00", ArriveTime: "12:00", DayOffset: 1}, at(2026, 12, 28, 10, 0), at(2026, 12, 29, 12, 0)},
+		{"numeric date", rawSegment{Date: "30/12", DepartTime: "09:00", ArriveTime: "10:00"}, at(2026, 12, 30, 9, 0), at(2026, 12, 30, 10, 0)},
+		{"next year", rawSegment{Date: "vie 2 ene", DepartTime: "09:00", ArriveTime: "10:00"}, at(2027, 1, 2, 9, 0), at(2027, 1, 2, 10, 0)},
+	}
+	for _, tt := range tests {
+		departs, arrives := segmentTimes(tt.seg, ref)
+		if !departs.Equal(tt.departs) || !arrives.Equal(tt.arrives) {
+			t.Errorf("%s: got %v - %v, want %v - %v", tt.name, departs, arrives, tt.departs, tt.arrives)
+		}
+	}
+}
+
+func TestAssignDirections(t *testing.T) {
+	segs := []rawSegment{
+		{From: "EZE", To: "PTY"},
+		{From: "PTY", To: "MIA"},
+		{From: "MIA", To: "PTY"},
+		{From: "PTY", To: "EZE"},
+	}
+	want := []string{"outbound", "outbound", "return", "return"}
+
+	for _, dest := range []string{"MIA", "MIA_CITY"} {
+		assignDirections(segs, dest)
+		for i := range segs {
+			if segs[i].Direction != want[i] {
+				t.Errorf("%s: segment %d direction = %q, want %q", dest, i, segs[i].Direction, want[i])
+			}
+			segs[i].Direction = ""
+		}
+	}
+}
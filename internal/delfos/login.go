@@ -0,0 +1,292 @@
+// Package delfos holds the site-specific flows for the Delfos Tour
+// (www.delfos.tur.ar) JSF/PrimeFaces front end.
+package delfos
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"ExpeditusClient/internal/browser"
+	"ExpeditusClient/internal/config"
+
+	"github.com/chromedp/chromedp"
+)
+
+// Login form elements are tagged by buildLocateLoginScript so the typing layer
+// can address them with stable selectors regardless of the generated JSF ids.
+const (
+	loginUserSel     = `[data-expeditus="login-user"]`
+	loginPasswordSel = `[data-expeditus="login-password"]`
+	loginSubmitSel   = `[data-expeditus="login-submit"]`
+)
+
+// loginPendingSel matches the password field only while no error message is
+// shown, so waiting for it to disappear ends as soon as the login form closes
+// or the site rejects the credentials.
+const loginPendingSel = `body:not(:has(.ui-messages-error, .ui-message-error, .ui-growl-message-error, ` +
+	`.ui-messages-warn, .ui-growl-message-warn, [class*="error-message" i])) ` + loginPasswordSel
+
+// pageTimeout bounds each wait for the site's AJAX activity to settle.
+const pageTimeout = 20 * time.Second
+
+// LoginStatus is the typed outcome of a login attempt.
+type LoginStatus string
+
+const (
+	LoginSuccess            LoginStatus = "success"
+	LoginInvalidCredentials LoginStatus = "invalid_credentials"
+	LoginAccountLocked      LoginStatus = "account_locked"
+	LoginCaptchaRequired    LoginStatus = "captcha_required"
+	LoginMaintenance        LoginStatus = "maintenance"
+	LoginUnknown            LoginStatus = "unknown"
+)
+
+// LoginOutcome describes what the page looks like after submitting the login form.
+type LoginOutcome struct {
+	Status   LoginStatus `json:"status"`
+	UserName string      `json:"user_name,omitempty"`
+	Message  string      `json:"message,omitempty"`
+	URL      string      `json:"url"`
+}
+
+// LoginError is returned when the login did not reach an authenticated page.
+type LoginError struct {
+	Outcome LoginOutcome
+}
+
+func (e *LoginError) Error() string {
+	if e.Outcome.Message != "" {
+		return fmt.Sprintf("login %s: %s", e.Outcome.Status, e.Outcome.Message)
+	}
+	return fmt.Sprintf("login %s", e.Outcome.Status)
+}
+
+// loginSignals are the raw markers collected from the page by buildVerifyScript.
+type loginSignals struct {
+	URL             string   `json:"url"`
+	HasLogout       bool     `json:"has_logout"`
+	HasLoginTrigger bool     `json:"has_login_trigger"`
+	HasPassword     bool     `json:"has_password"`
+	UserName        string   `json:"user_name"`
+	Messages        []string `json:"messages"`
+	Captcha         bool     `json:"captcha"`
+	Maintenance     bool     `json:"maintenance"`
+}
+
+// Login opens the login modal on cfg.TargetURL, types the credentials through
+// CDP key events and verifies the resulting page.
+func Login(ctx context.Context, cfg *config.LoginConfig, typing browser.TypeOptions) (LoginOutcome, error) {
+	site, err := siteURL(cfg.TargetURL)
+	if err != nil {
+		return LoginOutcome{Status: LoginUnknown}, err
+	}
+	err = chromedp.Run(ctx,
+		chromedp.Navigate(cfg.TargetURL),
+		browser.WaitURL(site, pageTimeout),
+		chromedp.WaitReady("body", chromedp.ByQuery),
+		browser.WaitAjaxIdle(pageTimeout),
+	)
+	if err != nil {
+		return LoginOutcome{Status: LoginUnknown}, fmt.Errorf("navigation failed: %w", err)
+	}
+
+	var located struct {
+		User     bool   `json:"user"`
+		Password bool   `json:"password"`
+		Submit   bool   `json:"submit"`
+		Debug    string `json:"debug"`
+	}
+	err = chromedp.Run(ctx,
+		chromedp.Evaluate(buildOpenLoginScript(), nil),
+		browser.WaitSelector(`input[type="password"]`, pageTimeout),
+		browser.CallFunction(buildLocateLoginScript(), &located),
+	)
+	if err != nil {
+		return LoginOutcome{Status: LoginUnknown}, fmt.Errorf("open login form: %w", err)
+	}
+	if !located.User || !located.Password || !located.Submit {
+		return LoginOutcome{Status: LoginUnknown}, fmt.Errorf("login form not found: %s", located.Debug)
+	}
+
+	err = chromedp.Run(ctx,
+		browser.TypeText(loginUserSel, cfg.Username, typing),
+		browser.TypeText(loginPasswordSel, cfg.Password, typing),
+		browser.BestEffort(browser.NetworkIdle(chromedp.Click(loginSubmitSel, chromedp.ByQuery), 500*time.Millisecond, pageTimeout)),
+		browser.WaitURL(site, pageTimeout),
+		browser.WaitAjaxIdle(pageTimeout),
+		// a captcha or an unexpected page keeps the form open; VerifyLogin
+		// classifies whatever is there once the wait gives up
+		browser.BestEffort(browser.WaitSelectorGone(loginPendingSel, pageTimeout)),
+	)
+	if err != nil {
+		return LoginOutcome{Status: LoginUnknown}, fmt.Errorf("login fill failed: %w", err)
+	}
+
+	outcome, err := VerifyLogin(ctx)
+	if err != nil {
+		return outcome, err
+	}
+	if outcome.Status != LoginSuccess {
+		return outcome, &LoginError{Outcome: outcome}
+	}
+	return outcome, nil
+}
+
+// VerifyLogin inspects the current page and classifies the login state.
+// Success is only reported when authenticated-only markers (a logout control and
+// no visible "Entrar" trigger or password field) are present.
+func VerifyLogin(ctx context.Context) (LoginOutcome, error) {
+	var signals loginSignals
+	if err := chromedp.Run(ctx, chromedp.Evaluate(buildVerifyScript(), &signals)); err != nil {
+		return LoginOutcome{Status: LoginUnknown}, fmt.Errorf("verify login: %w", err)
+	}
+	return classifyLogin(signals), nil
+}
+
+// KeepAlive reloads base in a logged-in tab so the server session does not
+// expire, and reports whether it is still authenticated.
+func KeepAlive(ctx context.Context, base string) (LoginOutcome, error) {
+	err := chromedp.Run(ctx,
+		chromedp.Navigate(base),
+		chromedp.WaitReady("body", chromedp.ByQuery),
+		browser.WaitAjaxIdle(pageTimeout),
+	)
+	if err != nil {
+		return LoginOutcome{Status: LoginUnknown}, fmt.Errorf("keep-alive navigation: %w", err)
+	}
+	return VerifyLogin(ctx)
+}
+
+func classifyLogin(s loginSignals) LoginOutcome {
+	outcome := LoginOutcome{Status: LoginUnknown, URL: s.URL}
+	message := strings.Join(s.Messages, " | ")
+	lower := strings.ToLower(message)
+
+	switch {
+	case containsAny(lower, "bloquead", "suspendid", "locked", "inhabilitad"):
+		outcome.Status = LoginAccountLocked
+	case containsAny(lower, "incorrect", "inválid", "invalid", "no coincide", "no existe"):
+		outcome.Status = LoginInvalidCredentials
+	case s.Captcha:
+		outcome.Status = LoginCaptchaRequired
+	case s.Maintenance && !s.HasLogout:
+		outcome.Status = LoginMaintenance
+	case s.HasLogout && !s.HasLoginTrigger && !s.HasPassword:
+		outcome.Status = LoginSuccess
+		outcome.UserName = s.UserName
+	}
+
+	outcome.Message = message
+	return outcome
+}
+
+func containsAny(s string, subs ...string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+func buildVerifyScript() string {
+	return `(() => {
+		const visible = (el) => !!el && el.offsetParent !== null;
+		const text = (el) => (el?.innerText || el?.textContent || '').trim();
+
+		// ANCHOR: Logout control only rendered for authenticated users
+		const logoutWords = ['cerrar sesión', 'cerrar sesion', 'salir', 'logout', 'desconectar'];
+		const logout = Array.from(document.querySelectorAll('a, button, [role="button"]')).find(el => {
+			const href = (el.getAttribute('href') || '').toLowerCase();
+			const id = (el.id || '').toLowerCase();
+			const label = text(el).toLowerCase();
+			return href.includes('logout') || id.includes('logout') || logoutWords.includes(label);
+		});
+
+		const userEl = document.querySelector(
+			'[id*="userName" i], [id*="loggedUser" i], [class*="user-name" i], [class*="username" i], [class*="user-info" i]'
+		);
+
+		const messages = Array.from(document.querySelectorAll(
+			'.ui-messages-error, .ui-message-error, .ui-growl-message-error, .ui-messages-warn, .ui-growl-message-warn, [class*="error-message" i]'
+		)).filter(visible).map(text).filter(Boolean);
+
+		const captcha = !!document.querySelector(
+			'iframe[src*="recaptcha"], iframe[src*="hcaptcha"], iframe[src*="challenges.cloudflare.com"], .g-recaptcha, .h-captcha, [id*="captcha" i]'
+		);
+
+		const body = (document.body?.innerText || '').toLowerCase();
+
+		return {
+			url: window.location.href,
+			has_logout: !!logout,
+			has_login_trigger: visible(document.getElementById('openLogin')),
+			has_password: visible(document.querySelector('input[type="password"]')),
+			user_name: text(userEl).substring(0, 80),
+			messages: messages,
+			captcha: captcha,
+			maintenance: body.includes('mantenimiento') || body.includes('maintenance')
+		};
+	})()`
+}
+
+func buildOpenLoginScript() string {
+	return `(() => {
+		// ANCHOR: Find "Entrar" by id or exact text match
+		const trigger = document.getElementById('openLogin');
+		if (trigger) {
+			trigger.click();
+			return 'clicked-openLogin';
+		}
+		for (const link of document.querySelectorAll('a, button')) {
+			if (link.textContent?.trim().toLowerCase() === 'entrar') {
+				link.click();
+				return 'clicked-entrar';
+			}
+		}
+		return 'not-found';
+	})()`
+}
+
+func buildLocateLoginScript() string {
+	return `function() {
+		const debug = [];
+		const tag = (el, name) => { el.setAttribute('data-expeditus', name); };
+
+		let loginForm = null;
+		for (const form of document.querySelectorAll('form')) {
+			if (form.querySelector('input[type="password"]') && form.querySelector('input[type="text"], input[type="email"]')) {
+				loginForm = form;
+				debug.push('form: ' + form.id);
+				break;
+			}
+		}
+		const root = loginForm || document;
+
+		// ANCHOR: Known JSF ids end in ":login:Email", ":login:j_password" and ":login:signin"
+		const user = root.querySelector('input[id$=":login:Email"]') ||
+			Array.from(root.querySelectorAll('input[type="text"], input[type="email"]')).find(input => {
+				const id = (input.id || '').toLowerCase();
+				const name = (input.name || '').toLowerCase();
+				return id.includes('email') || name.includes('email') || id.includes('login');
+			});
+		const password = root.querySelector('input[id$=":login:j_password"]') ||
+			root.querySelector('input[type="password"]');
+
+		const buttons = Array.from(root.querySelectorAll('button, input[type="submit"], [role="button"]'));
+		const submit = root.querySelector('button[id$=":login:signin"]') ||
+			buttons.find(btn => {
+				const text = (btn.textContent || btn.value || '').toLowerCase().trim();
+				return text === 'iniciar sesión' || text === 'iniciar';
+			}) ||
+			buttons.find(btn => (btn.type || '').toLowerCase() === 'submit');
+
+		if (user) { tag(user, 'login-user'); debug.push('user: ' + user.id); }
+		if (password) { tag(password, 'login-password'); debug.push('password: ' + password.id); }
+		if (submit) { tag(submit, 'login-submit'); debug.push('submit: ' + (submit.id || submit.tagName)); }
+
+		return { user: !!user, password: !!password, submit: !!submit, debug: debug.join(' | ') };
+	}`
+}
@@ -0,0 +1,59 @@
+package delfos
+
+import "testing"
+
+func TestClassifyLogin(t *testing.T) {
+	tests := []struct {
+		name    string
+		signals loginSignals
+		want    LoginStatus
+	}{
+		{
+			name:    "authenticated markers",
+			signals: loginSignals{HasLogout: true, UserName: "JPARDO"},
+			want:    LoginSuccess,
+		},
+		{
+			name:    "url changed but login trigger still visible",
+			signals: loginSignals{URL: "https://www.delfos.tur.ar/home", HasLoginTrigger: true},
+			want:    LoginUnknown,
+		},
+		{
+			name:    "logout link but password field still open",
+			signals: loginSignals{HasLogout: true, HasPassword: true},
+			want:    LoginUnknown,
+		},
+		{
+			name:    "bad password",
+			signals: loginSignals{HasPassword: true, Messages: []string{"Usuario o contraseña incorrectos"}},
+			want:    LoginInvalidCredentials,
+		},
+		{
+			name:    "locked account",
+			signals: loginSignals{HasPassword: true, Messages: []string{"El usuario se encuentra bloqueado"}},
+			want:    LoginAccountLocked,
+		},
+		{
+			name:    "captcha",
+			signals: loginSignals{HasPassword: true, Captcha: true},
+			want:    LoginCaptchaRequired,
+		},
+		{
+			name:    "maintenance",
+			signals: loginSignals{Maintenance: true},
+			want:    LoginMaintenance,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := classifyLogin(tt.signals)
+			if got.Status != tt.want {
+				t.Fatalf("status = %s, want %s", got.Status, tt.want)
+			}
+			if tt.want == LoginSuccess && got.UserName != tt.signals.UserName {
+				t.Fatalf("user name = %q, want %q", got.UserName, tt.signals.UserName)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package delfos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"ExpeditusClient/internal/browser"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/storage"
+	"github.com/chromedp/chromedp"
+)
+
+// logoutSel is the control tagged by buildLogoutScript.
+const logoutSel = `[data-expeditus="logout"]`
+
+// Logout ends the session of the tab: it follows the site's logout control,
+// reloads base to check the server no longer recognizes the session and then
+// clears the site's cookies and storage whatever happened. It returns the
+// state of base after the logout.
+func Logout(ctx context.Context, base string) (LoginOutcome, error) {
+	outcome, err := logout(ctx, base)
+	if clearErr := ClearSession(ctx, base); clearErr != nil {
+		err = errors.Join(err, clearErr)
+	}
+	return outcome, err
+}
+
+func logout(ctx context.Context, base string) (LoginOutcome, error) {
+	err := chromedp.Run(ctx,
+		chromedp.Navigate(base),
+		chromedp.WaitReady("body", chromedp.ByQuery),
+		browser.WaitAjaxIdle(pageTimeout),
+	)
+	if err != nil {
+		return LoginOutcome{Status: LoginUnknown}, fmt.Errorf("logout navigation: %w", err)
+	}
+
+	var control struct {
+		Found bool   `json:"found"`
+		Href  string `json:"href"`
+	}
+	if err := chromedp.Run(ctx, chromedp.Evaluate(buildLogoutScript(), &control)); err != nil {
+		return LoginOutcome{Status: LoginUnknown}, fmt.Errorf("find logout control: %w", err)
+	}
+	if control.Found {
+		var trigger chromedp.Action = chromedp.Click(logoutSel, chromedp.ByQuery)
+		if control.Href != "" {
+			// a plain link works even when the menu holding it is collapsed
+			trigger = chromedp.Navigate(control.Href)
+		}
+		err := chromedp.Run(ctx,
+			browser.BestEffort(browser.NetworkIdle(trigger, 500*time.Millisecond, pageTimeout)),
+			browser.WaitAjaxIdle(pageTimeout),
+		)
+		if err != nil {
+			return LoginOutcome{Status: LoginUnknown}, fmt.Errorf("logout: %w", err)
+		}
+	}
+
+	outcome, err := KeepAlive(ctx, base)
+	if err != nil {
+		return outcome, fmt.Errorf("verify logout: %w", err)
+	}
+	if outcome.Status == LoginSuccess {
+		return outcome, fmt.Errorf("still logged in as %q after logout", outcome.UserName)
+	}
+	return outcome, nil
+}
+
+// ClearSession deletes the browser's cookies and cache and the storage of
+// base's origin, so the next tab starts logged out.
+func ClearSession(ctx context.Context, base string) error {
+	u, err := url.Parse(base)
+	if err != nil {
+		return fmt.Errorf("parse site url: %w", err)
+	}
+	origin := u.Scheme + "://" + u.Host
+	err = chromedp.Run(ctx,
+		// sessionStorage is per tab and not covered by ClearDataForOrigin
+		browser.BestEffort(chromedp.Evaluate(`try { sessionStorage.clear(); localStorage.clear(); } catch (e) {}`, nil)),
+		network.ClearBrowserCookies(),
+		network.ClearBrowserCache(),
+		storage.ClearDataForOrigin(origin, "all"),
+	)
+	if err != nil {
+		return fmt.Errorf("clear session: %w", err)
+	}
+	return nil
+}
+
+func buildLogoutScript() string {
+	return `(() => {
+		// ANCHOR: Same logout markers as buildVerifyScript
+		const logoutWords = ['cerrar sesión', 'cerrar sesion', 'salir', 'logout', 'desconectar'];
+		const logout = Array.from(document.querySelectorAll('a, button, [role="button"]')).find(el => {
+			const href = (el.getAttribute('href') || '').toLowerCase();
+			const id = (el.id || '').toLowerCase();
+			const label = (el.innerText || el.textContent || '').trim().toLowerCase();
+			return href.includes('logout') || id.includes('logout') || logoutWords.includes(label);
+		});
+		if (!logout) return { found: false, href: '' };
+		logout.setAttribute('data-expeditus', 'logout');
+
+		// ANCHOR: JSF commandLinks carry href="#" and submit through onclick
+		const href = logout.tagName === 'A' ? logout.href : '';
+		const navigable = href.startsWith('http') && !href.endsWith('#') && !logout.getAttribute('onclick');
+		return { found: true, href: navigable ? href : '' };
+	})()`
+}
@@ -0,0 +1,118 @@
+package delfos
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxRooms            = 9
+	maxAdultsPerRoom    = 9
+	maxChildrenPerRoom  = 4
+	maxChildAge         = 17
+	occupancyRoomSep    = ";"
+	occupancyChildSep   = ":"
+	distributionRoomSep = "!"
+)
+
+// Room is the occupancy of one room: adults plus the age of each child.
+type Room struct {
+	Adults       int   `json:"adults"`
+	ChildrenAges []int `json:"children_ages,omitempty"`
+}
+
+// UniformOccupancy returns rooms identical rooms of adults each.
+func UniformOccupancy(rooms, adults int) ([]Room, error) {
+	if rooms < 1 || rooms > maxRooms {
+		return nil, fmt.Errorf("rooms must be between 1 and %d", maxRooms)
+	}
+	if adults < 1 || adults > maxAdultsPerRoom {
+		return nil, fmt.Errorf("adults must be between 1 and %d", maxAdultsPerRoom)
+	}
+	occ := make([]Room, rooms)
+	for i := range occ {
+		occ[i] = Room{Adults: adults}
+	}
+	return occ, nil
+}
+
+// ParseOccupancy parses "2;2:5,8" as two rooms, the first with two adults and
+// the second with two adults and children aged 5 and 8.
+func ParseOccupancy(spec string) ([]Room, error) {
+	var rooms []Room
+	for i, part := range strings.Split(spec, occupancyRoomSep) {
+		adultsStr, agesStr, hasChildren := strings.Cut(strings.TrimSpace(part), occupancyChildSep)
+
+		adults, err := strconv.Atoi(strings.TrimSpace(adultsStr))
+		if err != nil {
+			return nil, fmt.Errorf("room %d: invalid adult count %q", i+1, adultsStr)
+		}
+		room := Room{Adults: adults}
+
+		if hasChildren {
+			for _, a := range strings.Split(agesStr, ",") {
+				age, err := strconv.Atoi(strings.TrimSpace(a))
+				if err != nil {
+					return nil, fmt.Errorf("room %d: invalid child age %q", i+1, a)
+				}
+				room.ChildrenAges = append(room.ChildrenAges, age)
+			}
+		}
+		rooms = append(rooms, room)
+	}
+	return rooms, validateOccupancy(rooms)
+}
+
+func validateOccupancy(rooms []Room) error {
+	if len(rooms) < 1 || len(rooms) > maxRooms {
+		return fmt.Errorf("rooms must be between 1 and %d", maxRooms)
+	}
+
+	var errs []error
+	for i, r := range rooms {
+		if r.Adults < 1 || r.Adults > maxAdultsPerRoom {
+			errs = append(errs, fmt.Errorf("room %d: adults must be between 1 and %d", i+1, maxAdultsPerRoom))
+		}
+		if len(r.ChildrenAges) > maxChildrenPerRoom {
+			errs = append(errs, fmt.Errorf("room %d: at most %d children", i+1, maxChildrenPerRoom))
+		}
+		for _, age := range r.ChildrenAges {
+			if age < 0 || age > maxChildAge {
+				errs = append(errs, fmt.Errorf("room %d: child age %d must be between 0 and %d", i+1, age, maxChildAge))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// distribution encodes rooms for the distribution URL parameter: each room is
+// "<adults>[-<childAge>...]" and rooms are joined by "!", e.g. "2!2-5-8".
+func distribution(rooms []Room) string {
+	parts := make([]string, len(rooms))
+	for i, r := range rooms {
+		fields := []string{strconv.Itoa(r.Adults)}
+		for _, age := range r.ChildrenAges {
+			fields = append(fields, strconv.Itoa(age))
+		}
+		parts[i] = strings.Join(fields, "-")
+	}
+	return strings.Join(parts, distributionRoomSep)
+}
+
+// FormatOccupancy renders rooms in the ParseOccupancy syntax.
+func FormatOccupancy(rooms []Room) string {
+	parts := make([]string, len(rooms))
+	for i, r := range rooms {
+		parts[i] = strconv.Itoa(r.Adults)
+		if len(r.ChildrenAges) > 0 {
+			ages := make([]string, len(r.ChildrenAges))
+			for j, age := range r.ChildrenAges {
+				ages[j] = strconv.Itoa(age)
+			}
+			parts[i] += occupancyChildSep + strings.Join(ages, ",")
+		}
+	}
+	return strings.Join(parts, occupancyRoomSep)
+}
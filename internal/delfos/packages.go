@@ -0,0 +1,268 @@
+package delfos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"ExpeditusClient/internal/browser"
+	"ExpeditusClient/internal/money"
+
+	"github.com/chromedp/chromedp"
+)
+
+// TripFlightHotel is the flight plus accommodation package search.
+const TripFlightHotel TripType = "FLIGHT_HOTEL"
+
+// PackageComponent is a service included in a package.
+type PackageComponent string
+
+const (
+	ComponentFlight    PackageComponent = "flight"
+	ComponentHotel     PackageComponent = "hotel"
+	ComponentTransfer  PackageComponent = "transfer"
+	ComponentInsurance PackageComponent = "insurance"
+	ComponentExcursion PackageComponent = "excursion"
+	ComponentTicket    PackageComponent = "ticket"
+)
+
+// PackageSearchRequest is a flight plus hotel search as accepted by the home
+// page's directSubmit handler.
+type PackageSearchRequest struct {
+	Origin      string // airport or city code the flight leaves from
+	Destination string // destination code, e.g. "MIA" or "Destination::MIA"
+	Departure   time.Time
+	Return      time.Time
+	Occupancy   []Room
+}
+
+// Nights returns the length of the stay.
+func (r PackageSearchRequest) Nights() int {
+	return int(r.Return.Sub(r.Departure).Hours() / 24)
+}
+
+// Validate checks the request against today's date.
+func (r PackageSearchRequest) Validate(today time.Time) error {
+	var errs []error
+
+	for _, f := range []struct{ name, value string }{{"origin", r.Origin}, {"destination", r.Destination}} {
+		switch {
+		case strings.TrimSpace(f.value) == "":
+			errs = append(errs, fmt.Errorf("%s is required", f.name))
+		case siteCode(f.value) == "":
+			errs = append(errs, fmt.Errorf("%s %q is not a site code", f.name, f.value))
+		}
+	}
+	if r.Origin != "" && siteCode(r.Origin) == siteCode(r.Destination) {
+		errs = append(errs, errors.New("origin and destination must differ"))
+	}
+
+	if r.Departure.IsZero() || r.Return.IsZero() {
+		errs = append(errs, errors.New("departure and return dates are required"))
+	} else {
+		day := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, r.Departure.Location())
+		if r.Departure.Before(day) {
+			errs = append(errs, fmt.Errorf("departure %s is in the past", r.Departure.Format(DateLayout)))
+		}
+		if !r.Return.After(r.Departure) {
+			errs = append(errs, fmt.Errorf("return %s must be after departure %s", r.Return.Format(DateLayout), r.Departure.Format(DateLayout)))
+		}
+	}
+
+	if err := validateOccupancy(r.Occupancy); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// URL builds the home?directSubmit=true package search URL relative to base.
+func (r PackageSearchRequest) URL(base string) (string, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("parse base url: %w", err)
+	}
+	u = u.ResolveReference(&url.URL{Path: "home"})
+
+	q := url.Values{}
+	q.Set("directSubmit", "true")
+	q.Set("latestSearch", "true")
+	q.Set("tripType", string(TripFlightHotel))
+	q.Set("departureDate", r.Departure.Format(DateLayout))
+	q.Set("arrivalDate", r.Return.Format(DateLayout))
+	q.Set("flightOrigin", siteCode(r.Origin))
+	q.Set("flightDestination", siteCode(r.Destination))
+	q.Set("hotelDestination", siteCode(r.Destination))
+	q.Set("distribution", distribution(r.Occupancy))
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// PackageOffer is a package from the package search results or one of the
+// promotions featured on the home page.
+type PackageOffer struct {
+	Title            string             `json:"title"`
+	Components       []PackageComponent `json:"components,omitempty"`
+	Includes         []string           `json:"includes,omitempty"`
+	Nights           int                `json:"nights,omitempty"`
+	Destinations     []string           `json:"destinations,omitempty"`
+	DestinationCount int                `json:"destination_count,omitempty"`
+	PricePerPerson   money.Money        `json:"price_per_person,omitzero"`
+	TotalPrice       money.Money        `json:"total_price,omitzero"`
+	DetailURL        string             `json:"detail_url,omitempty"`
+	Featured         bool               `json:"featured,omitempty"`
+	Warnings         []string           `json:"warnings,omitempty"` // prices that could not be read
+}
+
+// rawPackage is an offer as read from the page, with its prices still as text.
+type rawPackage struct {
+	PackageOffer
+	PricePerPerson string `json:"price_per_person"`
+	TotalPrice     string `json:"total_price"`
+}
+
+func (r rawPackage) offer() PackageOffer {
+	p := r.PackageOffer
+	p.PricePerPerson = parsePrice("price per person", r.PricePerPerson, &p.Warnings)
+	p.TotalPrice = parsePrice("total price", r.TotalPrice, &p.Warnings)
+	return p
+}
+
+// SearchPackages navigates to the package results for req and extracts every
+// offer. The browser must already be logged in.
+func SearchPackages(ctx context.Context, base string, req PackageSearchRequest) ([]PackageOffer, error) {
+	if err := req.Validate(time.Now()); err != nil {
+		return nil, err
+	}
+	target, err := req.URL(base)
+	if err != nil {
+		return nil, err
+	}
+
+	offers, err := extractPackages(ctx, target)
+	if err != nil {
+		return nil, fmt.Errorf("package search failed: %w", err)
+	}
+	for i := range offers {
+		if offers[i].Nights == 0 {
+			offers[i].Nights = req.Nights()
+		}
+	}
+	return offers, nil
+}
+
+// FeaturedPackages reads the promotional packages (e.g. "Mundial 2026")
+// shown on the home page.
+func FeaturedPackages(ctx context.Context, base string) ([]PackageOffer, error) {
+	offers, err := extractPackages(ctx, base)
+	if err != nil {
+		return nil, fmt.Errorf("featured packages: %w", err)
+	}
+	for i := range offers {
+		offers[i].Featured = true
+	}
+	return offers, nil
+}
+
+func extractPackages(ctx context.Context, target string) ([]PackageOffer, error) {
+	var raw []rawPackage
+	err := chromedp.Run(ctx,
+		browser.BestEffort(browser.NetworkIdle(chromedp.Navigate(target), time.Second, 45*time.Second)),
+		chromedp.WaitReady("body", chromedp.ByQuery),
+		browser.WaitAjaxIdle(45*time.Second),
+		browser.BestEffort(browser.WaitDOMStable("body", time.Second, 20*time.Second)),
+		chromedp.Evaluate(buildExtractPackagesScript(), &raw),
+	)
+	if err != nil {
+		return nil, err
+	}
+	offers := make([]PackageOffer, len(raw))
+	for i, r := range raw {
+		offers[i] = r.offer()
+	}
+	return offers, nil
+}
+
+func buildExtractPackagesScript() string {
+	return `(() => {
+		const PRICE = /(?:US\$|U\$S|U\$D|USD|ARS|EUR|€|\$)\s?[\d.,]+/;
+		const NIGHTS = /(\d+)\s*noches?\b/i;
+		const nightsCount = (el) => ((el.innerText || '').match(/\d+\s*noches?\b/gi) || []).length;
+		const clean = (s) => (s || '').replace(/\s+/g, ' ').trim();
+		const visible = (el) => el.offsetParent !== null;
+		const texts = (root, sel) => Array.from(root.querySelectorAll(sel)).filter(visible).map(el => clean(el.innerText)).filter(Boolean);
+
+		// ANCHOR: A package card states its length ("3 noches") exactly once next to a price;
+		// climb from that line to the largest ancestor that still holds a single one.
+		const cards = [];
+		const walker = document.createTreeWalker(document.body, NodeFilter.SHOW_TEXT);
+		while (walker.nextNode()) {
+			const node = walker.currentNode;
+			if (!NIGHTS.test(node.textContent)) continue;
+			const el = node.parentElement;
+			if (!el || !visible(el)) continue;
+			let card = el;
+			while (card.parentElement && card.parentElement !== document.body && nightsCount(card.parentElement) <= 1) {
+				card = card.parentElement;
+			}
+			if (nightsCount(card) === 1 && PRICE.test(card.innerText || '') && !cards.includes(card)) cards.push(card);
+		}
+
+		const componentRules = [
+			['flight', /a[eé]reo|vuelo|flight|pasaje/i],
+			['hotel', /hotel|alojamiento|HTL|hospedaje|\d\s*\*/i],
+			['transfer', /traslado|transfer/i],
+			['insurance', /asistencia|seguro|insurance/i],
+			['excursion', /excursi[oó]n|city tour|paseo/i],
+			['ticket', /entrada|partido|ticket|match/i]
+		];
+
+		return cards.map(card => {
+			const text = card.innerText || '';
+			const lines = text.split('\n').map(clean).filter(Boolean);
+
+			const title = texts(card, 'h2, h3, h4, [class*="title" i], [class*="name" i]')
+				.map(t => t.split('\n')[0]).find(t => !PRICE.test(t) && !NIGHTS.test(t)) ||
+				lines.find(l => !PRICE.test(l) && !NIGHTS.test(l) && l.length > 3) || '';
+
+			const includes = texts(card, 'li, [class*="include" i], [class*="incluye" i], [class*="service" i]')
+				.filter(t => !PRICE.test(t) && t.length < 120);
+			const components = componentRules
+				.filter(([, re]) => re.test(title) || includes.some(t => re.test(t)) || lines.some(l => re.test(l) && l.length < 80))
+				.map(([name]) => name);
+
+			const countMatch = text.match(/(\d+)\s*destinos?\b/i);
+			const destinations = texts(card, '[class*="destination" i], [class*="destino" i], [class*="city" i]')
+				.filter(t => !/\d+\s*destinos?\b/i.test(t) && !PRICE.test(t) && t.length < 80);
+
+			// ANCHOR: Promotions quote "por persona"/"desde" prices; results also show a total
+			const perPersonLine = lines.find(l => PRICE.test(l) && /por persona|p\/p|x persona|por pax|base doble|desde/i.test(l));
+			const totalLine = lines.find(l => PRICE.test(l) && /total/i.test(l));
+			const prices = lines.filter(l => PRICE.test(l)).map(l => clean(l.match(PRICE)[0]));
+			let perPerson = perPersonLine ? clean(perPersonLine.match(PRICE)[0]) : '';
+			let total = totalLine ? clean(totalLine.match(PRICE)[0]) : '';
+			if (!perPerson && !total && prices.length > 0) perPerson = prices[0];
+
+			const link = Array.from(card.querySelectorAll('a[href]')).find(a => {
+				const href = a.getAttribute('href') || '';
+				return href && href !== '#' && !href.startsWith('javascript');
+			}) || card.closest('a[href]');
+
+			return {
+				title: title,
+				components: components,
+				includes: [...new Set(includes)],
+				nights: parseInt((text.match(NIGHTS) || [])[1] || '0', 10),
+				destinations: [...new Set(destinations)],
+				destination_count: countMatch ? parseInt(countMatch[1], 10) : new Set(destinations).size,
+				price_per_person: perPerson,
+				total_price: total,
+				detail_url: link ? link.href : ''
+			};
+		}).filter(p => p.title && (p.price_per_person || p.total_price));
+	})()`
+}
@@ -0,0 +1,53 @@
+package delfos
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestPackageSearchRequest(t *testing.T) {
+	today := time.Date(2026, 5, 1, 15, 0, 0, 0, time.UTC)
+	dep, _ := ParseDate("09/05/2026")
+	ret, _ := ParseDate("16/05/2026")
+
+	req := PackageSearchRequest{Origin: "EZE", Destination: "MIA", Departure: dep, Return: ret, Occupancy: []Room{{Adults: 2}}}
+	if err := req.Validate(today); err != nil {
+		t.Fatalf("valid request: %v", err)
+	}
+	if n := req.Nights(); n != 7 {
+		t.Errorf("nights = %d", n)
+	}
+
+	raw, err := req.URL("https://www.delfos.tur.ar/")
+	if err != nil {
+		t.Fatalf("URL: %v", err)
+	}
+	u, _ := url.Parse(raw)
+	want := map[string]string{
+		"tripType":          "FLIGHT_HOTEL",
+		"departureDate":     "09/05/2026",
+		"arrivalDate":       "16/05/2026",
+		"flightOrigin":      "Destination::EZE",
+		"hotelDestination":  "Destination::MIA",
+		"flightDestination": "Destination::MIA",
+		"distribution":      "2",
+	}
+	for k, v := range want {
+		if got := u.Query().Get(k); got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+
+	invalid := []PackageSearchRequest{
+		{Destination: "MIA", Departure: dep, Return: ret, Occupancy: []Room{{Adults: 2}}},
+		{Origin: "EZE", Destination: "MIA", Departure: dep, Return: dep, Occupancy: []Room{{Adults: 2}}},
+		{Origin: "EZE", Destination: "Miami", Departure: dep, Return: ret, Occupancy: []Room{{Adults: 2}}},
+		{Origin: "EZE", Destination: "MIA", Departure: dep, Return: ret},
+	}
+	for i, r := range invalid {
+		if err := r.Validate(today); err == nil {
+			t.Errorf("invalid[%d]: expected error", i)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package delfos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"ExpeditusClient/internal/browser"
+	"ExpeditusClient/internal/textnorm"
+
+	"github.com/chromedp/chromedp"
+)
+
+// DefaultMaxPages is the number of result pages IterateHotels reads when
+// ResultsOptions.MaxPages is not set.
+const DefaultMaxPages = 50
+
+// ErrStopIteration can be returned by an IterateHotels callback to stop early
+// without IterateHotels reporting an error.
+var ErrStopIteration = errors.New("stop iteration")
+
+// ResultsOptions bounds IterateHotels.
+type ResultsOptions struct {
+	Limit    int           // stop after this many unique hotels; 0 means no limit
+	MaxPages int           // maximum "ver más"/next-page/scroll steps; 0 means 50
+	Settle   time.Duration // DOM quiet period after each step; 0 means 1s
+}
+
+// IterateHotels extracts the hotels on the results page, then keeps clicking
+// "ver más"/next-page controls or scrolling to trigger lazy loading until a
+// step yields no unseen hotel, the limit is reached or yield returns an error.
+// Hotels appearing on more than one page are passed to yield only once.
+func IterateHotels(ctx context.Context, opts ResultsOptions, yield func(page int, h Hotel) error) error {
+	maxPages := opts.MaxPages
+	if maxPages <= 0 {
+		maxPages = DefaultMaxPages
+	}
+	settle := opts.settle()
+
+	stream := hotelStream{limit: opts.Limit, seen: make(map[string]bool), yield: yield}
+	for page := 1; ; page++ {
+		hotels, err := ExtractHotels(ctx)
+		if err != nil {
+			return fmt.Errorf("page %d: %w", page, err)
+		}
+
+		fresh, done, err := stream.add(page, hotels)
+		if err != nil || done {
+			return err
+		}
+		if !keepPaging(page, fresh, maxPages) {
+			return nil
+		}
+
+		more, err := loadMoreResults(ctx, settle)
+		if err != nil {
+			return fmt.Errorf("load page %d: %w", page+1, err)
+		}
+		if !more {
+			return nil
+		}
+	}
+}
+
+// ReturnToResults reopens the results page at resultsURL and repeats the
+// "ver más"/next-page/scroll steps that IterateHotels took to reach page, so a
+// card first seen there can be clicked again.
+func ReturnToResults(ctx context.Context, resultsURL string, page int, opts ResultsOptions) error {
+	err := chromedp.Run(ctx,
+		chromedp.Navigate(resultsURL),
+		chromedp.WaitReady("body", chromedp.ByQuery),
+		browser.WaitAjaxIdle(pageTimeout),
+	)
+	if err != nil {
+		return fmt.Errorf("return to results: %w", err)
+	}
+	for p := 1; p < page; p++ {
+		more, err := loadMoreResults(ctx, opts.settle())
+		if err != nil {
+			return fmt.Errorf("return to results page %d: %w", p+1, err)
+		}
+		if !more {
+			return fmt.Errorf("return to results page %d: the list ends at page %d", page, p)
+		}
+	}
+	return nil
+}
+
+func (o ResultsOptions) settle() time.Duration {
+	if o.Settle <= 0 {
+		return time.Second
+	}
+	return o.Settle
+}
+
+// keepPaging reports whether to load the page after page, which yielded fresh
+// unseen hotels. The first page may be empty while lazy loading kicks in.
+func keepPaging(page, fresh, maxPages int) bool {
+	return (page == 1 || fresh > 0) && page < maxPages
+}
+
+// hotelStream passes each hotel to yield once and tracks the limit.
+type hotelStream struct {
+	limit int
+	seen  map[string]bool
+	yield func(page int, h Hotel) error
+}
+
+// add yields the hotels of page not seen before and returns how many there
+// were. done reports that the limit was reached or yield asked to stop;
+// ErrStopIteration is not returned as an error.
+func (s *hotelStream) add(page int, hotels []Hotel) (fresh int, done bool, err error) {
+	for _, h := range hotels {
+		key := hotelKey(h)
+		if s.seen[key] {
+			continue
+		}
+		s.seen[key] = true
+		fresh++
+
+		if err := s.yield(page, h); err != nil {
+			if errors.Is(err, ErrStopIteration) {
+				return fresh, true, nil
+			}
+			return fresh, true, err
+		}
+		if s.limit > 0 && len(s.seen) >= s.limit {
+			return fresh, true, nil
+		}
+	}
+	return fresh, false, nil
+}
+
+func hotelKey(h Hotel) string {
+	return textnorm.Fold(h.Name) + "|" + textnorm.Fold(h.Address)
+}
+
+// loadMoreResults clicks the next "ver más"/paginator control, or scrolls to
+// the bottom when there is none, and waits for the list to settle. It reports
+// false when neither is possible.
+func loadMoreResults(ctx context.Context, settle time.Duration) (bool, error) {
+	var more struct {
+		Step      string `json:"step"`
+		Component string `json:"component"`
+	}
+	if err := chromedp.Run(ctx, chromedp.Evaluate(buildLocateMoreScript(), &more)); err != nil {
+		return false, err
+	}
+
+	var wait chromedp.Action
+	switch {
+	case more.Step == "none":
+		return false, nil
+	case more.Step == "scroll":
+		wait = scrollAndSettle(chromedp.Evaluate(scrollToBottomExpr, nil), settle)
+	case more.Component != "":
+		// the control's data table or form is re-rendered with the next page
+		wait = browser.BestEffort(browser.WaitPartialUpdate(more.Component, chromedp.Evaluate(clickLoadMoreExpr, nil), pageTimeout))
+	default:
+		wait = scrollAndSettle(chromedp.Evaluate(clickLoadMoreExpr, nil), settle)
+	}
+	if err := chromedp.Run(ctx, wait); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// scrollAndSettle runs trigger and waits for whatever it loads when there is
+// no known component to watch.
+func scrollAndSettle(trigger chromedp.Action, settle time.Duration) chromedp.Action {
+	return chromedp.Tasks{
+		browser.BestEffort(browser.NetworkIdle(trigger, 750*time.Millisecond, pageTimeout)),
+		browser.WaitAjaxIdle(pageTimeout),
+		browser.BestEffort(browser.WaitDOMStable("body", settle, pageTimeout)),
+	}
+}
+
+const loadMoreSel = `[data-expeditus="load-more"]`
+
+const clickLoadMoreExpr = `document.querySelector('` + loadMoreSel + `')?.click()`
+
+const scrollToBottomExpr = `window.scrollTo(0, document.documentElement.scrollHeight)`
+
+// buildLocateMoreScript tags the control that loads the next results, if any,
+// and returns the step it takes ("more", "next", "scroll" or "none") and the
+// client id of the data component or form it re-renders.
+func buildLocateMoreScript() string {
+	return `(() => {
+		const visible = (el) => !!el && el.offsetParent !== null && !el.disabled &&
+			!el.classList.contains('ui-state-disabled') && el.getAttribute('aria-disabled') !== 'true';
+		const component = (el) => (el.closest('.ui-datatable[id], .ui-datagrid[id], .ui-dataview[id], .ui-datalist[id], .ui-datascroller[id]') ||
+			el.closest('form[id]'))?.id || '';
+		document.querySelectorAll('` + loadMoreSel + `').forEach(el => el.removeAttribute('data-expeditus'));
+
+		// ANCHOR: "ver más" style buttons append results in place
+		const labels = ['ver más', 'ver mas', 'cargar más', 'cargar mas', 'mostrar más', 'mostrar mas', 'más resultados', 'ver más hoteles'];
+		const more = Array.from(document.querySelectorAll('a, button, [role="button"]'))
+			.find(el => visible(el) && labels.includes((el.innerText || '').trim().toLowerCase()));
+		if (more) {
+			more.setAttribute('data-expeditus', 'load-more');
+			return {step: 'more', component: component(more)};
+		}
+
+		// ANCHOR: PrimeFaces/data-table paginators replace the page
+		const next = Array.from(document.querySelectorAll(
+			'.ui-paginator-next, a[rel="next"], [aria-label="Next Page" i], [aria-label="Siguiente" i]'
+		)).find(visible) || Array.from(document.querySelectorAll('a, button'))
+			.find(el => visible(el) && ['siguiente', '›', '»'].includes((el.innerText || '').trim().toLowerCase()));
+		if (next) {
+			next.setAttribute('data-expeditus', 'load-more');
+			return {step: 'next', component: component(next)};
+		}
+
+		const bottom = window.scrollY + window.innerHeight >= document.documentElement.scrollHeight - 1;
+		return {step: bottom ? 'none' : 'scroll', component: ''};
+	})()`
+}
@@ -0,0 +1,91 @@
+package delfos
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestHotelKey(t *testing.T) {
+	tests := []struct {
+		a, b Hotel
+		same bool
+	}{
+		{Hotel{Name: "Riu Palace", Address: "Playa Arena Gorda"}, Hotel{Name: "RIU  PALACE", Address: "playa arena gorda"}, true},
+		{Hotel{Name: "Meliá Caribe"}, Hotel{Name: "Melia Caribe", RoomType: "Suite"}, true},
+		{Hotel{Name: "Riu Palace", Address: "Bávaro"}, Hotel{Name: "Riu Palace", Address: "Macao"}, false},
+		{Hotel{Name: "Riu Palace"}, Hotel{Name: "Riu Palace Bavaro"}, false},
+	}
+	for _, tt := range tests {
+		if same := hotelKey(tt.a) == hotelKey(tt.b); same != tt.same {
+			t.Errorf("%+v vs %+v: same key = %v, want %v", tt.a, tt.b, same, tt.same)
+		}
+	}
+}
+
+func TestHotelStream(t *testing.T) {
+	errFailed := errors.New("write failed")
+	pages := [][]Hotel{
+		{{Name: "A"}, {Name: "B"}, {Name: "C"}},
+		{{Name: "b"}, {Name: "D"}, {Name: "C"}, {Name: "E"}},
+		{{Name: "A"}, {Name: "E"}},
+	}
+	tests := []struct {
+		name    string
+		limit   int
+		stopAt  string // yield returns stopErr for this hotel
+		stopErr error
+		want    []string
+		fresh   []int // per page added before done
+		err     error
+	}{
+		{"all pages", 0, "", nil, []string{"A", "B", "C", "D", "E"}, []int{3, 2, 0}, nil},
+		{"limit within a page", 4, "", nil, []string{"A", "B", "C", "D"}, []int{3, 1}, nil},
+		{"limit at a page end", 3, "", nil, []string{"A", "B", "C"}, []int{3}, nil},
+		{"stop", 0, "D", ErrStopIteration, []string{"A", "B", "C", "D"}, []int{3, 1}, nil},
+		{"error", 0, "B", errFailed, []string{"A", "B"}, []int{2}, errFailed},
+	}
+	for _, tt := range tests {
+		var got []string
+		s := hotelStream{limit: tt.limit, seen: make(map[string]bool), yield: func(page int, h Hotel) error {
+			got = append(got, h.Name)
+			if h.Name == tt.stopAt {
+				return tt.stopErr
+			}
+			return nil
+		}}
+
+		var fresh []int
+		var err error
+		for i, hotels := range pages {
+			n, done, addErr := s.add(i+1, hotels)
+			fresh = append(fresh, n)
+			if done {
+				err = addErr
+				break
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) || !reflect.DeepEqual(fresh, tt.fresh) || err != tt.err {
+			t.Errorf("%s: yielded %v fresh %v err %v, want %v %v %v", tt.name, got, fresh, err, tt.want, tt.fresh, tt.err)
+		}
+	}
+}
+
+func TestKeepPaging(t *testing.T) {
+	tests := []struct {
+		page, fresh, max int
+		want             bool
+	}{
+		{1, 0, 50, true}, // lazy lists can start empty
+		{1, 5, 50, true},
+		{2, 3, 50, true},
+		{2, 0, 50, false}, // nothing new: the site is repeating itself
+		{3, 4, 3, false},
+		{1, 5, 1, false},
+	}
+	for _, tt := range tests {
+		if got := keepPaging(tt.page, tt.fresh, tt.max); got != tt.want {
+			t.Errorf("keepPaging(%d, %d, %d) = %v, want %v", tt.page, tt.fresh, tt.max, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package delfos
+
+import (
+	"fmt"
+	"strings"
+
+	"ExpeditusClient/internal/money"
+)
+
+// parsePrice parses a price read from a card as text. An unreadable price is
+// left zero and noted in warnings, so one odd card does not fail the page.
+func parsePrice(field, text string, warnings *[]string) money.Money {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return money.Money{}
+	}
+	m, err := money.Parse(text)
+	if err != nil {
+		*warnings = append(*warnings, fmt.Sprintf("%s %q: %v", field, text, err))
+		return money.Money{}
+	}
+	return m
+}
@@ -0,0 +1,47 @@
+package delfos
+
+import (
+	"encoding/json"
+	"testing"
+
+	"ExpeditusClient/internal/money"
+)
+
+func TestCardPrices(t *testing.T) {
+	page := `[
+		{"name": "Hotel Riu", "nightly_price": "US$ 150", "total_price": "US$ 1.050"},
+		{"name": "Meliá", "nightly_price": "", "total_price": "$ a consultar"},
+		{"name": "Barceló", "total_price": "USD 990,50"}
+	]`
+	var raw []rawHotel
+	if err := json.Unmarshal([]byte(page), &raw); err != nil {
+		t.Fatalf("one odd price failed the page: %v", err)
+	}
+	if len(raw) != 3 {
+		t.Fatalf("cards = %d", len(raw))
+	}
+
+	want := []struct {
+		name     string
+		total    money.Money
+		warnings int
+	}{
+		{"Hotel Riu", money.New(1050, 0, money.USD), 0},
+		{"Meliá", money.Money{}, 1},
+		{"Barceló", money.New(990, 50, money.USD), 0},
+	}
+	for i, w := range want {
+		h := raw[i].hotel()
+		if h.Name != w.name || h.TotalPrice != w.total || len(h.Warnings) != w.warnings {
+			t.Errorf("card %d = %+v, want %s %v with %d warnings", i, h, w.name, w.total, w.warnings)
+		}
+	}
+
+	var offer rawPackage
+	if err := json.Unmarshal([]byte(`{"title": "Mundial 2026", "price_per_person": "US$ ???", "total_price": "US$ 4.000"}`), &offer); err != nil {
+		t.Fatal(err)
+	}
+	if p := offer.offer(); p.Title != "Mundial 2026" || !p.PricePerPerson.IsZero() || p.TotalPrice != money.New(4000, 0, money.USD) || len(p.Warnings) != 1 {
+		t.Errorf("package = %+v", p)
+	}
+}
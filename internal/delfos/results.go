@@ -0,0 +1,300 @@
+package delfos
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"ExpeditusClient/internal/money"
+
+	"github.com/chromedp/chromedp"
+)
+
+// Hotel is one result card from the hotel search results page.
+type Hotel struct {
+	Name         string      `json:"name"`
+	Category     string      `json:"category,omitempty"`
+	Stars        float64     `json:"stars,omitempty"`
+	Address      string      `json:"address,omitempty"`
+	Zone         string      `json:"zone,omitempty"`
+	RoomType     string      `json:"room_type,omitempty"`
+	Board        string      `json:"board,omitempty"`
+	Refundable   *bool       `json:"refundable,omitempty"`
+	Provider     string      `json:"provider,omitempty"`
+	NightlyPrice money.Money `json:"nightly_price,omitzero"`
+	TotalPrice   money.Money `json:"total_price,omitzero"`
+	DetailURL    string      `json:"detail_url,omitempty"`
+	Warnings     []string    `json:"warnings,omitempty"` // prices that could not be read
+}
+
+// rawHotel is a card as read from the page, with its prices still as text.
+type rawHotel struct {
+	Hotel
+	NightlyPrice string `json:"nightly_price"`
+	TotalPrice   string `json:"total_price"`
+}
+
+func (r rawHotel) hotel() Hotel {
+	h := r.Hotel
+	h.NightlyPrice = parsePrice("nightly price", r.NightlyPrice, &h.Warnings)
+	h.TotalPrice = parsePrice("total price", r.TotalPrice, &h.Warnings)
+	return h
+}
+
+// ExtractHotels reads every hotel card currently rendered on the results page.
+// Cards are located from their "Total" price line rather than from a list of
+// known hotel names, so any hotel the site returns is picked up. Prices are
+// read as text and parsed card by card; a card whose price cannot be read is
+// kept with a zero price and a warning.
+func ExtractHotels(ctx context.Context) ([]Hotel, error) {
+	var raw []rawHotel
+	if err := chromedp.Run(ctx, chromedp.Evaluate(buildExtractHotelsScript(), &raw)); err != nil {
+		return nil, fmt.Errorf("extract hotels: %w", err)
+	}
+	hotels := make([]Hotel, len(raw))
+	for i, r := range raw {
+		hotels[i] = r.hotel()
+	}
+	return hotels, nil
+}
+
+// Markup patterns for server-rendered result cards, as returned to the
+// browserless client. They follow the in-page extraction script.
+const cardPrice = `(?:US\$|U\$S|U\$D|USD|ARS|EUR|€|\$)\s?[\d.,]+`
+
+var (
+	// resultItemRe opens one item of a PrimeFaces data component
+	resultItemRe    = regexp.MustCompile(`(?i)<(?:div|li|tr)\b[^>]*\bclass="[^"]*\b(?:ui-datagrid-column|ui-dataview-row|ui-datalist-item|ui-datascroller-item|ui-widget-content ui-datatable-(?:even|odd))\b[^"]*"[^>]*>`)
+	cardTotalRe     = regexp.MustCompile(`(?i)Total[^\d$€]{0,20}?(` + cardPrice + `)`)
+	cardNightlyRe   = regexp.MustCompile(`(?i)(` + cardPrice + `)\s*(?:/|por)\s*noche|noche[^\d$€]{0,15}?(` + cardPrice + `)`)
+	cardClassStarRe = regexp.MustCompile(`(?i)class="[^"]*\b(?:stars?|category|cat)[-_]?(\d(?:[._]5)?)\b`)
+	cardTextStarRe  = regexp.MustCompile(`(?i)(\d)\s*(?:\*|estrellas|stars)`)
+	cardHeadingRe   = regexp.MustCompile(`(?is)<h[2-4]\b[^>]*>(.*?)</h[2-4]>`)
+	cardHrefRe      = regexp.MustCompile(`(?i)<a\b[^>]*\bhref="([^"#][^"]*)"`)
+	cardProviderRe  = regexp.MustCompile(`(?i)(?:Proveedor|Provider)\s*:?\s*([^\n]+)`)
+	cardRoomLineRe  = regexp.MustCompile(`(?i)habitaci[oó]n|room|suite|doble|double|twin|standard|est[aá]ndar|superior|deluxe`)
+	noRefundRe      = regexp.MustCompile(`(?i)no reembolsable|non[- ]?refundable|sin reembolso|gastos de cancelaci[oó]n del 100`)
+	refundRe        = regexp.MustCompile(`(?i)reembolsable|cancelaci[oó]n gratuita|free cancellation|cancelaci[oó]n sin cargo`)
+	cardBoards      = []string{
+		"Todo incluido", "All inclusive", "Pensión completa", "Full board", "Media pensión", "Half board",
+		"Alojamiento y desayuno", "Desayuno incluido", "Bed and breakfast", "Desayuno", "Solo alojamiento", "Room only", "Sólo alojamiento",
+	}
+)
+
+// parseHotelCards reads the hotel cards in server-rendered results markup,
+// one per item of the page's data component. Like ExtractHotels it keeps the
+// items with a name and a "Total" price and skips package promotions. Links
+// are resolved against pageURL.
+func parseHotelCards(pageURL, markup string) []Hotel {
+	base, _ := url.Parse(pageURL)
+	starts := resultItemRe.FindAllStringIndex(markup, -1)
+
+	var hotels []Hotel
+	for i, start := range starts {
+		end := len(markup)
+		if i+1 < len(starts) {
+			end = starts[i+1][0]
+		}
+		card := markup[start[0]:end]
+		text := cardText(card)
+
+		total := cardTotalRe.FindStringSubmatch(text)
+		name := cardField(card, "hotel-name", "hotelName", "accommodation-name")
+		if name == "" {
+			if m := cardHeadingRe.FindStringSubmatch(card); m != nil {
+				name = firstLine(cardText(m[1]))
+			}
+		}
+		if total == nil || name == "" || packagePromoRe.MatchString(text) {
+			continue
+		}
+
+		raw := rawHotel{TotalPrice: total[1]}
+		raw.Name = name
+		raw.Category = cardField(card, "category", "categoria")
+		raw.Address = cardField(card, "address", "direccion", "location")
+		raw.Zone = cardField(card, "zone", "zona", "area")
+		raw.RoomType = cardField(card, "room-name", "roomName", "room")
+		if raw.RoomType == "" {
+			for _, line := range strings.Split(text, "\n") {
+				if cardRoomLineRe.MatchString(line) && len(line) < 120 {
+					raw.RoomType = line
+					break
+				}
+			}
+		}
+		raw.Board = cardField(card, "board", "regimen", "meal")
+		if raw.Board == "" {
+			lower := strings.ToLower(text)
+			for _, b := range cardBoards {
+				if strings.Contains(lower, strings.ToLower(b)) {
+					raw.Board = b
+					break
+				}
+			}
+		}
+		raw.Provider = cardField(card, "provider", "supplier", "proveedor")
+		if m := cardProviderRe.FindStringSubmatch(text); raw.Provider == "" && m != nil {
+			raw.Provider = strings.TrimSpace(m[1])
+		}
+		if m := cardClassStarRe.FindStringSubmatch(card); m != nil {
+			raw.Stars, _ = strconv.ParseFloat(strings.Replace(m[1], "_", ".", 1), 64)
+		} else if m := cardTextStarRe.FindStringSubmatch(text); m != nil {
+			raw.Stars, _ = strconv.ParseFloat(m[1], 64)
+		}
+		switch {
+		case noRefundRe.MatchString(text):
+			raw.Refundable = new(false)
+		case refundRe.MatchString(text):
+			raw.Refundable = new(true)
+		}
+		if m := cardNightlyRe.FindStringSubmatch(text); m != nil {
+			raw.NightlyPrice = m[1] + m[2]
+		}
+		if m := cardHrefRe.FindStringSubmatch(card); m != nil && !strings.HasPrefix(strings.ToLower(m[1]), "javascript") {
+			raw.DetailURL = html.UnescapeString(m[1])
+			if ref, err := url.Parse(raw.DetailURL); err == nil && base != nil {
+				raw.DetailURL = base.ResolveReference(ref).String()
+			}
+		}
+		hotels = append(hotels, raw.hotel())
+	}
+	return hotels
+}
+
+var packagePromoRe = regexp.MustCompile(`(?i)\b\d+\s*destinos?\b|\bpaquete`)
+
+// cardField returns the first line of text of the first element in card
+// whose class contains one of classes.
+func cardField(card string, classes ...string) string {
+	for _, class := range classes {
+		re := regexp.MustCompile(`(?is)<([a-z][a-z0-9]*)\b[^>]*\bclass="[^"]*` + regexp.QuoteMeta(class) + `[^"]*"[^>]*>(.*?)</([a-z][a-z0-9]*)>`)
+		for _, m := range re.FindAllStringSubmatch(card, -1) {
+			if line := firstLine(cardText(m[2])); line != "" {
+				return line
+			}
+		}
+	}
+	return ""
+}
+
+// cardText is the text of markup with one line per element.
+func cardText(markup string) string {
+	var lines []string
+	for _, line := range strings.Split(html.UnescapeString(tagRe.ReplaceAllString(markup, "\n")), "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(text, "\n")
+	return line
+}
+
+func buildExtractHotelsScript() string {
+	return `(() => {
+		const PRICE = '(?:US\\$|U\\$S|U\\$D|USD|ARS|EUR|€|\\$)\\s?[\\d.,]+';
+		const totalRe = new RegExp('Total[^\\d$€]{0,20}?(' + PRICE + ')', 'i');
+		const nightlyRe = new RegExp('(' + PRICE + ')\\s*(?:\\/|por)\\s*noche|noche[^\\d$€]{0,15}?(' + PRICE + ')', 'i');
+		const totalCount = (el) => ((el.innerText || '').match(/Total[^\d$€]{0,20}(?:US\$|U\$S|U\$D|USD|ARS|EUR|€|\$)/gi) || []).length;
+		const clean = (s) => (s || '').replace(/\s+/g, ' ').trim();
+		const firstText = (card, selectors, firstLine) => {
+			for (const sel of selectors) {
+				const el = card.querySelector(sel);
+				let text = el?.innerText || el?.textContent || '';
+				if (firstLine) text = text.trim().split('\n')[0];
+				text = clean(text);
+				if (text) return text;
+			}
+			return '';
+		};
+
+		// ANCHOR: Every result card has exactly one "Total: US$..." line; climb from it to
+		// the largest ancestor that still contains a single total.
+		const cards = [];
+		const walker = document.createTreeWalker(document.body, NodeFilter.SHOW_TEXT);
+		while (walker.nextNode()) {
+			const node = walker.currentNode;
+			if (!/Total/i.test(node.textContent)) continue;
+			let el = node.parentElement;
+			if (!el || el.offsetParent === null) continue;
+			// the amount may sit in a sibling element of the "Total" label
+			while (el && el !== document.body && !totalRe.test(el.innerText || '')) el = el.parentElement;
+			if (!el || el === document.body) continue;
+			let card = el;
+			while (card.parentElement && card.parentElement !== document.body && totalCount(card.parentElement) <= 1) {
+				card = card.parentElement;
+			}
+			// package promotions ("1 destino, 3 noches") are extracted by SearchPackages/FeaturedPackages
+			if (/\b\d+\s*destinos?\b|\bpaquete/i.test(card.innerText || '')) continue;
+			if (totalCount(card) === 1 && !cards.includes(card)) cards.push(card);
+		}
+
+		const boards = [
+			'Todo incluido', 'All inclusive', 'Pensión completa', 'Full board', 'Media pensión', 'Half board',
+			'Alojamiento y desayuno', 'Desayuno incluido', 'Bed and breakfast', 'Desayuno', 'Solo alojamiento', 'Room only', 'Sólo alojamiento'
+		];
+
+		return cards.map(card => {
+			const text = card.innerText || '';
+			const lower = text.toLowerCase();
+
+			const name = firstText(card, [
+				'[class*="hotel-name" i]', '[class*="hotelName" i]', '[class*="accommodation-name" i]',
+				'h2', 'h3', 'h4', '[class*="name" i] a', '[class*="name" i]', '[class*="title" i]'
+			], true);
+
+			let stars = 0;
+			const classes = Array.from(card.querySelectorAll('[class]')).map(el => el.getAttribute('class')).join(' ');
+			const classStars = classes.match(/(?:stars?|category|cat)[-_]?(\d(?:[._]5)?)\b/i);
+			if (classStars) {
+				stars = parseFloat(classStars[1].replace('_', '.'));
+			} else {
+				const icons = card.querySelectorAll('i[class*="star" i], span[class*="star" i]:empty, svg[class*="star" i]');
+				if (icons.length > 0 && icons.length <= 5) stars = icons.length;
+				const textStars = text.match(/(\d)\s*(?:\*|estrellas|stars)/i);
+				if (!stars && textStars) stars = parseInt(textStars[1], 10);
+			}
+
+			let refundable = null;
+			if (/no reembolsable|non[- ]?refundable|sin reembolso|gastos de cancelaci[oó]n del 100/i.test(text)) {
+				refundable = false;
+			} else if (/reembolsable|cancelaci[oó]n gratuita|free cancellation|cancelaci[oó]n sin cargo/i.test(text)) {
+				refundable = true;
+			}
+
+			const providerMatch = text.match(/(?:Proveedor|Provider)\s*:?\s*([^\n]+)/i);
+			const roomLine = text.split('\n').map(clean).find(l => /habitaci[oó]n|room|suite|doble|double|twin|standard|est[aá]ndar|superior|deluxe/i.test(l) && l.length < 120);
+			const totalMatch = text.match(totalRe);
+			const nightlyMatch = text.match(nightlyRe);
+			const link = Array.from(card.querySelectorAll('a[href]')).find(a => {
+				const href = a.getAttribute('href') || '';
+				return href && href !== '#' && !href.startsWith('javascript');
+			});
+
+			return {
+				name: name,
+				category: firstText(card, ['[class*="category" i]', '[class*="categoria" i]']),
+				stars: stars,
+				address: firstText(card, ['[class*="address" i]', '[class*="direccion" i]', '[class*="location" i]']),
+				zone: firstText(card, ['[class*="zone" i]', '[class*="zona" i]', '[class*="area" i]']),
+				room_type: firstText(card, ['[class*="room-name" i]', '[class*="roomName" i]', '[class*="room" i]']) || roomLine || '',
+				board: firstText(card, ['[class*="board" i]', '[class*="regimen" i]', '[class*="meal" i]']) ||
+					boards.find(b => lower.includes(b.toLowerCase())) || '',
+				refundable: refundable,
+				provider: firstText(card, ['[class*="provider" i]', '[class*="supplier" i]', '[class*="proveedor" i]']) ||
+					clean(providerMatch?.[1]),
+				nightly_price: clean(nightlyMatch?.[1] || nightlyMatch?.[2]),
+				total_price: clean(totalMatch?.[1]),
+				detail_url: link ? link.href : ''
+			};
+		}).filter(h => h.name && h.total_price);
+	})()`
+}
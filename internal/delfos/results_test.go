@@ -0,0 +1,48 @@
+package delfos
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"ExpeditusClient/internal/money"
+)
+
+func TestHotelFromCard(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		card string
+		want Hotel
+	}{
+		{
+			`{"name": "Riu Palace Bavaro", "category": "5 estrellas", "stars": 5, "address": "Playa Arena Gorda",
+			"zone": "Bávaro", "room_type": "Junior Suite", "board": "Todo incluido", "refundable": true,
+			"provider": "Hotelbeds", "nightly_price": "US$ 150", "total_price": "US$ 1.050",
+			"detail_url": "https://www.delfos.tur.ar/hotel/123"}`,
+			Hotel{
+				Name: "Riu Palace Bavaro", Category: "5 estrellas", Stars: 5, Address: "Playa Arena Gorda",
+				Zone: "Bávaro", RoomType: "Junior Suite", Board: "Todo incluido", Refundable: &yes,
+				Provider: "Hotelbeds", NightlyPrice: money.New(150, 0, money.USD), TotalPrice: money.New(1050, 0, money.USD),
+				DetailURL: "https://www.delfos.tur.ar/hotel/123",
+			},
+		},
+		{
+			`{"name": "Hotel Boutique", "stars": 3.5, "refundable": false, "nightly_price": "", "total_price": "ARS 250.000,50", "detail_url": ""}`,
+			Hotel{Name: "Hotel Boutique", Stars: 3.5, Refundable: &no, TotalPrice: money.New(250000, 50, money.ARS)},
+		},
+		{
+			// the extractor sends null when the card does not say
+			`{"name": "Hostel", "stars": 0, "refundable": null, "total_price": "USD 90"}`,
+			Hotel{Name: "Hostel", TotalPrice: money.New(90, 0, money.USD)},
+		},
+	}
+	for _, tt := range tests {
+		var raw rawHotel
+		if err := json.Unmarshal([]byte(tt.card), &raw); err != nil {
+			t.Fatalf("%s: %v", tt.card, err)
+		}
+		if got := raw.hotel(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("card %s\n got %+v\nwant %+v", tt.want.Name, got, tt.want)
+		}
+	}
+}
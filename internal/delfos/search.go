@@ -0,0 +1,191 @@
+package delfos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"ExpeditusClient/internal/browser"
+
+	"github.com/chromedp/chromedp"
+)
+
+// TripType is the product searched, as named in the tripType URL parameter.
+type TripType string
+
+const (
+	TripOnlyHotel  TripType = "ONLY_HOTEL"
+	TripOnlyFlight TripType = "ONLY_FLIGHT"
+)
+
+// DateLayout is the dd/MM/yyyy format the site uses for dates.
+const DateLayout = "02/01/2006"
+
+const destinationPrefix = "Destination::"
+
+// SearchRequest is a hotel search as accepted by the home page's directSubmit handler.
+type SearchRequest struct {
+	Destination     string // internal code, e.g. "Destination::AUA"; a bare "AUA" is prefixed
+	DestinationName string // visible label for the search form, optional for URL searches
+	CheckIn         time.Time
+	CheckOut        time.Time
+	TripType        TripType
+	Occupancy       []Room
+}
+
+// ParseDate parses a dd/MM/yyyy date.
+func ParseDate(s string) (time.Time, error) {
+	t, err := time.Parse(DateLayout, strings.TrimSpace(s))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, expected dd/mm/yyyy", s)
+	}
+	return t, nil
+}
+
+// Nights returns the length of the stay.
+func (r SearchRequest) Nights() int {
+	return int(r.CheckOut.Sub(r.CheckIn).Hours() / 24)
+}
+
+var bareCodeRe = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// IsDestinationCode reports whether s is a site code ("Destination::MIA") or a
+// bare upper-case three-letter code ("MIA") rather than free text to be
+// resolved. Short names such as "Rio" or "bue" are free text.
+func IsDestinationCode(s string) bool {
+	s = strings.TrimSpace(s)
+	return strings.Contains(s, "::") || bareCodeRe.MatchString(s)
+}
+
+// DestinationCode returns Destination with the "Destination::" prefix applied
+// to bare codes, or "" when Destination is free text.
+func (r SearchRequest) DestinationCode() string {
+	return siteCode(r.Destination)
+}
+
+func siteCode(s string) string {
+	code := strings.TrimSpace(s)
+	switch {
+	case strings.Contains(code, "::"):
+		return code
+	case bareCodeRe.MatchString(code):
+		return destinationPrefix + code
+	default:
+		return ""
+	}
+}
+
+// Validate checks the request against today's date.
+func (r SearchRequest) Validate(today time.Time) error {
+	return errors.Join(r.ValidateStay(today), r.ValidateDestination())
+}
+
+// ValidateDestination checks that the destination is a site code, as it must
+// be once free text has been resolved.
+func (r SearchRequest) ValidateDestination() error {
+	if strings.TrimSpace(r.Destination) != "" && r.DestinationCode() == "" {
+		return fmt.Errorf("destination %q is not a site code; resolve it first", r.Destination)
+	}
+	return nil
+}
+
+// ValidateStay checks everything but the destination format, so that a
+// request for a free-text destination is rejected before resolving it.
+func (r SearchRequest) ValidateStay(today time.Time) error {
+	var errs []error
+
+	if strings.TrimSpace(r.Destination) == "" {
+		errs = append(errs, errors.New("destination is required"))
+	}
+	if r.CheckIn.IsZero() || r.CheckOut.IsZero() {
+		errs = append(errs, errors.New("check-in and check-out dates are required"))
+	} else {
+		day := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, r.CheckIn.Location())
+		if r.CheckIn.Before(day) {
+			errs = append(errs, fmt.Errorf("check-in %s is in the past", r.CheckIn.Format(DateLayout)))
+		}
+		if !r.CheckOut.After(r.CheckIn) {
+			errs = append(errs, fmt.Errorf("check-out %s must be after check-in %s", r.CheckOut.Format(DateLayout), r.CheckIn.Format(DateLayout)))
+		}
+	}
+	if r.TripType != "" && r.TripType != TripOnlyHotel {
+		errs = append(errs, fmt.Errorf("unsupported trip type %q", r.TripType))
+	}
+	if err := validateOccupancy(r.Occupancy); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// URL builds the home?directSubmit=true search URL relative to base.
+func (r SearchRequest) URL(base string) (string, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("parse base url: %w", err)
+	}
+	u = u.ResolveReference(&url.URL{Path: "home"})
+
+	tripType := r.TripType
+	if tripType == "" {
+		tripType = TripOnlyHotel
+	}
+
+	q := url.Values{}
+	q.Set("directSubmit", "true")
+	q.Set("latestSearch", "true")
+	q.Set("tripType", string(tripType))
+	q.Set("departureDate", r.CheckIn.Format(DateLayout))
+	q.Set("arrivalDate", r.CheckOut.Format(DateLayout))
+	q.Set("hotelDestination", r.DestinationCode())
+	q.Set("distribution", distribution(r.Occupancy))
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// siteURL matches any page on base's host, so a navigation that ends on
+// another site (an error or challenge provider) is told apart from one that
+// stayed.
+func siteURL(base string) (*regexp.Regexp, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, fmt.Errorf("parse base url: %w", err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("base url %q has no host", base)
+	}
+	return regexp.MustCompile(`(?i)^https?://` + regexp.QuoteMeta(u.Host) + `(?:[/?#]|$)`), nil
+}
+
+// Search navigates to the results page for req and waits for it to settle.
+// The browser must already be logged in.
+func Search(ctx context.Context, base string, req SearchRequest) error {
+	if err := req.Validate(time.Now()); err != nil {
+		return err
+	}
+	target, err := req.URL(base)
+	if err != nil {
+		return err
+	}
+	site, err := siteURL(base)
+	if err != nil {
+		return err
+	}
+
+	err = chromedp.Run(ctx,
+		browser.BestEffort(browser.NetworkIdle(chromedp.Navigate(target), time.Second, 45*time.Second)),
+		browser.WaitURL(site, pageTimeout),
+		chromedp.WaitReady("body", chromedp.ByQuery),
+		browser.WaitAjaxIdle(30*time.Second),
+		browser.BestEffort(browser.WaitDOMStable("body", time.Second, 15*time.Second)),
+	)
+	if err != nil {
+		return fmt.Errorf("search navigation failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package delfos
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestSearchRequestURL(t *testing.T) {
+	checkIn, _ := ParseDate("09/05/2026")
+	checkOut, _ := ParseDate("23/05/2026")
+	req := SearchRequest{Destination: " AUA ", CheckIn: checkIn, CheckOut: checkOut, Occupancy: []Room{{Adults: 2}, {Adults: 2, ChildrenAges: []int{5, 8}}}}
+
+	raw, err := req.URL("https://www.delfos.tur.ar/")
+	if err != nil {
+		t.Fatalf("URL: %v", err)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("parse %q: %v", raw, err)
+	}
+	if u.Path != "/home" {
+		t.Errorf("path = %q", u.Path)
+	}
+
+	q := u.Query()
+	want := map[string]string{
+		"directSubmit":     "true",
+		"tripType":         "ONLY_HOTEL",
+		"departureDate":    "09/05/2026",
+		"arrivalDate":      "23/05/2026",
+		"hotelDestination": "Destination::AUA",
+		"distribution":     "2!2-5-8",
+	}
+	for k, v := range want {
+		if got := q.Get(k); got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+
+	site, err := siteURL("https://www.delfos.tur.ar/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for u, want := range map[string]bool{
+		raw:                              true,
+		"https://WWW.delfos.tur.ar":      true,
+		"http://www.delfos.tur.ar/login": true,
+		"https://www.delfos.tur.ar.evil.example/home":     false,
+		"https://challenges.example/?r=www.delfos.tur.ar": false,
+		"chrome-error://chromewebdata/":                   false,
+	} {
+		if site.MatchString(u) != want {
+			t.Errorf("siteURL matches %q = %v", u, !want)
+		}
+	}
+}
+
+func TestSearchRequestValidate(t *testing.T) {
+	today := time.Date(2026, 5, 1, 15, 0, 0, 0, time.UTC)
+	day := func(s string) time.Time {
+		d, err := ParseDate(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	valid := SearchRequest{Destination: "MIA", CheckIn: day("01/05/2026"), CheckOut: day("05/05/2026"), Occupancy: []Room{{Adults: 2}}}
+	if err := valid.Validate(today); err != nil {
+		t.Fatalf("valid request: %v", err)
+	}
+	if n := valid.Nights(); n != 4 {
+		t.Errorf("nights = %d", n)
+	}
+
+	invalid := []SearchRequest{
+		{CheckIn: day("01/05/2026"), CheckOut: day("05/05/2026"), Occupancy: []Room{{Adults: 2}}},
+		{Destination: "Punta Cana", CheckIn: day("01/05/2026"), CheckOut: day("05/05/2026"), Occupancy: []Room{{Adults: 2}}},
+		{Destination: "MIA", CheckIn: day("30/04/2026"), CheckOut: day("05/05/2026"), Occupancy: []Room{{Adults: 2}}},
+		{Destination: "MIA", CheckIn: day("05/05/2026"), CheckOut: day("05/05/2026"), Occupancy: []Room{{Adults: 2}}},
+		{Destination: "MIA", CheckIn: day("01/05/2026"), CheckOut: day("05/05/2026"), Occupancy: []Room{}},
+		{Destination: "MIA", CheckIn: day("01/05/2026"), CheckOut: day("05/05/2026"), Occupancy: []Room{{Adults: 2}}, TripType: "CRUISE"},
+	}
+	for i, req := range invalid {
+		if err := req.Validate(today); err == nil {
+			t.Errorf("case %d: expected error", i)
+		}
+	}
+
+	// free text is only rejected by Validate, once it should have been resolved
+	freeText := SearchRequest{Destination: "Punta Cana", CheckIn: day("01/05/2026"), CheckOut: day("05/05/2026"), Occupancy: []Room{{Adults: 2}}}
+	if err := freeText.ValidateStay(today); err != nil {
+		t.Errorf("ValidateStay(free text): %v", err)
+	}
+	for i, req := range invalid {
+		if req.Destination == "Punta Cana" {
+			continue
+		}
+		if err := req.ValidateStay(today); err == nil {
+			t.Errorf("case %d: ValidateStay accepted", i)
+		}
+	}
+	codes := []struct {
+		s    string
+		code bool
+	}{
+		{"MIA", true},
+		{" AUA ", true},
+		{"Destination::PUJ", true},
+		{"Rio", false},
+		{"bue", false},
+		{"mia", false},
+		{"Roma", false},
+		{"Punta Cana", false},
+	}
+	for _, tt := range codes {
+		if got := IsDestinationCode(tt.s); got != tt.code {
+			t.Errorf("IsDestinationCode(%q) = %v, want %v", tt.s, got, tt.code)
+		}
+	}
+
+	if _, err := ParseDate("2026-05-01"); err == nil {
+		t.Error("ParseDate accepted ISO date")
+	}
+}
+
+func TestParseOccupancy(t *testing.T) {
+	rooms, err := ParseOccupancy("2; 3:5,8 ;1:0")
+	if err != nil {
+		t.Fatalf("ParseOccupancy: %v", err)
+	}
+	if got := FormatOccupancy(rooms); got != "2;3:5,8;1:0" {
+		t.Errorf("FormatOccupancy = %q", got)
+	}
+	if got := distribution(rooms); got != "2!3-5-8!1-0" {
+		t.Errorf("distribution = %q", got)
+	}
+
+	for _, spec := range []string{"", "0", "2:18", "2:1,2,3,4,5", "x", "2:a", "1;1;1;1;1;1;1;1;1;1"} {
+		if _, err := ParseOccupancy(spec); err == nil {
+			t.Errorf("ParseOccupancy(%q) accepted", spec)
+		}
+	}
+}
+
+func TestUniformOccupancy(t *testing.T) {
+	rooms, err := UniformOccupancy(2, 3)
+	if err != nil {
+		t.Fatalf("UniformOccupancy: %v", err)
+	}
+	if got := FormatOccupancy(rooms); got != "3;3" {
+		t.Errorf("FormatOccupancy = %q", got)
+	}
+
+	for _, tt := range []struct{ rooms, adults int }{{0, 2}, {-1, 2}, {10, 2}, {1, 0}, {1, -1}, {1, 10}} {
+		if _, err := UniformOccupancy(tt.rooms, tt.adults); err == nil {
+			t.Errorf("UniformOccupancy(%d, %d) accepted", tt.rooms, tt.adults)
+		}
+	}
+}
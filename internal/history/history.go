@@ -0,0 +1,357 @@
+// Package history records searches and the prices they returned in
+// append-only JSON-lines files, and answers price history queries over them.
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ExpeditusClient/internal/money"
+	"ExpeditusClient/internal/textnorm"
+)
+
+const (
+	searchesFile = "searches.jsonl"
+	pricesFile   = "prices.jsonl"
+)
+
+// Kinds of priced items.
+const (
+	KindHotel     = "hotel"
+	KindRate      = "rate"
+	KindItinerary = "itinerary"
+	KindPackage   = "package"
+)
+
+// Search is one recorded search request.
+type Search struct {
+	ID          string    `json:"id"`
+	At          time.Time `json:"at"`
+	Account     string    `json:"account"`
+	TripType    string    `json:"trip_type"`
+	Origin      string    `json:"origin,omitempty"`
+	Destination string    `json:"destination"`
+	CheckIn     time.Time `json:"check_in"`
+	CheckOut    time.Time `json:"check_out,omitzero"`
+	Occupancy   string    `json:"occupancy,omitempty"`
+	Results     int       `json:"results"`
+}
+
+// Price is one priced item seen in a search: a hotel card, a room rate, an
+// itinerary or a package.
+type Price struct {
+	SearchID    string      `json:"search_id"`
+	At          time.Time   `json:"at"`
+	Account     string      `json:"account"`
+	Kind        string      `json:"kind"`
+	Destination string      `json:"destination"`
+	CheckIn     time.Time   `json:"check_in"`
+	CheckOut    time.Time   `json:"check_out,omitzero"`
+	Occupancy   string      `json:"occupancy,omitempty"`
+	Item        string      `json:"item"`             // hotel name, route or package title
+	Detail      string      `json:"detail,omitempty"` // room and board, or fare family
+	Price       money.Money `json:"price"`
+}
+
+// Store is a history directory. It is safe for concurrent use within a
+// process. Searches and prices live in separate files, each appended with a
+// single write, so concurrent processes do not interleave lines within a
+// file; another process may however see a search before its prices, and a
+// crash between the two writes leaves a search with no prices.
+type Store struct {
+	// Logf, when set, is told about the lines skipped by queries.
+	Logf func(format string, args ...any)
+
+	dir string
+	mu  sync.Mutex
+}
+
+// Open returns the store in dir, creating the directory if needed.
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create history directory: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Record appends s and its prices. The search ID and timestamps are filled
+// in when empty, and copied to every price.
+func (s *Store) Record(search Search, prices []Price) (Search, error) {
+	if search.ID == "" {
+		search.ID = newID()
+	}
+	if search.At.IsZero() {
+		search.At = time.Now().UTC()
+	}
+	search.Results = len(prices)
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for i := range prices {
+		p := &prices[i]
+		p.SearchID = search.ID
+		p.At = search.At
+		p.Account = search.Account
+		if p.Destination == "" {
+			p.Destination = search.Destination
+		}
+		if p.CheckIn.IsZero() {
+			p.CheckIn, p.CheckOut = search.CheckIn, search.CheckOut
+		}
+		if p.Occupancy == "" {
+			p.Occupancy = search.Occupancy
+		}
+		if err := enc.Encode(p); err != nil {
+			return search, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	line, err := json.Marshal(search)
+	if err != nil {
+		return search, err
+	}
+	if err := appendFile(filepath.Join(s.dir, searchesFile), append(line, '\n')); err != nil {
+		return search, fmt.Errorf("record search: %w", err)
+	}
+	if buf.Len() > 0 {
+		if err := appendFile(filepath.Join(s.dir, pricesFile), buf.Bytes()); err != nil {
+			return search, fmt.Errorf("record prices: %w", err)
+		}
+	}
+	return search, nil
+}
+
+func appendFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Query selects prices. Zero fields match everything; Item and Destination
+// match accent- and case-insensitive substrings.
+type Query struct {
+	Item        string
+	Destination string
+	Kind        string
+	Account     string
+	Since       time.Time // observed at or after
+	Until       time.Time // observed before
+	CheckIn     time.Time // stays starting on this day
+	CheckOut    time.Time // stays ending on this day
+	Occupancy   string    // exact occupancy, e.g. "2;2:5,8"
+	Limit       int       // most recent prices only; 0 means no limit
+}
+
+func (q Query) matches(p Price) bool {
+	switch {
+	case q.Kind != "" && p.Kind != q.Kind:
+		return false
+	case q.Account != "" && !strings.EqualFold(p.Account, q.Account):
+		return false
+	case !q.Since.IsZero() && p.At.Before(q.Since):
+		return false
+	case !q.Until.IsZero() && !p.At.Before(q.Until):
+		return false
+	case !q.CheckIn.IsZero() && !sameDay(p.CheckIn, q.CheckIn):
+		return false
+	case !q.CheckOut.IsZero() && !sameDay(p.CheckOut, q.CheckOut):
+		return false
+	case q.Occupancy != "" && p.Occupancy != q.Occupancy:
+		return false
+	case q.Item != "" && !strings.Contains(textnorm.Fold(p.Item), textnorm.Fold(q.Item)):
+		return false
+	case q.Destination != "" && !strings.Contains(textnorm.Fold(p.Destination), textnorm.Fold(q.Destination)):
+		return false
+	}
+	return true
+}
+
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// Prices returns the prices matching q, oldest first.
+func (s *Store) Prices(q Query) ([]Price, error) {
+	var prices []Price
+	err := s.scan(pricesFile, func(data []byte) error {
+		var p Price
+		if err := json.Unmarshal(data, &p); err != nil {
+			return err
+		}
+		if q.matches(p) {
+			prices = append(prices, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(prices, func(i, j int) bool { return prices[i].At.Before(prices[j].At) })
+	if q.Limit > 0 && len(prices) > q.Limit {
+		prices = prices[len(prices)-q.Limit:]
+	}
+	return prices, nil
+}
+
+// Searches returns the recorded searches observed within q's Since/Until and
+// matching its Destination, Account and CheckIn, oldest first. Limit keeps
+// only the most recent ones.
+func (s *Store) Searches(q Query) ([]Search, error) {
+	var searches []Search
+	err := s.scan(searchesFile, func(data []byte) error {
+		var r Search
+		if err := json.Unmarshal(data, &r); err != nil {
+			return err
+		}
+		p := Price{At: r.At, Account: r.Account, Destination: r.Destination, CheckIn: r.CheckIn}
+		if (Query{Destination: q.Destination, Account: q.Account, Since: q.Since, Until: q.Until, CheckIn: q.CheckIn}).matches(p) {
+			searches = append(searches, r)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(searches, func(i, j int) bool { return searches[i].At.Before(searches[j].At) })
+	if q.Limit > 0 && len(searches) > q.Limit {
+		searches = searches[len(searches)-q.Limit:]
+	}
+	return searches, nil
+}
+
+// scan calls fn for every line of name. A truncated last line, left by a
+// crash during an append, is skipped, and so is a line fn cannot decode, so
+// one damaged line does not hide the rest of the history.
+func (s *Store) scan(name string, fn func([]byte) error) error {
+	f, err := os.Open(filepath.Join(s.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for lineNo := 1; ; lineNo++ {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if err := fn(line); err != nil && s.Logf != nil {
+			s.Logf("%s line %d skipped: %v", name, lineNo, err)
+		}
+	}
+}
+
+// Trend summarizes the prices of one item (same kind, item, detail, stay,
+// occupancy and currency) over time.
+type Trend struct {
+	Kind         string      `json:"kind"`
+	Item         string      `json:"item"`
+	Detail       string      `json:"detail,omitempty"`
+	Destination  string      `json:"destination"`
+	CheckIn      time.Time   `json:"check_in"`
+	CheckOut     time.Time   `json:"check_out,omitzero"`
+	Occupancy    string      `json:"occupancy,omitempty"`
+	Observations int         `json:"observations"`
+	FirstSeen    time.Time   `json:"first_seen"`
+	LastSeen     time.Time   `json:"last_seen"`
+	First        money.Money `json:"first"`
+	Last         money.Money `json:"last"`
+	Min          money.Money `json:"min"`
+	Max          money.Money `json:"max"`
+	Change       money.Money `json:"change"`
+	ChangePct    float64     `json:"change_pct"`
+}
+
+// Direction describes Change as "up", "down" or "same".
+func (t Trend) Direction() string {
+	switch {
+	case t.Change.Amount > 0:
+		return "up"
+	case t.Change.Amount < 0:
+		return "down"
+	}
+	return "same"
+}
+
+// Trends groups prices (oldest first, as returned by Prices) per item and
+// compares the first and last observation of each.
+func Trends(prices []Price) []Trend {
+	index := make(map[string]int)
+	var trends []Trend
+	for _, p := range prices {
+		key := strings.Join([]string{
+			p.Kind, textnorm.Fold(p.Item), textnorm.Fold(p.Detail),
+			p.CheckIn.Format(time.DateOnly), p.CheckOut.Format(time.DateOnly), p.Occupancy, p.Price.Currency,
+		}, "|")
+
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(trends)
+			trends = append(trends, Trend{
+				Kind: p.Kind, Item: p.Item, Detail: p.Detail, Destination: p.Destination,
+				CheckIn: p.CheckIn, CheckOut: p.CheckOut, Occupancy: p.Occupancy,
+				FirstSeen: p.At, First: p.Price, Min: p.Price, Max: p.Price,
+			})
+			i = len(trends) - 1
+		}
+
+		t := &trends[i]
+		t.Observations++
+		t.LastSeen, t.Last = p.At, p.Price
+		if p.Price.Amount < t.Min.Amount {
+			t.Min = p.Price
+		}
+		if p.Price.Amount > t.Max.Amount {
+			t.Max = p.Price
+		}
+	}
+
+	for i := range trends {
+		t := &trends[i]
+		t.Change = money.Money{Amount: t.Last.Amount - t.First.Amount, Currency: t.Last.Currency}
+		if t.First.Amount != 0 {
+			t.ChangePct = float64(t.Change.Amount) * 100 / float64(t.First.Amount)
+		}
+	}
+	return trends
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,128 @@
+package history
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"ExpeditusClient/internal/money"
+)
+
+func TestStoreAndTrends(t *testing.T) {
+	store, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkIn := time.Date(2026, 12, 20, 0, 0, 0, 0, time.UTC)
+	lastWeek := time.Date(2026, 10, 11, 12, 0, 0, 0, time.UTC)
+	today := lastWeek.AddDate(0, 0, 7)
+
+	searches := []struct {
+		at     time.Time
+		prices []Price
+	}{
+		{lastWeek, []Price{
+			{Kind: KindHotel, Item: "Meliá Punta Cana", Price: money.New(1200, 0, money.USD)},
+			{Kind: KindHotel, Item: "Hotel Riu", Price: money.New(900, 0, money.USD)},
+		}},
+		{today, []Price{
+			{Kind: KindHotel, Item: "Melia Punta Cana", Price: money.New(1350, 0, money.USD)},
+			{Kind: KindHotel, Item: "Hotel Riu", Price: money.New(850, 50, money.USD)},
+		}},
+	}
+	for _, s := range searches {
+		rec, err := store.Record(Search{At: s.at, Account: "agent", TripType: "ONLY_HOTEL", Destination: "Destination::PUJ", CheckIn: checkIn, Occupancy: "2"}, s.prices)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.ID == "" || rec.Results != 2 {
+			t.Errorf("recorded search = %+v", rec)
+		}
+	}
+
+	all, err := store.Prices(Query{Destination: "puj"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 4 || !all[0].CheckIn.Equal(checkIn) || all[0].Account != "agent" || all[0].Occupancy != "2" {
+		t.Fatalf("prices = %+v", all)
+	}
+
+	recent, _ := store.Prices(Query{Since: today})
+	if len(recent) != 2 {
+		t.Errorf("prices since today = %d", len(recent))
+	}
+	melia, _ := store.Prices(Query{Item: "MELIA"})
+	if len(melia) != 2 {
+		t.Errorf("prices for melia = %d", len(melia))
+	}
+	if other, _ := store.Prices(Query{Occupancy: "2;2"}); len(other) != 0 {
+		t.Errorf("prices for another occupancy = %d", len(other))
+	}
+	if got, _ := store.Searches(Query{}); len(got) != 2 {
+		t.Errorf("searches = %d", len(got))
+	}
+	if got, _ := store.Searches(Query{Limit: 1}); len(got) != 1 || !got[0].At.Equal(today) {
+		t.Errorf("latest search = %+v", got)
+	}
+
+	trends := Trends(all)
+	if len(trends) != 2 {
+		t.Fatalf("trends = %+v", trends)
+	}
+	want := []struct {
+		item      string
+		direction string
+		change    int64
+	}{
+		{"Meliá Punta Cana", "up", 15000},
+		{"Hotel Riu", "down", -4950},
+	}
+	for i, w := range want {
+		tr := trends[i]
+		if tr.Item != w.item || tr.Direction() != w.direction || tr.Change.Amount != w.change || tr.Observations != 2 {
+			t.Errorf("trend %d = %+v", i, tr)
+		}
+	}
+}
+
+func TestSkipDamagedLines(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var skipped []string
+	store.Logf = func(format string, args ...any) { skipped = append(skipped, fmt.Sprintf(format, args...)) }
+
+	checkIn := time.Date(2026, 12, 20, 0, 0, 0, 0, time.UTC)
+	record := func() {
+		_, err := store.Record(Search{Destination: "Destination::PUJ", CheckIn: checkIn},
+			[]Price{{Kind: KindHotel, Item: "Hotel Riu", Price: money.New(900, 0, money.USD)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	record()
+	for _, name := range []string{pricesFile, searchesFile} {
+		if err := appendFile(filepath.Join(dir, name), []byte("{\"at\": \"yesterday\", \"price\": \"US$ ??\"}\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	record()
+
+	prices, err := store.Prices(Query{})
+	if err != nil || len(prices) != 2 {
+		t.Errorf("prices = %d, %v; want the 2 readable ones", len(prices), err)
+	}
+	searches, err := store.Searches(Query{})
+	if err != nil || len(searches) != 2 {
+		t.Errorf("searches = %d, %v; want the 2 readable ones", len(searches), err)
+	}
+	if len(skipped) != 2 || !strings.Contains(skipped[0], "line 2") {
+		t.Errorf("skipped = %q", skipped)
+	}
+}
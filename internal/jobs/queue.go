@@ -0,0 +1,449 @@
+// Package jobs is a persistent priority queue of jobs processed by a fixed
+// number of workers. Each job is stored as a JSON file, so queued jobs and
+// results survive restarts.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Priorities. Higher priorities run first; equal priorities run in
+// submission order.
+const (
+	PriorityBackground  = 0
+	PriorityInteractive = 10
+)
+
+// Status is the lifecycle state of a job.
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusCanceled  Status = "canceled"
+)
+
+// Done reports whether the job has finished, successfully or not.
+func (s Status) Done() bool {
+	return s == StatusSucceeded || s == StatusFailed || s == StatusCanceled
+}
+
+var (
+	ErrNotFound = errors.New("job not found")
+	ErrFinished = errors.New("job already finished")
+)
+
+// Job is a unit of work and, once finished, its result or error.
+type Job struct {
+	ID          string          `json:"id"`
+	Kind        string          `json:"kind"`
+	Priority    int             `json:"priority"`
+	Params      json.RawMessage `json:"params,omitempty"`
+	Status      Status          `json:"status"`
+	SubmittedAt time.Time       `json:"submitted_at"`
+	StartedAt   time.Time       `json:"started_at,omitzero"`
+	FinishedAt  time.Time       `json:"finished_at,omitzero"`
+	Worker      int             `json:"worker,omitempty"` // 1-based
+	Result      json.RawMessage `json:"result,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	ErrorCode   string          `json:"error_code,omitempty"`
+}
+
+// Handler runs a job on worker (1-based) and returns its JSON-encodable
+// result. An error with a Code() string method sets the job's ErrorCode.
+type Handler func(ctx context.Context, worker int, job Job) (any, error)
+
+// pruneInterval is how often Run deletes the finished jobs past retention.
+const pruneInterval = time.Hour
+
+// Queue holds the jobs of a directory.
+type Queue struct {
+	dir       string
+	retention time.Duration
+
+	mu       sync.Mutex
+	jobs     map[string]*Job
+	cancels  map[string]context.CancelFunc
+	canceled map[string]bool // running jobs whose cancellation was requested
+	done     map[string]chan struct{}
+	wake     chan struct{}
+}
+
+// Open loads the jobs in dir, creating it if needed. Jobs that were running
+// when the process stopped are queued again, and finished jobs older than
+// retention (0 keeps them forever) are deleted, now and periodically while
+// the queue runs.
+func Open(dir string, retention time.Duration) (*Queue, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create job directory: %w", err)
+	}
+	q := &Queue{
+		dir:       dir,
+		retention: retention,
+		jobs:      make(map[string]*Job),
+		cancels:   make(map[string]context.CancelFunc),
+		canceled:  make(map[string]bool),
+		done:      make(map[string]chan struct{}),
+		wake:      make(chan struct{}, 1),
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read job directory: %w", err)
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read job: %w", err)
+		}
+		var job Job
+		if err := json.Unmarshal(data, &job); err != nil || job.ID == "" {
+			// a crash during the first write leaves an unusable file
+			os.Remove(path)
+			continue
+		}
+		if q.expired(&job) {
+			os.Remove(path)
+			continue
+		}
+		if job.Status == StatusRunning {
+			job.Status, job.StartedAt, job.Worker = StatusQueued, time.Time{}, 0
+			if err := q.save(&job); err != nil {
+				return nil, err
+			}
+		}
+		q.jobs[job.ID] = &job
+	}
+	return q, nil
+}
+
+// NewID returns a fresh job ID, for callers that need it before Submit.
+func NewID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Submit queues a job built from spec's ID (generated when empty), Kind,
+// Priority and Params.
+func (q *Queue) Submit(spec Job) (Job, error) {
+	job := Job{
+		ID:          spec.ID,
+		Kind:        spec.Kind,
+		Priority:    spec.Priority,
+		Params:      spec.Params,
+		Status:      StatusQueued,
+		SubmittedAt: time.Now().UTC(),
+	}
+	if job.ID == "" {
+		job.ID = NewID()
+	}
+	if job.Kind == "" {
+		return job, errors.New("job kind is required")
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.jobs[job.ID]; ok {
+		return job, fmt.Errorf("duplicate job id %q", job.ID)
+	}
+	if err := q.save(&job); err != nil {
+		return job, err
+	}
+	q.jobs[job.ID] = &job
+	q.signal()
+	return job, nil
+}
+
+// Get returns the job with the given ID.
+func (q *Queue) Get(id string) (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// List returns the jobs with the given status (all when empty), newest first.
+func (q *Queue) List(status Status) []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var list []Job
+	for _, job := range q.jobs {
+		if status == "" || job.Status == status {
+			list = append(list, *job)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].SubmittedAt.After(list[j].SubmittedAt) })
+	return list
+}
+
+// Cancel stops a queued or running job. Running jobs are marked canceled
+// once their handler returns.
+func (q *Queue) Cancel(id string) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[id]
+	switch {
+	case !ok:
+		return Job{}, ErrNotFound
+	case job.Status.Done():
+		return *job, ErrFinished
+	case job.Status == StatusRunning:
+		q.canceled[id] = true
+		q.cancels[id]()
+		return *job, nil
+	}
+
+	job.Status, job.FinishedAt = StatusCanceled, time.Now().UTC()
+	if err := q.save(job); err != nil {
+		return *job, err
+	}
+	q.closeDone(id)
+	return *job, nil
+}
+
+// Wait blocks until the job finishes or ctx is done.
+func (q *Queue) Wait(ctx context.Context, id string) (Job, error) {
+	q.mu.Lock()
+	job, ok := q.jobs[id]
+	if !ok {
+		q.mu.Unlock()
+		return Job{}, ErrNotFound
+	}
+	if job.Status.Done() {
+		defer q.mu.Unlock()
+		return *job, nil
+	}
+	ch := q.doneChan(id)
+	q.mu.Unlock()
+
+	select {
+	case <-ch:
+		job, _ := q.Get(id)
+		return job, nil
+	case <-ctx.Done():
+		job, _ := q.Get(id)
+		return job, ctx.Err()
+	}
+}
+
+// Prune deletes the finished jobs older than the retention and returns how
+// many were removed.
+func (q *Queue) Prune() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	removed := 0
+	for id, job := range q.jobs {
+		if !q.expired(job) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(q.dir, id+".json")); err != nil && !errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		delete(q.jobs, id)
+		removed++
+	}
+	return removed
+}
+
+func (q *Queue) expired(job *Job) bool {
+	return job.Status.Done() && q.retention > 0 && time.Since(job.FinishedAt) > q.retention
+}
+
+// Run processes jobs with the given number of workers until ctx is done,
+// pruning expired jobs every pruneInterval. Jobs interrupted by the shutdown
+// are queued again.
+func (q *Queue) Run(ctx context.Context, workers int, handler Handler) {
+	var wg sync.WaitGroup
+	for w := 1; w <= workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx, w, handler)
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(pruneInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				q.Prune()
+			}
+		}
+	}()
+	wg.Wait()
+}
+
+func (q *Queue) work(ctx context.Context, worker int, handler Handler) {
+	for {
+		job, jobCtx, ok := q.claim(ctx, worker)
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return
+			case <-q.wake:
+				continue
+			}
+		}
+		result, err := runHandler(jobCtx, handler, worker, job)
+		q.finish(ctx, job.ID, result, err)
+	}
+}
+
+// claim marks the next job as running: the highest priority, then the
+// oldest submission.
+func (q *Queue) claim(ctx context.Context, worker int) (Job, context.Context, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if ctx.Err() != nil {
+		return Job{}, nil, false
+	}
+
+	var next *Job
+	queued := 0
+	for _, job := range q.jobs {
+		if job.Status != StatusQueued {
+			continue
+		}
+		queued++
+		if next == nil || job.Priority > next.Priority ||
+			(job.Priority == next.Priority && job.SubmittedAt.Before(next.SubmittedAt)) {
+			next = job
+		}
+	}
+	if next == nil {
+		return Job{}, nil, false
+	}
+	if queued > 1 {
+		q.signal() // let another idle worker pick up the rest
+	}
+
+	next.Status, next.StartedAt, next.Worker = StatusRunning, time.Now().UTC(), worker
+	if err := q.save(next); err != nil {
+		next.Status = StatusQueued
+		return Job{}, nil, false
+	}
+	jobCtx, cancel := context.WithCancel(ctx)
+	q.cancels[next.ID] = cancel
+	return *next, jobCtx, true
+}
+
+func (q *Queue) finish(ctx context.Context, id string, result any, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job := q.jobs[id]
+	q.cancels[id]()
+	delete(q.cancels, id)
+	canceled := q.canceled[id]
+	delete(q.canceled, id)
+
+	if ctx.Err() != nil && !canceled {
+		// shutting down: run it again on the next start
+		job.Status, job.StartedAt, job.Worker = StatusQueued, time.Time{}, 0
+		q.save(job)
+		return
+	}
+
+	job.FinishedAt = time.Now().UTC()
+	switch {
+	case canceled:
+		job.Status = StatusCanceled
+		if err != nil {
+			job.Error = err.Error()
+		}
+	case err != nil:
+		job.Status, job.Error = StatusFailed, err.Error()
+		var coded interface{ Code() string }
+		if errors.As(err, &coded) {
+			job.ErrorCode = coded.Code()
+		}
+	default:
+		job.Status = StatusSucceeded
+		if result != nil {
+			data, marshalErr := json.Marshal(result)
+			if marshalErr != nil {
+				job.Status, job.Error = StatusFailed, fmt.Sprintf("encode result: %v", marshalErr)
+			} else {
+				job.Result = data
+			}
+		}
+	}
+	q.save(job)
+	q.closeDone(id)
+}
+
+// runHandler converts a panic into an error so one bad job does not stop
+// its worker.
+func runHandler(ctx context.Context, handler Handler, worker int, job Job) (result any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(ctx, worker, job)
+}
+
+func (q *Queue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// doneChan and closeDone must be called with q.mu held.
+func (q *Queue) doneChan(id string) chan struct{} {
+	ch, ok := q.done[id]
+	if !ok {
+		ch = make(chan struct{})
+		q.done[id] = ch
+	}
+	return ch
+}
+
+func (q *Queue) closeDone(id string) {
+	if ch, ok := q.done[id]; ok {
+		close(ch)
+		delete(q.done, id)
+	}
+}
+
+func (q *Queue) save(job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(q.dir, job.ID+".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("save job %s: %w", job.ID, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("save job %s: %w", job.ID, err)
+	}
+	return nil
+}
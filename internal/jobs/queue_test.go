@@ -0,0 +1,119 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQueue(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	for _, spec := range []Job{
+		{Kind: "refresh", Priority: PriorityBackground},
+		{Kind: "search", Priority: PriorityInteractive},
+		{Kind: "refresh", Priority: PriorityBackground},
+		{Kind: "fail", Priority: PriorityBackground},
+	} {
+		job, err := q.Submit(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, job.ID)
+	}
+	if _, err := q.Cancel(ids[2]); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var mu sync.Mutex
+	var order []string
+	go q.Run(ctx, 1, func(ctx context.Context, worker int, job Job) (any, error) {
+		mu.Lock()
+		order = append(order, job.ID)
+		mu.Unlock()
+		if job.Kind == "fail" {
+			return nil, errors.New("boom")
+		}
+		return map[string]string{"kind": job.Kind}, nil
+	})
+	last, err := q.Wait(ctx, ids[3])
+	cancel()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{ids[1], ids[0], ids[3]}; !slices.Equal(order, want) {
+		t.Errorf("run order = %v, want %v (interactive first, canceled skipped)", order, want)
+	}
+	if last.Status != StatusFailed || last.Error != "boom" {
+		t.Errorf("failed job = %s %q", last.Status, last.Error)
+	}
+
+	reopened, err := Open(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Status{ids[0]: StatusSucceeded, ids[1]: StatusSucceeded, ids[2]: StatusCanceled, ids[3]: StatusFailed}
+	for id, status := range want {
+		job, ok := reopened.Get(id)
+		if !ok || job.Status != status {
+			t.Errorf("reopened job %s = %v %s, want %s", id, ok, job.Status, status)
+		}
+	}
+	if job, _ := reopened.Get(ids[1]); string(job.Result) != `{"kind":"search"}` {
+		t.Errorf("result = %s", job.Result)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for range 3 {
+		job, err := q.Submit(Job{Kind: "refresh"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, job.ID)
+	}
+	for _, id := range ids[:2] {
+		if _, err := q.Cancel(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the first job finished before the retention window, the second inside it
+	q.mu.Lock()
+	q.jobs[ids[0]].FinishedAt = time.Now().Add(-2 * time.Hour)
+	q.mu.Unlock()
+
+	if n := q.Prune(); n != 1 {
+		t.Errorf("pruned %d jobs, want 1", n)
+	}
+	if _, ok := q.Get(ids[0]); ok {
+		t.Error("expired job still listed")
+	}
+	if _, err := os.Stat(filepath.Join(dir, ids[0]+".json")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expired job file: %v", err)
+	}
+	for _, id := range ids[1:] {
+		if _, ok := q.Get(id); !ok {
+			t.Errorf("job %s pruned too early", id)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package jsf
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const defaultUserAgent = "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0 Safari/537.36"
+
+// Client keeps the cookie jar and the current view state of one JSF session.
+type Client struct {
+	HTTP      *http.Client
+	BaseURL   *url.URL
+	UserAgent string
+
+	viewState string
+}
+
+// NewClient returns a client with its own cookie jar rooted at baseURL.
+func NewClient(baseURL string) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse base url: %w", err)
+	}
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, fmt.Errorf("cookie jar: %w", err)
+	}
+
+	return &Client{
+		HTTP:      &http.Client{Jar: jar, Timeout: 30 * time.Second},
+		BaseURL:   u,
+		UserAgent: defaultUserAgent,
+	}, nil
+}
+
+// ViewState returns the last view state seen in a page or partial response.
+func (c *Client) ViewState() string {
+	return c.viewState
+}
+
+// Cookie returns the value of the named cookie for the base URL.
+func (c *Client) Cookie(name string) string {
+	if c.HTTP.Jar == nil {
+		return ""
+	}
+	for _, ck := range c.HTTP.Jar.Cookies(c.BaseURL) {
+		if ck.Name == name {
+			return ck.Value
+		}
+	}
+	return ""
+}
+
+// Get fetches and parses a full page. ref is resolved against the base URL.
+func (c *Client) Get(ctx context.Context, ref string) (*Page, error) {
+	target, err := c.resolve(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.setHeaders(req)
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("get %s: %w", target, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", target, err)
+	}
+	if resp.StatusCode >= 400 {
+		return nil, &StatusError{Code: resp.StatusCode, URL: target}
+	}
+
+	page := ParsePage(resp.Request.URL.String(), string(body))
+	if page.ViewState != "" {
+		c.viewState = page.ViewState
+	}
+	return page, nil
+}
+
+// PartialRequest describes a PrimeFaces AJAX submit.
+type PartialRequest struct {
+	Form    *Form      // form whose fields are submitted
+	Source  string     // client id of the component firing the request
+	Execute []string   // components processed on the server, defaults to Source's form
+	Render  []string   // components re-rendered in the response, defaults to @all
+	Event   string     // behavior event name, if any (e.g. "query", "itemSelect")
+	Params  url.Values // field overrides and extra parameters
+}
+
+// Submit posts req to the form action as a partial/ajax request.
+func (c *Client) Submit(ctx context.Context, req PartialRequest) (*PartialResponse, error) {
+	if req.Form == nil {
+		return nil, fmt.Errorf("partial request for %s: no form", req.Source)
+	}
+
+	target, err := c.resolve(req.Form.Action)
+	if err != nil {
+		return nil, err
+	}
+
+	values := buildPartialValues(req, c.viewState)
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, target, strings.NewReader(values.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	c.setHeaders(httpReq)
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=UTF-8")
+	httpReq.Header.Set("Faces-Request", "partial/ajax")
+	httpReq.Header.Set("X-Requested-With", "XMLHttpRequest")
+
+	resp, err := c.HTTP.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("post %s: %w", target, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", target, err)
+	}
+	if resp.StatusCode >= 400 {
+		return nil, &StatusError{Code: resp.StatusCode, URL: target}
+	}
+
+	pr, err := ParsePartialResponse(body)
+	if err != nil {
+		return nil, err
+	}
+	if pr.ViewState != "" {
+		c.viewState = pr.ViewState
+	}
+	return pr, nil
+}
+
+func buildPartialValues(req PartialRequest, viewState string) url.Values {
+	values := req.Form.Values()
+
+	execute := req.Execute
+	if len(execute) == 0 {
+		execute = []string{req.Source}
+	}
+	render := req.Render
+	if len(render) == 0 {
+		render = []string{"@all"}
+	}
+
+	values.Set("javax.faces.partial.ajax", "true")
+	values.Set("javax.faces.source", req.Source)
+	values.Set("javax.faces.partial.execute", strings.Join(execute, " "))
+	values.Set("javax.faces.partial.render", strings.Join(render, " "))
+	if req.Event != "" {
+		values.Set("javax.faces.behavior.event", req.Event)
+		values.Set("javax.faces.partial.event", req.Event)
+	} else {
+		values.Set(req.Source, req.Source)
+	}
+	if viewState != "" {
+		values.Set(viewStateParam, viewState)
+	}
+
+	for k, vs := range req.Params {
+		values[k] = vs
+	}
+	return values
+}
+
+func (c *Client) resolve(ref string) (string, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return "", fmt.Errorf("parse url %q: %w", ref, err)
+	}
+	return c.BaseURL.ResolveReference(u).String(), nil
+}
+
+func (c *Client) setHeaders(req *http.Request) {
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	req.Header.Set("Accept-Language", "es-AR,es;q=0.9,en;q=0.8")
+}
+
+// StatusError is returned for HTTP responses with status >= 400.
+type StatusError struct {
+	Code int
+	URL  string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: http %d", e.URL, e.Code)
+}
@@ -0,0 +1,120 @@
+package jsf
+
+import (
+	"strings"
+	"testing"
+)
+
+const loginPage = `<html><head><title>Delfos &amp; Co</title></head><body>
+<form id="j_id_4s_3_1:login-content:login" name="j_id_4s_3_1:login-content:login" method="post" action="/home.xhtml">
+	<input type="hidden" name="j_id_4s_3_1:login-content:login" value="j_id_4s_3_1:login-content:login" />
+	<input id="j_id_4s_3_1:login-content:login:Email" name="j_id_4s_3_1:login-content:login:Email" type="text" placeholder="..." />
+	<input id="j_id_4s_3_1:login-content:login:j_password" name="j_id_4s_3_1:login-content:login:j_password" type="password" />
+	<button id="j_id_4s_3_1:login-content:login:signin" name="j_id_4s_3_1:login-content:login:signin" type="submit">Iniciar sesión</button>
+	<input type="hidden" name="javax.faces.ViewState" id="j_id1:javax.faces.ViewState:0" value="-123:456&amp;x" autocomplete="off" />
+</form>
+<a id="j_id_79:init-compositor-all:j_id_20v:startTrip" href="#">Buscar</a>
+</body></html>`
+
+func TestParsePage(t *testing.T) {
+	p := ParsePage("https://www.delfos.tur.ar/", loginPage)
+
+	if p.Title != "Delfos & Co" {
+		t.Errorf("title = %q", p.Title)
+	}
+	if p.ViewState != "-123:456&x" {
+		t.Errorf("view state = %q", p.ViewState)
+	}
+
+	form := p.FormWith(":login:Email")
+	if form == nil {
+		t.Fatal("login form not found")
+	}
+	if form.Action != "/home.xhtml" {
+		t.Errorf("action = %q", form.Action)
+	}
+	if in := form.Find(":login:j_password"); in == nil || in.Type != "password" {
+		t.Errorf("password input = %+v", in)
+	}
+
+	values := form.Values()
+	if _, ok := values["j_id_4s_3_1:login-content:login:signin"]; ok {
+		t.Error("button must not be submitted as a field")
+	}
+	if values.Get("javax.faces.ViewState") != "-123:456&x" {
+		t.Errorf("view state field = %q", values.Get("javax.faces.ViewState"))
+	}
+
+	if id := p.FindID(":startTrip"); id != "j_id_79:init-compositor-all:j_id_20v:startTrip" {
+		t.Errorf("startTrip id = %q", id)
+	}
+}
+
+func TestBuildPartialValues(t *testing.T) {
+	form := ParsePage("", loginPage).FormWith(":login:Email")
+	values := buildPartialValues(PartialRequest{
+		Form:   form,
+		Source: "src",
+		Params: map[string][]string{"j_id_4s_3_1:login-content:login:Email": {"o'neil\\"}},
+	}, "vs-2")
+
+	checks := map[string]string{
+		"javax.faces.partial.ajax":              "true",
+		"javax.faces.source":                    "src",
+		"javax.faces.partial.execute":           "src",
+		"javax.faces.partial.render":            "@all",
+		"src":                                   "src",
+		"javax.faces.ViewState":                 "vs-2",
+		"j_id_4s_3_1:login-content:login:Email": "o'neil\\",
+	}
+	for k, want := range checks {
+		if got := values.Get(k); got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+}
+
+func TestParsePartialResponse(t *testing.T) {
+	body := `<?xml version='1.0' encoding='UTF-8'?>
+<partial-response id="j_id1"><changes>
+<update id="j_id_4s_3_1:login-content:login:messages"><![CDATA[<div class="ui-messages-error"><span class="ui-messages-error-summary">Usuario incorrecto</span></div>]]></update>
+<update id="j_id1:javax.faces.ViewState:0"><![CDATA[-999:888]]></update>
+<extension ln="primefaces" type="args">{"validationFailed":true}</extension>
+</changes></partial-response>`
+
+	pr, err := ParsePartialResponse([]byte(body))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if pr.ViewState != "-999:888" {
+		t.Errorf("view state = %q", pr.ViewState)
+	}
+	if !pr.ValidationFailed() {
+		t.Error("validationFailed not detected")
+	}
+	markup, ok := pr.Update(":messages")
+	if !ok || !strings.Contains(markup, "Usuario incorrecto") {
+		t.Errorf("messages update = %q", markup)
+	}
+	if _, ok := pr.Updates["j_id1:javax.faces.ViewState:0"]; ok {
+		t.Error("view state must not be listed as an update")
+	}
+}
+
+func TestParsePartialResponseRedirectAndError(t *testing.T) {
+	pr, err := ParsePartialResponse([]byte(`<partial-response><redirect url="/results.xhtml?id=1"></redirect></partial-response>`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if pr.Redirect != "/results.xhtml?id=1" {
+		t.Errorf("redirect = %q", pr.Redirect)
+	}
+
+	pr, err = ParsePartialResponse([]byte(`<partial-response><error><error-name>javax.faces.application.ViewExpiredException</error-name><error-message>expired</error-message></error></partial-response>`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if pr.Err() == nil || !strings.Contains(pr.Err().Error(), "ViewExpiredException") {
+		t.Errorf("err = %v", pr.Err())
+	}
+}
@@ -0,0 +1,154 @@
+// Package jsf talks to JSF/PrimeFaces applications over plain HTTP: it scrapes
+// forms and the javax.faces.ViewState token from rendered pages and submits
+// partial (AJAX) requests the same way the PrimeFaces client does.
+package jsf
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	viewStateParam = "javax.faces.ViewState"
+)
+
+var (
+	formRe  = regexp.MustCompile(`(?is)<form\b([^>]*)>(.*?)</form>`)
+	inputRe = regexp.MustCompile(`(?is)<(input|button|select|textarea)\b([^>]*)>`)
+	attrRe  = regexp.MustCompile(`(?s)([\w:.-]+)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	titleRe = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	idRe    = regexp.MustCompile(`\bid\s*=\s*"([^"]+)"`)
+)
+
+// Page is a rendered JSF view.
+type Page struct {
+	URL       string
+	Body      string
+	Title     string
+	ViewState string
+	Forms     []Form
+}
+
+// Form is an HTML form with the fields it would submit.
+type Form struct {
+	ID     string
+	Action string
+	Inputs []Input
+}
+
+// Input is a single form control.
+type Input struct {
+	Tag   string
+	ID    string
+	Name  string
+	Type  string
+	Value string
+}
+
+// ParsePage extracts forms, title and view state from an HTML document.
+func ParsePage(pageURL, body string) *Page {
+	p := &Page{URL: pageURL, Body: body}
+
+	if m := titleRe.FindStringSubmatch(body); m != nil {
+		p.Title = strings.TrimSpace(html.UnescapeString(m[1]))
+	}
+
+	for _, m := range formRe.FindAllStringSubmatch(body, -1) {
+		attrs := parseAttrs(m[1])
+		form := Form{ID: attrs["id"], Action: attrs["action"]}
+		for _, im := range inputRe.FindAllStringSubmatch(m[2], -1) {
+			ia := parseAttrs(im[2])
+			form.Inputs = append(form.Inputs, Input{
+				Tag:   strings.ToLower(im[1]),
+				ID:    ia["id"],
+				Name:  ia["name"],
+				Type:  strings.ToLower(ia["type"]),
+				Value: ia["value"],
+			})
+		}
+		if vs := form.Value(viewStateParam); vs != "" && p.ViewState == "" {
+			p.ViewState = vs
+		}
+		p.Forms = append(p.Forms, form)
+	}
+
+	return p
+}
+
+func parseAttrs(s string) map[string]string {
+	attrs := make(map[string]string)
+	for _, m := range attrRe.FindAllStringSubmatch(s, -1) {
+		v := m[2]
+		if v == "" {
+			v = m[3]
+		}
+		attrs[strings.ToLower(m[1])] = html.UnescapeString(v)
+	}
+	return attrs
+}
+
+// FormWith returns the first form containing a control whose id or name ends with suffix.
+func (p *Page) FormWith(suffix string) *Form {
+	for i := range p.Forms {
+		if p.Forms[i].Find(suffix) != nil {
+			return &p.Forms[i]
+		}
+	}
+	return nil
+}
+
+// FindID returns the first element id in the page ending with suffix. It covers
+// components such as command links that are not form controls.
+func (p *Page) FindID(suffix string) string {
+	for _, m := range idRe.FindAllStringSubmatch(p.Body, -1) {
+		if strings.HasSuffix(m[1], suffix) {
+			return html.UnescapeString(m[1])
+		}
+	}
+	return ""
+}
+
+// Find returns the control whose id or name ends with suffix.
+// Generated JSF ids (j_id_4s_3_1:...) change between deployments, so callers
+// match on the stable tail of the client id.
+func (f *Form) Find(suffix string) *Input {
+	for i := range f.Inputs {
+		in := &f.Inputs[i]
+		if strings.HasSuffix(in.ID, suffix) || strings.HasSuffix(in.Name, suffix) {
+			return in
+		}
+	}
+	return nil
+}
+
+// Value returns the value of the control named name.
+func (f *Form) Value(name string) string {
+	for _, in := range f.Inputs {
+		if in.Name == name {
+			return in.Value
+		}
+	}
+	return ""
+}
+
+// Values returns the fields the browser would submit for this form.
+// Buttons and unchecked boxes are left out.
+func (f *Form) Values() url.Values {
+	v := url.Values{}
+	if f.ID != "" {
+		v.Set(f.ID, f.ID)
+	}
+	for _, in := range f.Inputs {
+		if in.Name == "" || in.Tag == "button" {
+			continue
+		}
+		switch in.Type {
+		case "submit", "button", "image", "reset", "file", "checkbox", "radio":
+			continue
+		}
+		v.Set(in.Name, in.Value)
+	}
+	return v
+}
@@ -0,0 +1,115 @@
+package jsf
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// PartialResponse is a parsed <partial-response> document.
+type PartialResponse struct {
+	Updates   map[string]string // component client id -> replacement markup
+	Redirect  string
+	ViewState string
+	Args      map[string]any // PrimeFaces callback params (extension ln="primefaces" type="args")
+	Errors    []PartialError
+}
+
+// PartialError is a server-side error reported inside a partial response.
+type PartialError struct {
+	Name    string
+	Message string
+}
+
+func (e PartialError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Name, e.Message)
+}
+
+type xmlPartialResponse struct {
+	Changes struct {
+		Updates []struct {
+			ID      string `xml:"id,attr"`
+			Content string `xml:",chardata"`
+		} `xml:"update"`
+		Extensions []struct {
+			Ln      string `xml:"ln,attr"`
+			Type    string `xml:"type,attr"`
+			Content string `xml:",chardata"`
+		} `xml:"extension"`
+	} `xml:"changes"`
+	Redirect *struct {
+		URL string `xml:"url,attr"`
+	} `xml:"redirect"`
+	Errors []struct {
+		Name    string `xml:"error-name"`
+		Message string `xml:"error-message"`
+	} `xml:"error"`
+}
+
+// ParsePartialResponse decodes the XML returned for a Faces-Request: partial/ajax POST.
+func ParsePartialResponse(body []byte) (*PartialResponse, error) {
+	var raw xmlPartialResponse
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	dec.Strict = false
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("parse partial response: %w", err)
+	}
+
+	pr := &PartialResponse{
+		Updates: make(map[string]string, len(raw.Changes.Updates)),
+	}
+
+	for _, u := range raw.Changes.Updates {
+		if strings.Contains(u.ID, viewStateParam) {
+			pr.ViewState = strings.TrimSpace(u.Content)
+			continue
+		}
+		pr.Updates[u.ID] = u.Content
+	}
+
+	for _, ext := range raw.Changes.Extensions {
+		if ext.Ln != "primefaces" || ext.Type != "args" {
+			continue
+		}
+		args := map[string]any{}
+		if err := json.Unmarshal([]byte(ext.Content), &args); err == nil {
+			pr.Args = args
+		}
+	}
+
+	if raw.Redirect != nil {
+		pr.Redirect = raw.Redirect.URL
+	}
+
+	for _, e := range raw.Errors {
+		pr.Errors = append(pr.Errors, PartialError{Name: e.Name, Message: e.Message})
+	}
+
+	return pr, nil
+}
+
+// Update returns the markup of the first update whose id ends with suffix.
+func (pr *PartialResponse) Update(suffix string) (string, bool) {
+	for id, content := range pr.Updates {
+		if strings.HasSuffix(id, suffix) {
+			return content, true
+		}
+	}
+	return "", false
+}
+
+// ValidationFailed reports the PrimeFaces validationFailed callback param.
+func (pr *PartialResponse) ValidationFailed() bool {
+	v, _ := pr.Args["validationFailed"].(bool)
+	return v
+}
+
+// Err returns the first server error, if any.
+func (pr *PartialResponse) Err() error {
+	if len(pr.Errors) > 0 {
+		return pr.Errors[0]
+	}
+	return nil
+}
@@ -0,0 +1,328 @@
+// Package money represents prices as integer minor units with an ISO 4217
+// currency and parses the price formats shown by the site.
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ISO codes of the currencies the site quotes.
+const (
+	USD = "USD"
+	ARS = "ARS"
+	EUR = "EUR"
+	BRL = "BRL"
+)
+
+// minorDigits is the number of decimal places of every supported currency.
+const minorDigits = 2
+
+var (
+	ErrNoAmount         = errors.New("no amount found")
+	ErrNoCurrency       = errors.New("no currency found")
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+)
+
+// Money is an amount in minor units (cents) of Currency.
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// New returns major units plus cents of currency, e.g. New(2990, 50, "USD").
+func New(major, cents int64, currency string) Money {
+	if major < 0 {
+		cents = -cents
+	}
+	return Money{Amount: major*100 + cents, Currency: currency}
+}
+
+// IsZero reports whether m is the zero value, so that ",omitzero" drops
+// missing prices from JSON.
+func (m Money) IsZero() bool {
+	return m.Amount == 0 && m.Currency == ""
+}
+
+// Compare returns -1, 0 or +1 as m is less than, equal to or greater than o.
+func (m Money) Compare(o Money) (int, error) {
+	if m.Currency != o.Currency {
+		return 0, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	switch {
+	case m.Amount < o.Amount:
+		return -1, nil
+	case m.Amount > o.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Add returns m+o.
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	return Money{Amount: m.Amount + o.Amount, Currency: m.Currency}, nil
+}
+
+// Times returns m multiplied by n, e.g. a per-person price times travellers.
+func (m Money) Times(n int) Money {
+	return Money{Amount: m.Amount * int64(n), Currency: m.Currency}
+}
+
+// Float returns the amount in major units. Use it for display and statistics
+// only; comparisons should use Amount.
+func (m Money) Float() float64 {
+	return float64(m.Amount) / 100
+}
+
+// Decimal returns the amount as a plain decimal, e.g. "2990.50".
+func (m Money) Decimal() string {
+	sign, abs := "", m.Amount
+	if abs < 0 {
+		sign, abs = "-", -abs
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, abs/100, abs%100)
+}
+
+// String returns the currency code and decimal amount, e.g. "USD 2990.50".
+func (m Money) String() string {
+	if m.IsZero() {
+		return ""
+	}
+	return m.Currency + " " + m.Decimal()
+}
+
+var displaySymbols = map[string]string{USD: "US$", ARS: "$", EUR: "€", BRL: "R$"}
+
+// Display formats m as the site does in es-AR, e.g. "US$ 2.990,50".
+func (m Money) Display() string {
+	if m.IsZero() {
+		return ""
+	}
+	symbol, ok := displaySymbols[m.Currency]
+	if !ok {
+		symbol = m.Currency
+	}
+
+	sign, abs := "", m.Amount
+	if abs < 0 {
+		sign, abs = "-", -abs
+	}
+	digits := strconv.FormatInt(abs/100, 10)
+	var b strings.Builder
+	for i, r := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(r)
+	}
+	out := fmt.Sprintf("%s%s %s", sign, symbol, b.String())
+	if cents := abs % 100; cents != 0 {
+		out += fmt.Sprintf(",%02d", cents)
+	}
+	return out
+}
+
+// UnmarshalJSON accepts the {"amount","currency"} object form as well as a
+// price string such as "US$2,990", which is parsed with Parse. An empty string
+// is the zero value.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		if strings.TrimSpace(s) == "" {
+			*m = Money{}
+			return nil
+		}
+		parsed, err := Parse(s)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	}
+
+	type plain Money
+	return json.Unmarshal(data, (*plain)(m))
+}
+
+// currencyRe matches currency symbols and codes; longer forms come first so
+// that "US$" is not read as "$".
+var currencyRe = regexp.MustCompile(`(?i)US\$|U\$S|U\$D|AR\$|R\$|USD|ARS|EUR|BRL|€|\$`)
+
+// spaces are the blanks allowed between a symbol and its amount, including
+// the non-breaking spaces of formatted prices.
+const spaces = " \t\u00a0\u202f"
+
+var numberRe = regexp.MustCompile(`\d+(?:[.,\x{00a0}\x{202f}]\d+)*`)
+
+var currencyCodes = map[string]string{
+	"US$": USD, "U$S": USD, "U$D": USD, "USD": USD,
+	"AR$": ARS, "ARS": ARS, "$": ARS,
+	"EUR": EUR, "€": EUR,
+	"R$": BRL, "BRL": BRL,
+}
+
+// Parse reads the first price in s, such as "Total: US$ 2.990,50 por persona".
+// The amount may be written in es-AR ("1.234,56") or US ("1,234.56") format;
+// a lone separator followed by exactly three digits ("2,990", "2.990") is a
+// thousands separator. A bare "$" is the Argentine peso, as on the site.
+func Parse(s string) (Money, error) {
+	sawCurrency := false
+	for _, loc := range currencyRe.FindAllStringIndex(s, -1) {
+		if !isCodeBoundary(s, loc[0], loc[1]) {
+			continue
+		}
+		sawCurrency = true
+		currency := currencyCodes[strings.ToUpper(s[loc[0]:loc[1]])]
+
+		// the amount follows the symbol ("US$ 10") or, less often, precedes the code ("10 USD")
+		if start, end, ok := amountAfter(s, loc[1]); ok {
+			return build(s[start:end], currency, isNegative(s, loc[0]))
+		}
+		if start, end, ok := amountBefore(s, loc[0]); ok {
+			return build(s[start:end], currency, isNegative(s, start))
+		}
+	}
+	if sawCurrency {
+		return Money{}, fmt.Errorf("parse %q: %w", s, ErrNoAmount)
+	}
+	return Money{}, fmt.Errorf("parse %q: %w", s, ErrNoCurrency)
+}
+
+// ParseAmount reads the first number in s as an amount of currency, for
+// prices whose currency is shown elsewhere.
+func ParseAmount(s, currency string) (Money, error) {
+	loc := numberRe.FindStringIndex(s)
+	if loc == nil {
+		return Money{}, fmt.Errorf("parse %q: %w", s, ErrNoAmount)
+	}
+	return build(s[loc[0]:loc[1]], currency, isNegative(s, loc[0]))
+}
+
+// isCodeBoundary rejects letter codes embedded in words ("PARS", "USDT").
+func isCodeBoundary(s string, start, end int) bool {
+	if !unicode.IsLetter(rune(s[start])) {
+		return true
+	}
+	prev, _ := utf8.DecodeLastRuneInString(s[:start])
+	next, _ := utf8.DecodeRuneInString(s[end:])
+	return !unicode.IsLetter(prev) && !unicode.IsLetter(next)
+}
+
+func amountAfter(s string, pos int) (int, int, bool) {
+	rest := strings.TrimLeft(s[pos:], spaces)
+	start := len(s) - len(rest)
+	loc := numberRe.FindStringIndex(rest)
+	if loc == nil || loc[0] != 0 {
+		return 0, 0, false
+	}
+	return start, start + loc[1], true
+}
+
+func amountBefore(s string, pos int) (int, int, bool) {
+	head := strings.TrimRight(s[:pos], spaces)
+	locs := numberRe.FindAllStringIndex(head, -1)
+	if len(locs) == 0 || locs[len(locs)-1][1] != len(head) {
+		return 0, 0, false
+	}
+	return locs[len(locs)-1][0], locs[len(locs)-1][1], true
+}
+
+// isNegative reports a minus sign right before pos. A dash followed by a
+// space ("3 noches - US$ 800") is punctuation, not a sign.
+func isNegative(s string, pos int) bool {
+	return strings.HasSuffix(s[:pos], "-") || strings.HasSuffix(s[:pos], "−")
+}
+
+func build(number, currency string, negative bool) (Money, error) {
+	amount, err := parseNumber(number)
+	if err != nil {
+		return Money{}, fmt.Errorf("parse amount %q: %w", number, err)
+	}
+	if negative {
+		amount = -amount
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+// parseNumber converts a number with grouping and decimal separators to
+// minor units.
+func parseNumber(number string) (int64, error) {
+	number = strings.NewReplacer("\u00a0", "", "\u202f", "").Replace(number)
+
+	lastDot := strings.LastIndex(number, ".")
+	lastComma := strings.LastIndex(number, ",")
+	decimalSep := -1
+	switch {
+	case lastDot >= 0 && lastComma >= 0:
+		// both present: whichever comes last is the decimal separator
+		decimalSep = max(lastDot, lastComma)
+	case lastDot >= 0 || lastComma >= 0:
+		sep := max(lastDot, lastComma)
+		if strings.Count(number, number[sep:sep+1]) == 1 && len(number)-sep-1 != 3 {
+			decimalSep = sep
+		}
+	}
+
+	intPart, fracPart := number, ""
+	if decimalSep >= 0 {
+		intPart, fracPart = number[:decimalSep], number[decimalSep+1:]
+		if strings.ContainsAny(fracPart, ".,") {
+			return 0, errors.New("misplaced separator")
+		}
+	}
+	if !validGrouping(intPart) {
+		return 0, errors.New("invalid digit grouping")
+	}
+	intPart = strings.NewReplacer(".", "", ",", "").Replace(intPart)
+
+	if len(fracPart) > minorDigits {
+		return 0, fmt.Errorf("more than %d decimal places", minorDigits)
+	}
+	fracPart += strings.Repeat("0", minorDigits-len(fracPart))
+
+	major, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	minor, err := strconv.ParseInt(fracPart, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if major > (1<<63-1-minor)/100 {
+		return 0, errors.New("amount out of range")
+	}
+	return major*100 + minor, nil
+}
+
+// validGrouping checks that thousands separators split the integer part into
+// groups of three after a leading group of one to three digits.
+func validGrouping(intPart string) bool {
+	groups := strings.FieldsFunc(intPart, func(r rune) bool { return r == '.' || r == ',' })
+	if len(groups) <= 1 {
+		return true
+	}
+	if strings.Count(intPart, ".") > 0 && strings.Count(intPart, ",") > 0 {
+		return false
+	}
+	if len(groups[0]) > 3 || len(groups) != strings.Count(intPart, ".")+strings.Count(intPart, ",")+1 {
+		return false
+	}
+	for _, g := range groups[1:] {
+		if len(g) != 3 {
+			return false
+		}
+	}
+	return true
+}
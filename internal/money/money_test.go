@@ -0,0 +1,245 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Money
+	}{
+		// symbols and codes
+		{"US$2,990", Money{299000, USD}},
+		{"US$ 2.990", Money{299000, USD}},
+		{"U$S 1.234,56", Money{123456, USD}},
+		{"u$s 75", Money{7500, USD}},
+		{"U$D 10", Money{1000, USD}},
+		{"USD 1,234.56", Money{123456, USD}},
+		{"USD2990", Money{299000, USD}},
+		{"$ 150.000", Money{15000000, ARS}},
+		{"$150.000,75", Money{15000075, ARS}},
+		{"ARS 1.234.567,89", Money{123456789, ARS}},
+		{"AR$ 99", Money{9900, ARS}},
+		{"€ 10,50", Money{1050, EUR}},
+		{"EUR 1.000", Money{100000, EUR}},
+		{"R$ 350,00", Money{35000, BRL}},
+
+		// es-AR versus US formats
+		{"US$ 1.234,56", Money{123456, USD}},
+		{"US$ 1,234.56", Money{123456, USD}},
+		{"US$ 1.234.567", Money{123456700, USD}},
+		{"US$ 1,234,567", Money{123456700, USD}},
+		{"US$ 1.234.567,8", Money{123456780, USD}},
+		{"US$ 1,234,567.8", Money{123456780, USD}},
+		{"US$ 12,5", Money{1250, USD}},
+		{"US$ 12.5", Money{1250, USD}},
+		{"US$ 12,50", Money{1250, USD}},
+		{"US$ 12.50", Money{1250, USD}},
+		{"US$ 0,99", Money{99, USD}},
+		{"US$ 999", Money{99900, USD}},
+		{"US$ 1.000", Money{100000, USD}},
+		{"US$ 1,000", Money{100000, USD}},
+		{"US$ 2.990", Money{299000, USD}},
+		{"US$ 2 990", Money{299000, USD}},
+
+		// code after the amount
+		{"2.990 USD", Money{299000, USD}},
+		{"1,234.56 EUR", Money{123456, EUR}},
+		{"150.000 ARS", Money{15000000, ARS}},
+
+		// surrounding text
+		{"Total: US$2,990", Money{299000, USD}},
+		{"Total US$ 2.990,50 por persona", Money{299050, USD}},
+		{"Desde $ 1.250.000 final", Money{125000000, ARS}},
+		{"Precio por noche: US$ 125.", Money{12500, USD}},
+		{"Mundial 2026 - 3 noches - US$2,990", Money{299000, USD}},
+		{"PARS 3 noches desde USD 800", Money{80000, USD}},
+		{"(US$ 45)", Money{4500, USD}},
+
+		// signs
+		{"-US$ 100", Money{-10000, USD}},
+		{"Descuento -$ 1.500,50", Money{-150050, ARS}},
+		{"Hotel 3* - US$ 800", Money{80000, USD}},
+		{"-100 USD", Money{-10000, USD}},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		in   string
+		want error
+	}{
+		{"", ErrNoCurrency},
+		{"2.990", ErrNoCurrency},
+		{"3 noches", ErrNoCurrency},
+		{"PARIS 300", ErrNoCurrency},
+		{"US$", ErrNoAmount},
+		{"Precio en USD", ErrNoAmount},
+		{"US$ 1,23,4", nil},
+		{"US$ 1.2345", nil},
+		{"US$ 1.234,567", nil},
+		{"US$ 12.34.567", nil},
+		{"US$ 1,234.567.8", nil},
+		{"US$ 99999999999999999999", nil},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if err == nil {
+			t.Errorf("Parse(%q) = %+v, want error", tt.in, got)
+			continue
+		}
+		if tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("Parse(%q) error = %v, want %v", tt.in, err, tt.want)
+		}
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		in       string
+		currency string
+		want     Money
+	}{
+		{"2.990", USD, Money{299000, USD}},
+		{"1.234,56", ARS, Money{123456, ARS}},
+		{"1,234.56", USD, Money{123456, USD}},
+		{"por persona 800", EUR, Money{80000, EUR}},
+		{"-15", USD, Money{-1500, USD}},
+	}
+	for _, tt := range tests {
+		got, err := ParseAmount(tt.in, tt.currency)
+		if err != nil {
+			t.Errorf("ParseAmount(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseAmount(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+
+	if _, err := ParseAmount("sin precio", USD); !errors.Is(err, ErrNoAmount) {
+		t.Errorf("ParseAmount without digits: %v", err)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		m       Money
+		decimal string
+		str     string
+		display string
+	}{
+		{Money{299000, USD}, "2990.00", "USD 2990.00", "US$ 2.990"},
+		{Money{299050, USD}, "2990.50", "USD 2990.50", "US$ 2.990,50"},
+		{Money{123456789, ARS}, "1234567.89", "ARS 1234567.89", "$ 1.234.567,89"},
+		{Money{5, EUR}, "0.05", "EUR 0.05", "€ 0,05"},
+		{Money{-150050, ARS}, "-1500.50", "ARS -1500.50", "-$ 1.500,50"},
+		{Money{100, "CLP"}, "1.00", "CLP 1.00", "CLP 1"},
+		{Money{}, "0.00", "", ""},
+	}
+	for _, tt := range tests {
+		if got := tt.m.Decimal(); got != tt.decimal {
+			t.Errorf("%+v.Decimal() = %q, want %q", tt.m, got, tt.decimal)
+		}
+		if got := tt.m.String(); got != tt.str {
+			t.Errorf("%+v.String() = %q, want %q", tt.m, got, tt.str)
+		}
+		if got := tt.m.Display(); got != tt.display {
+			t.Errorf("%+v.Display() = %q, want %q", tt.m, got, tt.display)
+		}
+	}
+
+	// Display output parses back to the same value
+	for _, tt := range tests[:5] {
+		back, err := Parse(tt.m.Display())
+		if err != nil || back != tt.m {
+			t.Errorf("Parse(%q) = %+v, %v; want %+v", tt.m.Display(), back, err, tt.m)
+		}
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	a, b := New(10, 50, USD), New(2, 25, USD)
+	if a != (Money{1050, USD}) {
+		t.Fatalf("New = %+v", a)
+	}
+	if neg := New(-3, 20, USD); neg.Amount != -320 {
+		t.Errorf("New(-3, 20) = %+v", neg)
+	}
+
+	sum, err := a.Add(b)
+	if err != nil || sum != (Money{1275, USD}) {
+		t.Errorf("Add = %+v, %v", sum, err)
+	}
+	if got := b.Times(3); got != (Money{675, USD}) {
+		t.Errorf("Times = %+v", got)
+	}
+	if got := a.Float(); got != 10.5 {
+		t.Errorf("Float = %v", got)
+	}
+
+	cmps := []struct {
+		x, y Money
+		want int
+	}{
+		{a, b, 1},
+		{b, a, -1},
+		{a, a, 0},
+	}
+	for _, c := range cmps {
+		if got, err := c.x.Compare(c.y); err != nil || got != c.want {
+			t.Errorf("Compare(%+v, %+v) = %d, %v", c.x, c.y, got, err)
+		}
+	}
+
+	ars := New(10, 0, ARS)
+	if _, err := a.Compare(ars); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Compare across currencies: %v", err)
+	}
+	if _, err := a.Add(ars); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Add across currencies: %v", err)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var v struct {
+		Total   Money `json:"total"`
+		Nightly Money `json:"nightly,omitzero"`
+	}
+	if err := json.Unmarshal([]byte(`{"total":"US$ 2.990,50","nightly":""}`), &v); err != nil {
+		t.Fatalf("unmarshal string form: %v", err)
+	}
+	if v.Total != (Money{299050, USD}) || !v.Nightly.IsZero() {
+		t.Fatalf("decoded %+v", v)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), `{"total":{"amount":299050,"currency":"USD"}}`; got != want {
+		t.Errorf("marshal = %s, want %s", got, want)
+	}
+
+	v.Total = Money{}
+	if err := json.Unmarshal(data, &v); err != nil || v.Total != (Money{299050, USD}) {
+		t.Errorf("round trip = %+v, %v", v.Total, err)
+	}
+
+	if err := json.Unmarshal([]byte(`{"total":"sin precio"}`), &v); err == nil {
+		t.Error("expected error for unparseable price")
+	}
+}
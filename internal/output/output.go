@@ -0,0 +1,204 @@
+// Package output writes command results as JSON, NDJSON, CSV or aligned text
+// tables inside a versioned envelope with run metadata.
+package output
+
+import (
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// SchemaVersion is bumped whenever a field is renamed or removed.
+const SchemaVersion = "1"
+
+// Exit codes shared by the commands.
+const (
+	ExitOK          = 0
+	ExitFailure     = 1 // browser, network or site failure
+	ExitUsage       = 2 // invalid flags or search parameters
+	ExitLoginFailed = 3 // the site rejected the login
+)
+
+// Format is an output encoding selected with -format.
+type Format string
+
+const (
+	FormatJSON   Format = "json"
+	FormatNDJSON Format = "ndjson"
+	FormatCSV    Format = "csv"
+	FormatTable  Format = "table"
+)
+
+// Formats lists the accepted -format values.
+var Formats = []Format{FormatJSON, FormatNDJSON, FormatCSV, FormatTable}
+
+// ParseFormat validates a -format value.
+func ParseFormat(s string) (Format, error) {
+	for _, f := range Formats {
+		if strings.EqualFold(s, string(f)) {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown format %q (want json, ndjson, csv or table)", s)
+}
+
+// Status is the overall outcome of a run.
+type Status string
+
+const (
+	StatusOK    Status = "ok"
+	StatusError Status = "error"
+)
+
+// Meta describes the run that produced a report.
+type Meta struct {
+	Tool       string            `json:"tool"`
+	RunID      string            `json:"run_id"`
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt time.Time         `json:"finished_at"`
+	DurationMS int64             `json:"duration_ms"`
+	Params     map[string]string `json:"params,omitempty"`
+	Records    int               `json:"records"`
+}
+
+// ErrorInfo is the failure reported in an error envelope.
+type ErrorInfo struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Record is one result row in NDJSON output, e.g. a hotel or an itinerary.
+type Record struct {
+	Kind string `json:"type"`
+	Data any    `json:"data"`
+}
+
+// Table is the flattened view of the records used by CSV and table output.
+type Table struct {
+	Columns []string
+	Rows    [][]string
+}
+
+// Report is everything a command outputs. Data is the full result written as
+// JSON; Records and Table are the per-row views for the other formats.
+type Report struct {
+	Meta    Meta
+	Status  Status
+	Error   *ErrorInfo
+	Data    any
+	Records []Record
+	Table   Table
+}
+
+// NewReport starts a report for tool, stamping the run ID and start time.
+func NewReport(tool string) *Report {
+	return &Report{
+		Meta: Meta{
+			Tool:      tool,
+			RunID:     newRunID(),
+			StartedAt: time.Now().UTC(),
+		},
+		Status: StatusOK,
+	}
+}
+
+// Fail marks the report as failed with a machine-readable code.
+func (r *Report) Fail(code string, err error) {
+	r.Status = StatusError
+	r.Error = &ErrorInfo{Code: code, Message: err.Error()}
+}
+
+type envelope struct {
+	SchemaVersion string     `json:"schema_version"`
+	Meta          Meta       `json:"meta"`
+	Status        Status     `json:"status"`
+	Error         *ErrorInfo `json:"error,omitempty"`
+	Data          any        `json:"data,omitempty"`
+}
+
+type ndjsonHeader struct {
+	Kind string `json:"type"`
+	envelope
+}
+
+// Write finishes the report's metadata and encodes it to w in format f.
+// CSV and table output carry no metadata; their errors go to errw instead.
+func (r *Report) Write(w, errw io.Writer, f Format) error {
+	r.Meta.FinishedAt = time.Now().UTC()
+	r.Meta.DurationMS = r.Meta.FinishedAt.Sub(r.Meta.StartedAt).Milliseconds()
+	r.Meta.Records = len(r.Records)
+
+	env := envelope{
+		SchemaVersion: SchemaVersion,
+		Meta:          r.Meta,
+		Status:        r.Status,
+		Error:         r.Error,
+		Data:          r.Data,
+	}
+
+	switch f {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(env)
+
+	case FormatNDJSON:
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		env.Data = nil
+		if err := enc.Encode(ndjsonHeader{Kind: "meta", envelope: env}); err != nil {
+			return err
+		}
+		for _, rec := range r.Records {
+			if err := enc.Encode(rec); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case FormatCSV:
+		r.writeError(errw)
+		cw := csv.NewWriter(w)
+		if len(r.Table.Columns) > 0 {
+			cw.Write(r.Table.Columns)
+		}
+		cw.WriteAll(r.Table.Rows)
+		return cw.Error()
+
+	case FormatTable:
+		r.writeError(errw)
+		if len(r.Table.Columns) == 0 {
+			return nil
+		}
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(r.Table.Columns, "\t"))
+		for _, row := range r.Table.Rows {
+			cells := make([]string, len(row))
+			for i, c := range row {
+				cells[i] = strings.NewReplacer("\t", " ", "\n", " ").Replace(c)
+			}
+			fmt.Fprintln(tw, strings.Join(cells, "\t"))
+		}
+		return tw.Flush()
+	}
+	return fmt.Errorf("unknown format %q", f)
+}
+
+func (r *Report) writeError(errw io.Writer) {
+	if r.Error != nil && errw != nil {
+		fmt.Fprintf(errw, "Error (%s): %s\n", r.Error.Code, r.Error.Message)
+	}
+}
+
+func newRunID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,84 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func sampleReport() *Report {
+	r := NewReport("test")
+	r.Data = map[string]int{"hotels": 2}
+	r.Records = []Record{{Kind: "hotel", Data: map[string]string{"name": "A"}}, {Kind: "hotel", Data: map[string]string{"name": "B, C"}}}
+	r.Table = Table{Columns: []string{"name", "total_price"}, Rows: [][]string{{"A", "10.00"}, {"B, C", "20.50"}}}
+	return r
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, s := range []string{"json", "NDJSON", "csv", "table"} {
+		if _, err := ParseFormat(s); err != nil {
+			t.Errorf("ParseFormat(%q): %v", s, err)
+		}
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("ParseFormat(xml): expected error")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var out bytes.Buffer
+	if err := sampleReport().Write(&out, nil, FormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	var env struct {
+		SchemaVersion string `json:"schema_version"`
+		Meta          Meta   `json:"meta"`
+		Status        Status `json:"status"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &env); err != nil {
+		t.Fatalf("json output: %v", err)
+	}
+	if env.SchemaVersion != SchemaVersion || env.Status != StatusOK || env.Meta.Records != 2 || env.Meta.RunID == "" {
+		t.Errorf("envelope = %+v", env)
+	}
+
+	out.Reset()
+	if err := sampleReport().Write(&out, nil, FormatNDJSON); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[0], `"type":"meta"`) || !strings.HasPrefix(lines[1], `{"type":"hotel"`) {
+		t.Errorf("ndjson output:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := sampleReport().Write(&out, nil, FormatCSV); err != nil {
+		t.Fatal(err)
+	}
+	if want := "name,total_price\nA,10.00\n\"B, C\",20.50\n"; out.String() != want {
+		t.Errorf("csv output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	var out, errOut bytes.Buffer
+	r := NewReport("test")
+	r.Fail("usage", errors.New("destination is required"))
+
+	if err := r.Write(&out, &errOut, FormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `"status": "error"`) || !strings.Contains(out.String(), `"code": "usage"`) {
+		t.Errorf("json error output:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := r.Write(&out, &errOut, FormatTable); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 0 || !strings.Contains(errOut.String(), "destination is required") {
+		t.Errorf("table error output: stdout %q, stderr %q", out.String(), errOut.String())
+	}
+}
@@ -0,0 +1,271 @@
+// Package ratelimit paces the requests sent to the supplier so the agency
+// account is not blocked: a token bucket shared by the whole process, one per
+// account, a random pause between consecutive actions of an account and a
+// growing backoff after the supplier answers "too many requests".
+package ratelimit
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit is a token bucket: PerMinute requests on average with bursts of up
+// to Burst. A zero PerMinute means unlimited.
+type Limit struct {
+	PerMinute float64
+	Burst     int
+}
+
+// Config holds the politeness settings.
+type Config struct {
+	Global  Limit // all accounts together
+	Account Limit // each account
+
+	// MinDelay and MaxDelay bound the random pause between two requests of
+	// the same account.
+	MinDelay time.Duration
+	MaxDelay time.Duration
+
+	// Backoff is the pause after a "too many requests" answer, doubled for
+	// each one in a row up to MaxBackoff. A longer Retry-After wins.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultConfig follows the supplier guide: 1-3s between actions.
+func DefaultConfig() Config {
+	return Config{
+		Global:     Limit{PerMinute: 60, Burst: 10},
+		Account:    Limit{PerMinute: 30, Burst: 5},
+		MinDelay:   time.Second,
+		MaxDelay:   3 * time.Second,
+		Backoff:    30 * time.Second,
+		MaxBackoff: 10 * time.Minute,
+	}
+}
+
+// Limiter is safe for concurrent use; share one per process.
+type Limiter struct {
+	cfg Config
+	now func() time.Time
+
+	mu         sync.Mutex
+	global     *bucket
+	accounts   map[string]*account
+	pauseUntil time.Time
+	strikes    int
+}
+
+type account struct {
+	bucket *bucket
+	next   time.Time // earliest start of its next request
+}
+
+func New(cfg Config) *Limiter {
+	return &Limiter{
+		cfg:      cfg,
+		now:      time.Now,
+		global:   newBucket(cfg.Global),
+		accounts: make(map[string]*account),
+	}
+}
+
+// Wait blocks until name's account may send its next request.
+func (l *Limiter) Wait(ctx context.Context, name string) error {
+	d := l.reserve(name)
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reserve books the next request of an account and returns how long to wait
+// for it. A booked slot is not returned if the caller gives up.
+func (l *Limiter) reserve(name string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	a, ok := l.accounts[name]
+	if !ok {
+		a = &account{bucket: newBucket(l.cfg.Account)}
+		l.accounts[name] = a
+	}
+
+	start := now
+	if l.pauseUntil.After(start) {
+		start = l.pauseUntil
+	}
+	if a.next.After(start) {
+		start = a.next
+	}
+	start = l.global.take(start)
+	start = a.bucket.take(start)
+	a.next = start.Add(l.delay())
+	return start.Sub(now)
+}
+
+func (l *Limiter) delay() time.Duration {
+	if l.cfg.MaxDelay <= l.cfg.MinDelay {
+		return l.cfg.MinDelay
+	}
+	return l.cfg.MinDelay + rand.N(l.cfg.MaxDelay-l.cfg.MinDelay)
+}
+
+// Throttled pauses every account after the supplier refused a request and
+// returns the pause.
+func (l *Limiter) Throttled(retryAfter time.Duration) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.strikes++
+	pause := l.cfg.Backoff << min(l.strikes-1, 16)
+	if l.cfg.MaxBackoff > 0 && pause > l.cfg.MaxBackoff {
+		pause = l.cfg.MaxBackoff
+	}
+	pause = max(pause, retryAfter)
+	if until := l.now().Add(pause); until.After(l.pauseUntil) {
+		l.pauseUntil = until
+	}
+	return pause
+}
+
+// Succeeded resets the backoff after an accepted request.
+func (l *Limiter) Succeeded() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.strikes = 0
+}
+
+// Account binds the limiter to one account.
+func (l *Limiter) Account(name string) *Pacer {
+	return &Pacer{limiter: l, account: name}
+}
+
+// Pacer paces the requests of one account. It satisfies browser.Throttler.
+type Pacer struct {
+	limiter *Limiter
+	account string
+	// OnThrottle, when set, is told about every refusal and the pause it caused.
+	OnThrottle func(pause time.Duration)
+}
+
+func (p *Pacer) Wait(ctx context.Context) error {
+	return p.limiter.Wait(ctx, p.account)
+}
+
+// Observe classifies a response of the supplier.
+func (p *Pacer) Observe(status int, retryAfter string, body []byte) {
+	switch {
+	case TooManyRequests(status, body):
+		pause := p.limiter.Throttled(RetryAfter(retryAfter, p.limiter.now()))
+		if p.OnThrottle != nil {
+			p.OnThrottle(pause)
+		}
+	case status > 0 && status < 400:
+		p.limiter.Succeeded()
+	}
+}
+
+var (
+	tooManyRe = regexp.MustCompile(`(?i)demasiadas\s+(solicitudes|peticiones|consultas)|too\s+many\s+requests|rate\s+limit\s+exceeded`)
+	scriptRe  = regexp.MustCompile(`(?is)<(script|style)\b.*?</(script|style)\s*>`)
+)
+
+// TooManyRequests reports whether a response is the supplier refusing
+// requests: a 429 or a page saying so outside its scripts and styles.
+func TooManyRequests(status int, body []byte) bool {
+	if status == http.StatusTooManyRequests {
+		return true
+	}
+	return tooManyRe.Match(body) && tooManyRe.Match(scriptRe.ReplaceAll(body, nil))
+}
+
+// RetryAfter parses a Retry-After header, in seconds or as an HTTP date.
+func RetryAfter(header string, now time.Time) time.Duration {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(header); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(header); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// bucket is a token bucket kept as the theoretical arrival time of the next
+// request (GCRA).
+type bucket struct {
+	interval time.Duration
+	burst    time.Duration // (Burst-1) intervals
+	tat      time.Time
+}
+
+func newBucket(limit Limit) *bucket {
+	if limit.PerMinute <= 0 {
+		return nil
+	}
+	interval := time.Duration(float64(time.Minute) / limit.PerMinute)
+	return &bucket{interval: interval, burst: time.Duration(max(limit.Burst-1, 0)) * interval}
+}
+
+// take returns the earliest time not before at when a request conforms, and
+// books it. A nil bucket is unlimited.
+func (b *bucket) take(at time.Time) time.Time {
+	if b == nil {
+		return at
+	}
+	tat := b.tat
+	if tat.Before(at) {
+		tat = at
+	}
+	if allowed := tat.Add(-b.burst); at.Before(allowed) {
+		at = allowed
+	}
+	b.tat = tat.Add(b.interval)
+	return at
+}
+
+// Transport paces an HTTP client's requests to Host.
+type Transport struct {
+	Base  http.RoundTripper // nil means http.DefaultTransport
+	Host  string
+	Pacer interface {
+		Wait(ctx context.Context) error
+		Observe(status int, retryAfter string, body []byte)
+	}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if req.URL.Host != t.Host {
+		return base.RoundTrip(req)
+	}
+	if err := t.Pacer.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	// bodies are read by the caller; a 429 status is enough here
+	t.Pacer.Observe(resp.StatusCode, resp.Header.Get("Retry-After"), nil)
+	return resp, nil
+}
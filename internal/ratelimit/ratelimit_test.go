@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReserve(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	l := New(Config{
+		Global:     Limit{PerMinute: 60, Burst: 3},
+		Account:    Limit{PerMinute: 30, Burst: 2},
+		Backoff:    30 * time.Second,
+		MaxBackoff: time.Minute,
+	})
+	l.now = func() time.Time { return now }
+
+	steps := []struct {
+		account string
+		want    time.Duration
+	}{
+		{"a", 0},
+		{"a", 0},               // account burst
+		{"a", 2 * time.Second}, // account: 30/min
+		{"b", 1 * time.Second}, // global burst of 3 used up by a
+		{"b", 2 * time.Second}, // global: 60/min
+	}
+	for i, s := range steps {
+		if got := l.reserve(s.account); got != s.want {
+			t.Errorf("step %d (%s): wait %s, want %s", i, s.account, got, s.want)
+		}
+	}
+
+	now = now.Add(time.Hour)
+	if pause := l.Throttled(0); pause != 30*time.Second {
+		t.Errorf("first backoff = %s", pause)
+	}
+	if pause := l.Throttled(0); pause != time.Minute {
+		t.Errorf("second backoff = %s, want doubled", pause)
+	}
+	if pause := l.Throttled(0); pause != time.Minute {
+		t.Errorf("third backoff = %s, want capped", pause)
+	}
+	if pause := l.Throttled(5 * time.Minute); pause != 5*time.Minute {
+		t.Errorf("backoff with Retry-After = %s", pause)
+	}
+	if got := l.reserve("c"); got != 5*time.Minute {
+		t.Errorf("wait while paused = %s", got)
+	}
+}
+
+func TestTooManyRequests(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		want   bool
+	}{
+		{429, "", true},
+		{200, "<html><body><h1>Demasiadas solicitudes</h1>Intente más tarde</body></html>", true},
+		{503, "Too Many Requests", true},
+		{200, `<script>var msg = "too many requests";</script><div>Hoteles</div>`, false},
+		{200, "<div>Resultados de búsqueda</div>", false},
+	}
+	for _, tt := range tests {
+		if got := TooManyRequests(tt.status, []byte(tt.body)); got != tt.want {
+			t.Errorf("TooManyRequests(%d, %q) = %v, want %v", tt.status, tt.body, got, tt.want)
+		}
+	}
+
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	if got := RetryAfter("120", now); got != 2*time.Minute {
+		t.Errorf("RetryAfter seconds = %s", got)
+	}
+	if got := RetryAfter("Sun, 18 Oct 2026 12:00:45 GMT", now); got != 45*time.Second {
+		t.Errorf("RetryAfter date = %s", got)
+	}
+}
@@ -0,0 +1,249 @@
+// Package retry decides what to do when a navigation or extraction step
+// fails: retry it with exponential backoff, log in again and retry, or give
+// up at once, depending on the class of the failure. The defaults follow the
+// error table of reglas.md; a CAPTCHA is handed to a human.
+package retry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Class is the kind of a failure.
+type Class string
+
+const (
+	ClassTimeout            Class = "timeout"             // a page or element did not load in time
+	ClassServerError        Class = "server_error"        // 5xx page or maintenance notice
+	ClassConnection         Class = "connection"          // reset, refused or dropped connection
+	ClassRateLimited        Class = "rate_limited"        // 429 or "demasiadas solicitudes"
+	ClassSessionExpired     Class = "session_expired"     // logged out mid-run (401, expired view)
+	ClassInvalidCredentials Class = "invalid_credentials" // wrong user or password
+	ClassForbidden          Class = "forbidden"           // 403 or locked account
+	ClassCaptcha            Class = "captcha"             // the site asks for a human
+	ClassCanceled           Class = "canceled"            // the caller gave up
+	ClassOther              Class = "other"
+)
+
+// Action is what a rule does with a failure.
+type Action string
+
+const (
+	ActionFail    Action = "fail"
+	ActionRetry   Action = "retry"
+	ActionRelogin Action = "relogin" // log in again, then retry
+	ActionHandoff Action = "handoff" // wait for a human to clear the page, then retry
+)
+
+// Rule is the behavior for one class. Attempts counts every try of the step,
+// the first included; the pause before retry n is Delay doubled n-1 times,
+// capped at MaxDelay.
+type Rule struct {
+	Action   Action   `json:"action"`
+	Attempts int      `json:"attempts,omitempty"`
+	Delay    Duration `json:"delay,omitempty"`
+	MaxDelay Duration `json:"max_delay,omitempty"`
+}
+
+// Duration reads "5s"-style strings from JSON.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"5s\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Policy maps each class to its rule; classes without a rule fail.
+type Policy map[Class]Rule
+
+// DefaultPolicy follows reglas.md: server errors are retried after 5s up to
+// 3 times, an expired session logs in again, invalid credentials and 403s
+// stop at once. A CAPTCHA waits for a human once.
+func DefaultPolicy() Policy {
+	transient := Rule{Action: ActionRetry, Attempts: 3, Delay: Duration(5 * time.Second), MaxDelay: Duration(time.Minute)}
+	return Policy{
+		ClassTimeout:            transient,
+		ClassServerError:        transient,
+		ClassConnection:         transient,
+		ClassRateLimited:        {Action: ActionRetry, Attempts: 3, Delay: Duration(30 * time.Second), MaxDelay: Duration(5 * time.Minute)},
+		ClassSessionExpired:     {Action: ActionRelogin, Attempts: 2},
+		ClassInvalidCredentials: {Action: ActionFail},
+		ClassForbidden:          {Action: ActionFail},
+		ClassCaptcha:            {Action: ActionHandoff, Attempts: 2},
+		ClassCanceled:           {Action: ActionFail},
+		ClassOther:              {Action: ActionFail},
+	}
+}
+
+// Load reads a JSON object of class to rule from path and lays it over the
+// defaults. A missing file gives the defaults.
+func Load(path string) (Policy, error) {
+	policy := DefaultPolicy()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return policy, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read retry policy: %w", err)
+	}
+	var overrides map[Class]Rule
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("parse retry policy %s: %w", path, err)
+	}
+	for class, rule := range overrides {
+		if _, ok := policy[class]; !ok {
+			return nil, fmt.Errorf("retry policy %s: unknown class %q", path, class)
+		}
+		switch rule.Action {
+		case ActionFail, ActionRetry, ActionRelogin, ActionHandoff:
+		default:
+			return nil, fmt.Errorf("retry policy %s: %s: unknown action %q (want fail, retry, relogin or handoff)", path, class, rule.Action)
+		}
+		policy[class] = rule
+	}
+	return policy, nil
+}
+
+// Error is the failure a step gave up on.
+type Error struct {
+	Step     string
+	Class    Class
+	Attempts int
+	Err      error
+}
+
+func (e *Error) Error() string {
+	if e.Attempts > 1 {
+		return fmt.Sprintf("%s failed after %d attempts (%s): %v", e.Step, e.Attempts, e.Class, e.Err)
+	}
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Step is one run of Policy.Do.
+type Step struct {
+	Name string
+	Run  func(ctx context.Context) error
+	// Classify maps a failure of Run to its class.
+	Classify func(ctx context.Context, err error) Class
+	// Relogin logs in again before a relogin retry; without it the step is
+	// simply retried.
+	Relogin func(ctx context.Context) error
+	// Handoff waits for a human to clear the page before a handoff retry;
+	// without it the step fails.
+	Handoff func(ctx context.Context) error
+	// Logf, when set, reports every retry.
+	Logf func(format string, args ...any)
+}
+
+// Do runs the step until it succeeds or the rule of its last failure gives
+// up, returning an *Error then. Attempts are counted per class.
+func (p Policy) Do(ctx context.Context, s Step) error {
+	tries := make(map[Class]int)
+	total := 0
+	for {
+		total++
+		err := s.Run(ctx)
+		if err == nil {
+			return nil
+		}
+		class := s.Classify(ctx, err)
+		if ctx.Err() != nil {
+			class = ClassCanceled
+		}
+		tries[class]++
+		rule, ok := p[class]
+		if !ok || rule.Action == ActionFail || tries[class] >= rule.Attempts ||
+			(rule.Action == ActionHandoff && s.Handoff == nil) {
+			return &Error{Step: s.Name, Class: class, Attempts: total, Err: err}
+		}
+		if rule.Action == ActionHandoff {
+			if s.Logf != nil {
+				s.Logf("%s: %s (%v); waiting for a human", s.Name, class, err)
+			}
+			if err := s.Handoff(ctx); err != nil {
+				return &Error{Step: s.Name, Class: class, Attempts: total, Err: err}
+			}
+			continue
+		}
+
+		wait := rule.backoff(tries[class])
+		if s.Logf != nil {
+			s.Logf("%s: %s (%v); %s in %s", s.Name, class, err, rule.Action, wait)
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return &Error{Step: s.Name, Class: ClassCanceled, Attempts: total, Err: err}
+		}
+		if rule.Action == ActionRelogin && s.Relogin != nil {
+			if err := s.Relogin(ctx); err != nil {
+				// a failed login is classified on its own, e.g. invalid credentials
+				return &Error{Step: s.Name + " relogin", Class: s.Classify(ctx, err), Attempts: total, Err: err}
+			}
+		}
+	}
+}
+
+// Budget is how long a step taking up to d per attempt may run when one class
+// of failure uses up its rule: every attempt plus the pauses between them,
+// under the rule that allows the longest. A handoff's wait for a human is not
+// included.
+func (p Policy) Budget(d time.Duration) time.Duration {
+	longest := d
+	for _, rule := range p {
+		if rule.Action == ActionFail || rule.Attempts <= 1 {
+			continue
+		}
+		total := time.Duration(rule.Attempts) * d
+		if rule.Action == ActionRetry || rule.Action == ActionRelogin {
+			for try := 1; try < rule.Attempts; try++ {
+				total += rule.backoff(try)
+			}
+		}
+		longest = max(longest, total)
+	}
+	return longest
+}
+
+func (r Rule) backoff(try int) time.Duration {
+	d := time.Duration(r.Delay)
+	for i := 1; i < try && d > 0; i++ {
+		d *= 2
+		if r.MaxDelay > 0 && d > time.Duration(r.MaxDelay) {
+			return time.Duration(r.MaxDelay)
+		}
+	}
+	return d
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
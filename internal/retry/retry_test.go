@@ -0,0 +1,125 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDo(t *testing.T) {
+	policy := Policy{
+		ClassTimeout:            {Action: ActionRetry, Attempts: 3},
+		ClassSessionExpired:     {Action: ActionRelogin, Attempts: 2},
+		ClassInvalidCredentials: {Action: ActionFail},
+		ClassCaptcha:            {Action: ActionHandoff, Attempts: 2},
+	}
+	tests := []struct {
+		name     string
+		failures []Class // class of each failing run, then success
+		runs     int
+		relogins int
+		handoffs int
+		want     Class // class of the returned error, "" for success
+	}{
+		{"success", nil, 1, 0, 0, ""},
+		{"transient", []Class{ClassTimeout, ClassTimeout}, 3, 0, 0, ""},
+		{"transient exhausted", []Class{ClassTimeout, ClassTimeout, ClassTimeout}, 3, 0, 0, ClassTimeout},
+		{"relogin", []Class{ClassSessionExpired}, 2, 1, 0, ""},
+		{"per class", []Class{ClassTimeout, ClassSessionExpired, ClassTimeout}, 4, 1, 0, ""},
+		{"handoff", []Class{ClassCaptcha}, 2, 0, 1, ""},
+		{"handoff exhausted", []Class{ClassCaptcha, ClassCaptcha}, 2, 0, 1, ClassCaptcha},
+		{"fail fast", []Class{ClassInvalidCredentials}, 1, 0, 0, ClassInvalidCredentials},
+		{"no rule", []Class{ClassOther}, 1, 0, 0, ClassOther},
+	}
+	for _, tt := range tests {
+		runs, relogins, handoffs := 0, 0, 0
+		err := policy.Do(context.Background(), Step{
+			Name: "step",
+			Run: func(ctx context.Context) error {
+				runs++
+				if runs <= len(tt.failures) {
+					return errors.New(string(tt.failures[runs-1]))
+				}
+				return nil
+			},
+			Classify: func(ctx context.Context, err error) Class { return Class(err.Error()) },
+			Relogin:  func(ctx context.Context) error { relogins++; return nil },
+			Handoff:  func(ctx context.Context) error { handoffs++; return nil },
+		})
+		var got Class
+		var retryErr *Error
+		if errors.As(err, &retryErr) {
+			got = retryErr.Class
+		} else if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if got != tt.want || runs != tt.runs || relogins != tt.relogins || handoffs != tt.handoffs {
+			t.Errorf("%s: class %q, %d runs, %d relogins, %d handoffs; want %q, %d, %d, %d",
+				tt.name, got, runs, relogins, handoffs, tt.want, tt.runs, tt.relogins, tt.handoffs)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	r := Rule{Delay: Duration(5 * time.Second), MaxDelay: Duration(15 * time.Second)}
+	for try, want := range []time.Duration{5 * time.Second, 10 * time.Second, 15 * time.Second, 15 * time.Second} {
+		if got := r.backoff(try + 1); got != want {
+			t.Errorf("backoff(%d) = %s, want %s", try+1, got, want)
+		}
+	}
+}
+
+func TestBudget(t *testing.T) {
+	tests := []struct {
+		policy Policy
+		want   time.Duration
+	}{
+		{Policy{ClassOther: {Action: ActionFail}}, time.Minute},
+		{Policy{ClassCaptcha: {Action: ActionHandoff, Attempts: 2}}, 2 * time.Minute},
+		{Policy{
+			ClassTimeout:     {Action: ActionRetry, Attempts: 3, Delay: Duration(5 * time.Second)},
+			ClassRateLimited: {Action: ActionRetry, Attempts: 2, Delay: Duration(time.Minute)},
+		}, 3*time.Minute + 15*time.Second},
+		// the default rate-limit rule waits 30s then 60s between three attempts
+		{DefaultPolicy(), 3*time.Minute + 90*time.Second},
+	}
+	for i, tt := range tests {
+		if got := tt.policy.Budget(time.Minute); got != tt.want {
+			t.Errorf("policy %d: Budget = %s, want %s", i, got, tt.want)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	policy, err := Load(filepath.Join(dir, "missing.json"))
+	if err != nil || policy[ClassTimeout].Attempts != 3 {
+		t.Fatalf("missing file: %v, %+v", err, policy[ClassTimeout])
+	}
+
+	path := filepath.Join(dir, "retry.json")
+	os.WriteFile(path, []byte(`{"timeout": {"action": "retry", "attempts": 5, "delay": "2s"}, "forbidden": {"action": "relogin", "attempts": 2}}`), 0o644)
+	policy, err = Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := policy[ClassTimeout]; r.Attempts != 5 || r.Delay != Duration(2*time.Second) {
+		t.Errorf("timeout = %+v", r)
+	}
+	if r := policy[ClassForbidden]; r.Action != ActionRelogin {
+		t.Errorf("forbidden = %+v", r)
+	}
+	if r := policy[ClassServerError]; r.Attempts != 3 {
+		t.Errorf("server_error lost its default: %+v", r)
+	}
+
+	for _, bad := range []string{`{"nope": {"action": "fail"}}`, `{"timeout": {"action": "wait"}}`, `{"timeout": {"action": "retry", "delay": 5}}`} {
+		os.WriteFile(path, []byte(bad), 0o644)
+		if _, err := Load(path); err == nil {
+			t.Errorf("Load(%s) succeeded", bad)
+		}
+	}
+}
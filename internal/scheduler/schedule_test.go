@@ -0,0 +1,64 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	buenosAires := time.FixedZone("ART", -3*3600)
+	from := time.Date(2026, 10, 18, 14, 7, 30, 0, buenosAires) // Sunday
+
+	tests := []struct {
+		spec string
+		want []time.Time // successive activations after from
+	}{
+		{"@every 90m", []time.Time{
+			time.Date(2026, 10, 18, 15, 37, 30, 0, buenosAires),
+			time.Date(2026, 10, 18, 17, 7, 30, 0, buenosAires),
+		}},
+		{"@hourly", []time.Time{
+			time.Date(2026, 10, 18, 15, 0, 0, 0, buenosAires),
+			time.Date(2026, 10, 18, 16, 0, 0, 0, buenosAires),
+		}},
+		{"*/20 8-20 * * *", []time.Time{
+			time.Date(2026, 10, 18, 14, 20, 0, 0, buenosAires),
+			time.Date(2026, 10, 18, 14, 40, 0, 0, buenosAires),
+		}},
+		{"0 9,18 * * 1-5", []time.Time{
+			time.Date(2026, 10, 19, 9, 0, 0, 0, buenosAires),
+			time.Date(2026, 10, 19, 18, 0, 0, 0, buenosAires),
+		}},
+		{"30 6 1 */3 *", []time.Time{
+			time.Date(2027, 1, 1, 6, 30, 0, 0, buenosAires),
+			time.Date(2027, 4, 1, 6, 30, 0, 0, buenosAires),
+		}},
+		// day of month and day of week both set: either matches
+		{"0 0 20 * 7", []time.Time{
+			time.Date(2026, 10, 20, 0, 0, 0, 0, buenosAires),
+			time.Date(2026, 10, 25, 0, 0, 0, 0, buenosAires),
+		}},
+		{"0 0 31 2 *", []time.Time{{}}},
+	}
+	for _, tt := range tests {
+		sched, err := Parse(tt.spec)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.spec, err)
+			continue
+		}
+		at := from
+		for i, want := range tt.want {
+			at = sched.Next(at)
+			if !at.Equal(want) {
+				t.Errorf("%q activation %d = %v, want %v", tt.spec, i, at, want)
+				break
+			}
+		}
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "@every 10ms", "@every soon", "@sometimes"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", spec)
+		}
+	}
+}
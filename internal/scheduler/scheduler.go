@@ -0,0 +1,230 @@
+// Package scheduler runs named jobs on cron or interval schedules inside a
+// long-running process. A job never overlaps itself, activations are spread
+// with random jitter and the last run of every job is persisted so a restart
+// does not run everything again.
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Job is a unit of scheduled work.
+type Job struct {
+	Name     string
+	Schedule Schedule
+	Jitter   time.Duration // random delay added to every activation
+	Timeout  time.Duration // 0 means no limit
+	Run      func(ctx context.Context) error
+}
+
+// JobState is what the scheduler remembers about a job across restarts.
+type JobState struct {
+	LastStart   time.Time `json:"last_start,omitzero"`
+	LastEnd     time.Time `json:"last_end,omitzero"`
+	LastSuccess time.Time `json:"last_success,omitzero"`
+	LastError   string    `json:"last_error,omitempty"`
+	Runs        int       `json:"runs"`
+	Failures    int       `json:"failures"`
+	Skipped     int       `json:"skipped"` // activations missed while a run was still going
+	Running     bool      `json:"running"`
+	Next        time.Time `json:"next,omitzero"`
+}
+
+// Scheduler runs jobs until its context is cancelled.
+type Scheduler struct {
+	statePath string
+	logf      func(format string, args ...any)
+	jobs      []Job
+
+	mu    sync.Mutex
+	state map[string]*JobState
+}
+
+// New returns a scheduler that persists job state in statePath (no
+// persistence when empty) and logs through logf.
+func New(statePath string, logf func(format string, args ...any)) (*Scheduler, error) {
+	s := &Scheduler{statePath: statePath, logf: logf, state: make(map[string]*JobState)}
+	if statePath == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read scheduler state: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.state); err != nil {
+		return nil, fmt.Errorf("parse scheduler state %s: %w", statePath, err)
+	}
+	for _, st := range s.state {
+		st.Running = false
+	}
+	return s, nil
+}
+
+// Add registers a job. Names must be unique.
+func (s *Scheduler) Add(job Job) error {
+	if job.Name == "" || job.Schedule == nil || job.Run == nil {
+		return errors.New("job needs a name, a schedule and a run function")
+	}
+	for _, j := range s.jobs {
+		if j.Name == job.Name {
+			return fmt.Errorf("duplicate job %q", job.Name)
+		}
+	}
+	s.jobs = append(s.jobs, job)
+	s.mu.Lock()
+	if s.state[job.Name] == nil {
+		s.state[job.Name] = &JobState{}
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// State returns a snapshot of every job's state.
+func (s *Scheduler) State() map[string]JobState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot := make(map[string]JobState, len(s.state))
+	for name, st := range s.state {
+		snapshot[name] = *st
+	}
+	return snapshot
+}
+
+// Run starts every job and blocks until ctx is cancelled and the running
+// jobs have returned.
+func (s *Scheduler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, job := range s.jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.loop(ctx, job)
+		}()
+	}
+	wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	for {
+		s.mu.Lock()
+		last := s.state[job.Name].LastStart
+		s.mu.Unlock()
+
+		now := time.Now()
+		var next time.Time
+		if last.IsZero() {
+			next = job.Schedule.Next(now)
+		} else if next = job.Schedule.Next(last); next.Before(now) {
+			next = now // missed while the process was down: run once now
+		}
+		if next.IsZero() {
+			s.logf("job %s: schedule has no further activations", job.Name)
+			return
+		}
+		if job.Jitter > 0 {
+			next = next.Add(rand.N(job.Jitter))
+		}
+		s.update(job.Name, func(st *JobState) { st.Next = next })
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		s.run(ctx, job)
+	}
+}
+
+// run executes one activation. Jobs run synchronously in their own loop, so a
+// slow run delays the next activation instead of overlapping it.
+func (s *Scheduler) run(ctx context.Context, job Job) {
+	start := time.Now()
+	s.update(job.Name, func(st *JobState) {
+		st.LastStart, st.Running, st.Next = start, true, time.Time{}
+	})
+	s.logf("job %s: started", job.Name)
+
+	runCtx, cancel := ctx, context.CancelFunc(func() {})
+	if job.Timeout > 0 {
+		runCtx, cancel = context.WithTimeout(ctx, job.Timeout)
+	}
+	err := runJob(runCtx, job)
+	cancel()
+
+	end := time.Now()
+	missed := 0
+	for t := job.Schedule.Next(start); !t.IsZero() && t.Before(end) && missed < 1000; t = job.Schedule.Next(t) {
+		missed++
+	}
+
+	s.update(job.Name, func(st *JobState) {
+		st.LastEnd, st.Running = end, false
+		st.Runs++
+		st.Skipped += missed
+		if err != nil {
+			st.Failures++
+			st.LastError = err.Error()
+		} else {
+			st.LastSuccess, st.LastError = end, ""
+		}
+	})
+	if err != nil {
+		s.logf("job %s: failed after %s: %v", job.Name, end.Sub(start).Round(time.Millisecond), err)
+	} else {
+		s.logf("job %s: done in %s", job.Name, end.Sub(start).Round(time.Millisecond))
+	}
+	if missed > 0 {
+		s.logf("job %s: skipped %d activation(s) while running", job.Name, missed)
+	}
+}
+
+// runJob converts a panic in a job into an error so one bad job does not
+// stop the daemon.
+func runJob(ctx context.Context, job Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return job.Run(ctx)
+}
+
+func (s *Scheduler) update(name string, fn func(*JobState)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(s.state[name])
+	if err := s.save(); err != nil {
+		s.logf("save scheduler state: %v", err)
+	}
+}
+
+func (s *Scheduler) save() error {
+	if s.statePath == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.statePath), 0o755); err != nil {
+		return err
+	}
+	tmp := s.statePath + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.statePath)
+}
@@ -0,0 +1,176 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func quiet(string, ...any) {}
+
+func newTestScheduler(t *testing.T, path string, jobs ...Job) *Scheduler {
+	t.Helper()
+	s, err := New(path, quiet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, job := range jobs {
+		if err := s.Add(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func TestRunActivation(t *testing.T) {
+	tests := []struct {
+		name        string
+		job         Job
+		minSkipped  int
+		maxSkipped  int
+		wantFailure string
+	}{
+		{
+			name: "quick run",
+			job:  Job{Schedule: Every(time.Hour), Run: func(context.Context) error { return nil }},
+		},
+		{
+			// activations 10ms apart fall inside a 50ms run: at least 4 are skipped
+			name: "overlapping activations",
+			job: Job{Schedule: Every(10 * time.Millisecond), Run: func(context.Context) error {
+				time.Sleep(50 * time.Millisecond)
+				return nil
+			}},
+			minSkipped: 4, maxSkipped: 1000,
+		},
+		{
+			name:        "failure",
+			job:         Job{Schedule: Every(time.Hour), Run: func(context.Context) error { return errors.New("site down") }},
+			wantFailure: "site down",
+		},
+		{
+			name:        "panic",
+			job:         Job{Schedule: Every(time.Hour), Run: func(context.Context) error { panic("nil map") }},
+			wantFailure: "panic: nil map",
+		},
+		{
+			name: "timeout",
+			job: Job{Schedule: Every(time.Hour), Timeout: 10 * time.Millisecond, Run: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			}},
+			wantFailure: context.DeadlineExceeded.Error(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.job.Name = "job"
+			s := newTestScheduler(t, "", tt.job)
+			s.run(context.Background(), tt.job)
+
+			st := s.State()["job"]
+			if st.Runs != 1 || st.Running || st.LastStart.IsZero() || st.LastEnd.Before(st.LastStart) {
+				t.Errorf("state = %+v", st)
+			}
+			if st.Skipped < tt.minSkipped || st.Skipped > tt.maxSkipped {
+				t.Errorf("skipped = %d, want %d..%d", st.Skipped, tt.minSkipped, tt.maxSkipped)
+			}
+			if tt.wantFailure == "" {
+				if st.Failures != 0 || st.LastSuccess.IsZero() {
+					t.Errorf("state = %+v, want a success", st)
+				}
+			} else if st.Failures != 1 || !strings.Contains(st.LastError, tt.wantFailure) || !st.LastSuccess.IsZero() {
+				t.Errorf("state = %+v, want failure %q", st, tt.wantFailure)
+			}
+		})
+	}
+}
+
+func TestStatePersisted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "scheduler-state.json")
+	job := Job{Name: "keepalive", Schedule: Every(time.Hour), Run: func(context.Context) error { return nil }}
+	s := newTestScheduler(t, path, job)
+	s.run(context.Background(), job)
+
+	// a crash mid-run leaves running set in the file; a restart clears it
+	s.update(job.Name, func(st *JobState) { st.Running = true })
+
+	restarted := newTestScheduler(t, path, job)
+	st := restarted.State()["keepalive"]
+	if st.Runs != 1 || st.Running || st.LastSuccess.IsZero() {
+		t.Errorf("restored state = %+v", st)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(path, quiet); err == nil {
+		t.Error("corrupt state file accepted")
+	}
+}
+
+func TestLoop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scheduler-state.json")
+	var missedRuns, freshRuns atomic.Int32
+	missed := Job{Name: "missed", Schedule: Every(time.Hour), Run: func(context.Context) error {
+		missedRuns.Add(1)
+		return nil
+	}}
+	fresh := Job{Name: "fresh", Schedule: Every(time.Hour), Run: func(context.Context) error {
+		freshRuns.Add(1)
+		return nil
+	}}
+	var panics atomic.Int32
+	flaky := Job{Name: "flaky", Schedule: Every(10 * time.Millisecond), Run: func(context.Context) error {
+		panics.Add(1)
+		panic("boom")
+	}}
+
+	// "missed" last ran two hours ago, while the process was down
+	s := newTestScheduler(t, path, missed, fresh, flaky)
+	s.update(missed.Name, func(st *JobState) { st.LastStart = time.Now().Add(-2 * time.Hour) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for (missedRuns.Load() == 0 || panics.Load() < 3) && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+
+	if n := missedRuns.Load(); n != 1 {
+		t.Errorf("missed job ran %d times, want once", n)
+	}
+	if n := freshRuns.Load(); n != 0 {
+		t.Errorf("job never run before ran %d times, want it to wait for its first activation", n)
+	}
+	if n := panics.Load(); n < 3 {
+		t.Errorf("panicking job ran %d times, want the loop to keep going", n)
+	}
+
+	state := s.State()
+	if next := state["fresh"].Next; time.Until(next) < 50*time.Minute {
+		t.Errorf("fresh job next = %s, want about an hour from now", next)
+	}
+	if st := state["missed"]; st.Runs != 1 || time.Until(st.Next) < 50*time.Minute {
+		t.Errorf("missed job state = %+v", st)
+	}
+	if st := state["flaky"]; st.Failures != st.Runs || !strings.Contains(st.LastError, "panic: boom") {
+		t.Errorf("flaky job state = %+v", st)
+	}
+}
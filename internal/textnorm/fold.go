@@ -0,0 +1,15 @@
+// Package textnorm normalizes user and site text for matching.
+package textnorm
+
+import "strings"
+
+var accentFolder = strings.NewReplacer(
+	"á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u", "ñ", "n",
+	"à", "a", "è", "e", "ì", "i", "ò", "o", "ù", "u", "ç", "c", "ã", "a", "õ", "o", "â", "a", "ê", "e", "ô", "o",
+)
+
+// Fold lower-cases s, strips Spanish and Portuguese accents and collapses
+// whitespace, so that "Meliá  Punta Cana" matches "melia punta cana".
+func Fold(s string) string {
+	return strings.Join(strings.Fields(accentFolder.Replace(strings.ToLower(s))), " ")
+}
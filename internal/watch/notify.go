@@ -0,0 +1,187 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Notifier delivers the alerts of one watch run.
+type Notifier interface {
+	Notify(ctx context.Context, alerts []Alert) error
+}
+
+// Notifiers sends alerts to every notifier, returning all their errors.
+type Notifiers []Notifier
+
+func (ns Notifiers) Notify(ctx context.Context, alerts []Alert) error {
+	if len(alerts) == 0 {
+		return nil
+	}
+	var errs []error
+	for _, n := range ns {
+		errs = append(errs, n.Notify(ctx, alerts))
+	}
+	return errors.Join(errs...)
+}
+
+// WriterNotifier prints one line per alert, e.g. to stdout.
+type WriterNotifier struct {
+	W io.Writer
+}
+
+func (n WriterNotifier) Notify(ctx context.Context, alerts []Alert) error {
+	for _, a := range alerts {
+		if _, err := fmt.Fprintf(n.W, "ALERT %s\n", a.Message()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WebhookNotifier POSTs {"alerts": [...]} as JSON to URL.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+func (n WebhookNotifier) Notify(ctx context.Context, alerts []Alert) error {
+	body, err := json.Marshal(struct {
+		Alerts []Alert `json:"alerts"`
+	}{alerts})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := n.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// smtpTimeout bounds a whole SMTP exchange when ctx has no earlier deadline.
+const smtpTimeout = 30 * time.Second
+
+// SMTPNotifier emails all alerts in one plain-text message. Addr is
+// host:port; Username may be empty for relays without authentication.
+type SMTPNotifier struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+func (n SMTPNotifier) Notify(ctx context.Context, alerts []Alert) error {
+	host, _, err := net.SplitHostPort(n.Addr)
+	if err != nil {
+		return fmt.Errorf("smtp address %q: %w", n.Addr, err)
+	}
+
+	subject := fmt.Sprintf("%d price alert(s): %s", len(alerts), alerts[0].Watch)
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", n.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n")
+	for _, a := range alerts {
+		msg.WriteString(a.Message() + "\r\n")
+	}
+
+	if err := n.send(ctx, host, []byte(msg.String())); err != nil {
+		return fmt.Errorf("send alert email: %w", err)
+	}
+	return nil
+}
+
+// send is smtp.SendMail bounded by ctx and smtpTimeout: a relay that stops
+// answering fails the notification instead of hanging the run.
+func (n SMTPNotifier) send(ctx context.Context, host string, msg []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", n.Addr)
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if err := n.deliver(conn, host, msg); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	return nil
+}
+
+func (n SMTPNotifier) deliver(conn net.Conn, host string, msg []byte) error {
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if err := c.Hello("localhost"); err != nil {
+		return err
+	}
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if n.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", n.Username, n.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(n.From); err != nil {
+		return err
+	}
+	for _, to := range n.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,120 @@
+package watch
+
+import (
+	"bufio"
+	"context"
+	"mime"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"ExpeditusClient/internal/money"
+)
+
+// fakeRelay accepts SMTP connections on a local port. A silent relay never
+// greets; otherwise each message's data is sent on the returned channel.
+func fakeRelay(t *testing.T, silent bool) (string, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	messages := make(chan string, 1)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+			if silent {
+				continue
+			}
+			go serveSMTP(textproto.NewConn(conn), messages)
+		}
+	}()
+	return ln.Addr().String(), messages
+}
+
+func serveSMTP(c *textproto.Conn, messages chan<- string) {
+	defer c.Close()
+	c.PrintfLine("220 fake ESMTP")
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		switch verb, _, _ := strings.Cut(line, " "); strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			c.PrintfLine("250 fake")
+		case "DATA":
+			c.PrintfLine("354 go ahead")
+			data, err := c.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			messages <- string(data)
+			c.PrintfLine("250 queued")
+		case "QUIT":
+			c.PrintfLine("221 bye")
+			return
+		default:
+			c.PrintfLine("250 ok")
+		}
+	}
+}
+
+func testAlerts() []Alert {
+	return []Alert{{
+		Watch: "Pérez – Año Nuevo", Item: "Meliá Caribe", Reason: ReasonBelowThreshold,
+		Price: money.New(1400, 0, money.USD), Threshold: money.New(1500, 0, money.USD),
+		CheckIn: time.Date(2026, 12, 28, 0, 0, 0, 0, time.UTC), CheckOut: time.Date(2027, 1, 4, 0, 0, 0, 0, time.UTC),
+	}}
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	addr, messages := fakeRelay(t, false)
+	n := SMTPNotifier{Addr: addr, From: "alertas@example.com", To: []string{"ventas@example.com"}}
+	if err := n.Notify(context.Background(), testAlerts()); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := <-messages
+	header, err := textproto.NewReader(bufio.NewReader(strings.NewReader(msg))).ReadMIMEHeader()
+	if err != nil {
+		t.Fatalf("parse %q: %v", msg, err)
+	}
+	raw := header.Get("Subject")
+	for _, r := range raw {
+		if r > 127 {
+			t.Fatalf("subject is not ASCII: %q", raw)
+		}
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(raw)
+	if err != nil || subject != "1 price alert(s): Pérez – Año Nuevo" {
+		t.Errorf("subject %q decodes to %q (%v)", raw, subject, err)
+	}
+	if !strings.Contains(msg, "Meliá Caribe") {
+		t.Errorf("body lost the alert: %q", msg)
+	}
+}
+
+func TestSMTPNotifierHungRelay(t *testing.T) {
+	addr, _ := fakeRelay(t, true)
+	n := SMTPNotifier{Addr: addr, From: "alertas@example.com", To: []string{"ventas@example.com"}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := n.Notify(ctx, testAlerts())
+	if err == nil {
+		t.Fatal("a relay that never answers accepted the alert")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Notify returned after %s, want it bounded by the context", elapsed)
+	}
+}
@@ -0,0 +1,245 @@
+// Package watch keeps saved hotel searches with price thresholds and decides
+// which of the prices found by a re-run should raise an alert.
+package watch
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"ExpeditusClient/internal/history"
+	"ExpeditusClient/internal/money"
+	"ExpeditusClient/internal/textnorm"
+)
+
+// Alert reasons.
+const (
+	ReasonBelowThreshold = "below_threshold"
+	ReasonDrop           = "drop"
+)
+
+// Watch is a saved hotel search and the price changes worth an alert.
+type Watch struct {
+	ID          string      `json:"id"`
+	Name        string      `json:"name,omitempty"`
+	Destination string      `json:"destination"`
+	CheckIn     time.Time   `json:"check_in"`
+	CheckOut    time.Time   `json:"check_out"`
+	Occupancy   string      `json:"occupancy"`       // e.g. "2;2:5,8"
+	Hotel       string      `json:"hotel,omitempty"` // only hotels whose name contains this
+	Below       money.Money `json:"below,omitzero"`  // alert when a price drops below this
+	DropPct     float64     `json:"drop_pct,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+}
+
+// Validate reports a watch that can never run or never alert.
+func (w Watch) Validate(today time.Time) error {
+	switch {
+	case strings.TrimSpace(w.Destination) == "":
+		return errors.New("destination is required")
+	case w.CheckIn.IsZero() || w.CheckOut.IsZero():
+		return errors.New("check-in and check-out dates are required")
+	case !w.CheckOut.After(w.CheckIn):
+		return errors.New("check-out must be after check-in")
+	case w.Expired(today):
+		return fmt.Errorf("check-in %s is in the past", w.CheckIn.Format(time.DateOnly))
+	case w.Below.IsZero() && w.DropPct <= 0:
+		return errors.New("a price threshold or a drop percentage is required")
+	case w.DropPct < 0 || w.DropPct >= 100:
+		return fmt.Errorf("drop percentage must be between 0 and 100, got %g", w.DropPct)
+	}
+	return nil
+}
+
+// Expired reports whether the stay has already started.
+func (w Watch) Expired(today time.Time) bool {
+	y, m, d := today.Date()
+	return w.CheckIn.Before(time.Date(y, m, d, 0, 0, 0, 0, w.CheckIn.Location()))
+}
+
+// Label is the watch name, or its destination and dates when unnamed.
+func (w Watch) Label() string {
+	if w.Name != "" {
+		return w.Name
+	}
+	return fmt.Sprintf("%s %s-%s", w.Destination, w.CheckIn.Format("02/01"), w.CheckOut.Format("02/01"))
+}
+
+// Load reads the watchlist in path. A missing file is an empty list.
+func Load(path string) ([]Watch, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read watchlist: %w", err)
+	}
+	var watches []Watch
+	if err := json.Unmarshal(data, &watches); err != nil {
+		return nil, fmt.Errorf("parse watchlist %s: %w", path, err)
+	}
+	return watches, nil
+}
+
+// Save replaces the watchlist in path.
+func Save(path string, watches []Watch) error {
+	data, err := json.MarshalIndent(watches, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(path, append(data, '\n')); err != nil {
+		return fmt.Errorf("write watchlist: %w", err)
+	}
+	return nil
+}
+
+// Add appends w to the list with a fresh ID and creation time.
+func Add(watches []Watch, w Watch) ([]Watch, Watch) {
+	w.ID = newID()
+	w.CreatedAt = time.Now().UTC()
+	return append(watches, w), w
+}
+
+// Remove drops the watch with the given ID.
+func Remove(watches []Watch, id string) ([]Watch, error) {
+	for i, w := range watches {
+		if w.ID == id {
+			return append(watches[:i:i], watches[i+1:]...), nil
+		}
+	}
+	return watches, fmt.Errorf("no watch with id %q", id)
+}
+
+// Alert is a price that crossed a watch's threshold or dropped enough since
+// it was last observed.
+type Alert struct {
+	WatchID     string      `json:"watch_id"`
+	Watch       string      `json:"watch"`
+	Reason      string      `json:"reason"`
+	Item        string      `json:"item"`
+	Detail      string      `json:"detail,omitempty"`
+	Destination string      `json:"destination"`
+	CheckIn     time.Time   `json:"check_in"`
+	CheckOut    time.Time   `json:"check_out"`
+	Price       money.Money `json:"price"`
+	Previous    money.Money `json:"previous,omitzero"`
+	Threshold   money.Money `json:"threshold,omitzero"`
+	DropPct     float64     `json:"drop_pct,omitempty"`
+}
+
+// Message is a one-line description of the alert for people.
+func (a Alert) Message() string {
+	msg := fmt.Sprintf("%s: %s", a.Watch, a.Item)
+	if a.Detail != "" {
+		msg += " (" + a.Detail + ")"
+	}
+	msg += " " + a.Price.Display()
+	switch a.Reason {
+	case ReasonBelowThreshold:
+		msg += ", below " + a.Threshold.Display()
+	case ReasonDrop:
+		msg += fmt.Sprintf(", down %.1f%% from %s", a.DropPct, a.Previous.Display())
+	}
+	return msg + fmt.Sprintf(" [%s - %s]", a.CheckIn.Format("02/01/2006"), a.CheckOut.Format("02/01/2006"))
+}
+
+// Evaluate compares the prices found by re-running w with those recorded
+// before (oldest first, as returned by history.Store.Prices). Only previous
+// prices for the same stay, occupancy and destination are compared. A price
+// below the threshold only alerts when it crosses it or keeps falling, so a
+// watch run several times a day does not repeat the same alert.
+func Evaluate(w Watch, current, previous []history.Price) []Alert {
+	last := make(map[string]money.Money)
+	for _, p := range previous {
+		if sameDay(p.CheckIn, w.CheckIn) && sameDay(p.CheckOut, w.CheckOut) && sameOccupancy(p.Occupancy, w.Occupancy) {
+			last[itemKey(p)] = p.Price
+		}
+	}
+
+	var alerts []Alert
+	for _, p := range current {
+		if w.Hotel != "" && !strings.Contains(textnorm.Fold(p.Item), textnorm.Fold(w.Hotel)) {
+			continue
+		}
+		prev, seen := last[itemKey(p)]
+		alert := Alert{
+			WatchID: w.ID, Watch: w.Label(),
+			Item: p.Item, Detail: p.Detail, Destination: p.Destination,
+			CheckIn: w.CheckIn, CheckOut: w.CheckOut, Price: p.Price,
+		}
+		if seen {
+			alert.Previous = prev
+		}
+
+		if below(p.Price, w.Below) && (!seen || !below(prev, w.Below) || below(p.Price, prev)) {
+			alert.Reason, alert.Threshold = ReasonBelowThreshold, w.Below
+			alerts = append(alerts, alert)
+			continue
+		}
+		if w.DropPct > 0 && seen && below(p.Price, prev) {
+			pct := float64(prev.Amount-p.Price.Amount) * 100 / float64(prev.Amount)
+			if pct >= w.DropPct {
+				alert.Reason, alert.DropPct = ReasonDrop, pct
+				alerts = append(alerts, alert)
+			}
+		}
+	}
+	return alerts
+}
+
+// below reports whether a is lower than a non-zero b in the same currency.
+func below(a, b money.Money) bool {
+	if b.IsZero() {
+		return false
+	}
+	c, err := a.Compare(b)
+	return err == nil && c < 0
+}
+
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// sameOccupancy compares occupancies ignoring the spaces ParseOccupancy
+// allows.
+func sameOccupancy(a, b string) bool {
+	return strings.Join(strings.Fields(a), "") == strings.Join(strings.Fields(b), "")
+}
+
+func itemKey(p history.Price) string {
+	return strings.Join([]string{p.Kind, textnorm.Fold(p.Destination), textnorm.Fold(p.Item), textnorm.Fold(p.Detail), p.Price.Currency}, "|")
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func newID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
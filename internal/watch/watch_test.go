@@ -0,0 +1,120 @@
+package watch
+
+import (
+	"testing"
+	"time"
+
+	"ExpeditusClient/internal/history"
+	"ExpeditusClient/internal/money"
+)
+
+func TestEvaluate(t *testing.T) {
+	checkIn := time.Date(2026, 12, 20, 0, 0, 0, 0, time.UTC)
+	checkOut := checkIn.AddDate(0, 0, 7)
+	price := func(item string, usd int64) history.Price {
+		return history.Price{Kind: history.KindHotel, Item: item, CheckIn: checkIn, CheckOut: checkOut, Price: money.New(usd, 0, money.USD)}
+	}
+	otherStay := price("Hotel Riu", 2000)
+	otherStay.CheckOut = checkOut.AddDate(0, 0, 1)
+	occupied := func(p history.Price, occupancy string) history.Price {
+		p.Occupancy = occupancy
+		return p
+	}
+	at := func(p history.Price, dest string) history.Price {
+		p.Destination = dest
+		return p
+	}
+
+	tests := []struct {
+		name     string
+		watch    Watch
+		current  []history.Price
+		previous []history.Price
+		want     []string // reasons, in order
+	}{
+		{
+			name:    "first run below threshold",
+			watch:   Watch{Below: money.New(1000, 0, money.USD)},
+			current: []history.Price{price("Hotel Riu", 900), price("Meliá", 1200)},
+			want:    []string{ReasonBelowThreshold},
+		},
+		{
+			name:     "already below and unchanged",
+			watch:    Watch{Below: money.New(1000, 0, money.USD)},
+			current:  []history.Price{price("Hotel Riu", 900)},
+			previous: []history.Price{price("Hotel Riu", 900)},
+		},
+		{
+			name:     "already below and still falling",
+			watch:    Watch{Below: money.New(1000, 0, money.USD)},
+			current:  []history.Price{price("Hotel Riu", 880)},
+			previous: []history.Price{price("Hotel Riu", 900)},
+			want:     []string{ReasonBelowThreshold},
+		},
+		{
+			name:     "drop against last observation",
+			watch:    Watch{DropPct: 10},
+			current:  []history.Price{price("Hotel Riu", 900), price("Melia", 1150)},
+			previous: []history.Price{price("Hotel Riu", 800), price("Hotel Riu", 1000), price("MELIA", 1200)},
+			want:     []string{ReasonDrop},
+		},
+		{
+			name:     "other stays are not compared",
+			watch:    Watch{DropPct: 10},
+			current:  []history.Price{price("Hotel Riu", 900)},
+			previous: []history.Price{otherStay},
+		},
+		{
+			name:     "other occupancies are not compared",
+			watch:    Watch{Occupancy: "2", DropPct: 10},
+			current:  []history.Price{occupied(price("Hotel Riu", 900), "2")},
+			previous: []history.Price{occupied(price("Hotel Riu", 950), "2"), occupied(price("Hotel Riu", 2000), "2;2")},
+		},
+		{
+			name:     "drop for the same occupancy",
+			watch:    Watch{Occupancy: "2; 2:5", DropPct: 10},
+			current:  []history.Price{occupied(price("Hotel Riu", 900), "2;2:5")},
+			previous: []history.Price{occupied(price("Hotel Riu", 1000), "2;2:5"), occupied(price("Hotel Riu", 850), "2")},
+			want:     []string{ReasonDrop},
+		},
+		{
+			name:     "other destinations are not compared",
+			watch:    Watch{DropPct: 10},
+			current:  []history.Price{at(price("Hotel Riu", 900), "Destination::PUJ")},
+			previous: []history.Price{at(price("Hotel Riu", 2000), "Destination::CUN")},
+		},
+		{
+			name:     "hotel filter",
+			watch:    Watch{Hotel: "melia", DropPct: 5},
+			current:  []history.Price{price("Hotel Riu", 500), price("Meliá Caribe", 900)},
+			previous: []history.Price{price("Hotel Riu", 1000), price("Meliá Caribe", 1000)},
+			want:     []string{ReasonDrop},
+		},
+		{
+			name:    "other currency never crosses",
+			watch:   Watch{Below: money.New(1000, 0, money.USD)},
+			current: []history.Price{{Kind: history.KindHotel, Item: "Hotel Riu", CheckIn: checkIn, CheckOut: checkOut, Price: money.New(500, 0, money.ARS)}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.watch.CheckIn, tt.watch.CheckOut = checkIn, checkOut
+			alerts := Evaluate(tt.watch, tt.current, tt.previous)
+			if len(alerts) != len(tt.want) {
+				t.Fatalf("alerts = %+v, want reasons %v", alerts, tt.want)
+			}
+			for i, a := range alerts {
+				if a.Reason != tt.want[i] {
+					t.Errorf("alert %d reason = %s, want %s", i, a.Reason, tt.want[i])
+				}
+			}
+		})
+	}
+
+	drop := Evaluate(Watch{DropPct: 10, CheckIn: checkIn, CheckOut: checkOut},
+		[]history.Price{price("Hotel Riu", 900)}, []history.Price{price("Hotel Riu", 1000)})
+	if len(drop) != 1 || drop[0].DropPct != 10 || drop[0].Previous != money.New(1000, 0, money.USD) {
+		t.Errorf("drop alert = %+v", drop)
+	}
+}